@@ -2,113 +2,266 @@ package main
 
 import (
 	"context"
-	_ "embed"
-	"log"
 	"net/http"
 	"os"
+	"strings"
+	"time"
+	_ "time/tzdata"
 
 	"github.com/labstack/echo/v4"
 	"github.com/rs/zerolog"
 	echoSwagger "github.com/swaggo/echo-swagger"
+	swaggerFiles "github.com/swaggo/files/v2"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/labstack/echo/otelecho"
 
 	"github.com/infosec554/clean-archtectura/config"
-	_ "github.com/infosec554/clean-archtectura/docs"
+	"github.com/infosec554/clean-archtectura/docs"
+	"github.com/infosec554/clean-archtectura/internal/repository/cached"
 	"github.com/infosec554/clean-archtectura/internal/repository/postgres"
 	"github.com/infosec554/clean-archtectura/internal/rest"
 	"github.com/infosec554/clean-archtectura/internal/rest/middleware"
+	"github.com/infosec554/clean-archtectura/internal/workers"
 	"github.com/infosec554/clean-archtectura/pkg/cache"
+	"github.com/infosec554/clean-archtectura/pkg/email"
+	"github.com/infosec554/clean-archtectura/pkg/health"
+	"github.com/infosec554/clean-archtectura/pkg/lifecycle"
+	"github.com/infosec554/clean-archtectura/pkg/logging"
+	"github.com/infosec554/clean-archtectura/pkg/metrics"
+	"github.com/infosec554/clean-archtectura/pkg/ratelimit"
+	"github.com/infosec554/clean-archtectura/pkg/storage"
 	"github.com/infosec554/clean-archtectura/pkg/token"
+	"github.com/infosec554/clean-archtectura/pkg/tracing"
+	"github.com/infosec554/clean-archtectura/pkg/validator"
+	export_service "github.com/infosec554/clean-archtectura/service/export"
 	user_service "github.com/infosec554/clean-archtectura/service/user"
 )
 
-var publicRoutes = map[string]bool{
-	"/api/v1/health":              true,
-	"/api/v1/docs":                true,
-	"/api/v1/dual_education.yaml": true,
-}
-
-var swaggerFile []byte
-
+// @title                       Clean Architecture API
+// @version                     1.0
+// @description                 User accounts, authentication, preferences and personal data export.
+// @BasePath                    /api/v1
+// @securityDefinitions.apikey  BearerAuth
+// @in                          header
+// @name                        Authorization
+// @description                 Type "Bearer" followed by a space and the JWT token.
 func main() {
-	cfg := config.Load()
-	ctx := context.Background()
-
-	logger := zerolog.New(os.Stdout)
 	zerolog.ErrorFieldName = "error"
+	logger := zerolog.New(os.Stdout).With().Timestamp().Logger().Hook(logging.Hook{})
+	// Used by zerolog.Ctx for contexts that carry no request logger
+	zerolog.DefaultContextLogger = &logger
+	ctx := logger.WithContext(context.Background())
 
+	cfg, err := config.Load()
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Invalid configuration")
+	}
+	// Config marshals with its secrets redacted
 	logger.Info().Any("config", cfg).Msg("loading config")
 
-	c := cache.NewCache(cfg)
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(ctx, cfg, os.Args[2:]); err != nil {
+			logger.Fatal().Err(err).Msg("Migration failed")
+		}
+		return
+	}
+	// "app worker" runs only the background jobs, without the HTTP server
+	workerMode := len(os.Args) > 1 && os.Args[1] == "worker"
+
+	lc := lifecycle.New(cfg.ShutdownTimeout, cfg.ShutdownDrainDelay, logger)
+
+	shutdownTracing, err := tracing.Setup(ctx, cfg)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Tracing init error")
+	}
+	// Registered first so it is closed last, after the final spans are ended
+	lc.OnClose("tracing", func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return shutdownTracing(ctx)
+	})
+	checks := health.NewRegistry(cfg.HealthCheckTimeout, lc.Draining())
+
+	c, err := cache.New(ctx, cfg)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Cache init error")
+	}
+	lc.OnClose("redis", c.Close)
+	checks.Register(c.HealthChecks()...)
 
 	store, err := postgres.New(ctx, cfg)
 	if err != nil {
-		log.Fatalf("❌ Storage init error: %v", err)
+		logger.Fatal().Err(err).Msg("Storage init error")
+	}
+	if cfg.DBAutoMigrate {
+		if err := store.Migrator().Up(ctx); err != nil {
+			logger.Fatal().Err(err).Msg("Migration failed")
+		}
+	}
+	lc.OnClose("postgres", store.Close)
+	metrics.Registry.MustRegister(store.Collector(cfg.PostgresDB))
+	checks.Register(store.HealthChecks()...)
+	lc.Go("postgres-replicas", store.Replicas.Monitor)
+
+	checks.Register(email.NewSender(cfg).HealthChecks()...)
+	if cfg.MinioEndpoint != "" {
+		checks.Register(health.HTTP("minio", strings.TrimRight(cfg.MinioEndpoint, "/")+"/minio/health/live", true))
+	}
+
+	jwtManager := token.NewJWTManager(cfg.JWTSecretKey)
+
+	userCache := cache.NewTiered("users", c, cfg.UserCacheSize, cfg.UserCacheLocalTTL, max(cfg.UserCacheTTL, cfg.UserCacheNegativeTTL))
+	lc.Go("user-cache-invalidation", userCache.Listen)
+	userRepo := cached.NewUserRepository(postgres.NewUserRepository(store.DB, store.Replicas, logger), userCache, cfg.UserCacheTTL, cfg.UserCacheNegativeTTL, logger)
+	auditRepo := postgres.NewAuditRepository(store.DB, logger)
+	jobRepo := postgres.NewJobRepository(store.DB, logger)
+	outboxRepo := postgres.NewOutboxRepository(store.DB, logger)
+	userService := user_service.NewUserService(userRepo, auditRepo, store, jobRepo, outboxRepo, cfg, c, logger, jwtManager)
+
+	exportStorage, err := storage.NewLocal(cfg.ExportDir)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Export storage init error")
+	}
+	exportRepo := postgres.NewDataExportRepository(store.DB, logger)
+	exportService := export_service.NewExportService(exportRepo, userRepo, exportStorage, store, jobRepo, cfg, logger)
+	// Logins and sessions are not per-user records: tokens are stateless,
+	// and logins only reach metrics and request logs
+	exportService.Register(userService, user_service.NewAuditTrail(auditRepo))
+
+	queues, err := workers.ParseQueues(cfg.WorkerQueues)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Worker queues config error")
+	}
+	worker := workers.New(jobRepo, queues, cfg.WorkerPollInterval, cfg.WorkerLease, logger)
+	workers.Handle(worker, user_service.VerificationEmailJob, userService.SendVerificationEmail)
+	workers.Handle(worker, user_service.AccountStatusEmailJob, userService.SendAccountStatusEmail)
+	workers.Handle(worker, export_service.BuildExportJob, exportService.Build)
+	relay := workers.NewRelay(outboxRepo, store, store, postgres.OutboxChannel, cfg.OutboxPollInterval, cfg.OutboxBatchSize, cfg.OutboxRetention, cfg.OutboxMaxAttempts, logger)
+	workers.Subscribe(relay, user_service.UserRegistered, userService.OnRegistered)
+	if workerMode || cfg.WorkerInProcess {
+		lc.Go("worker", worker.Run)
+		lc.Go("outbox-relay", relay.Run)
+	}
+
+	// The worker serves no HTTP; the API process owns the routes and the
+	// export janitor
+	if workerMode {
+		logger.Info().Str("app", cfg.AppName).Any("queues", queues).Msg("Starting worker")
+		if err := lc.Run(ctx); err != nil {
+			logger.Fatal().Err(err).Msg("Worker stopped with error")
+		}
+		return
 	}
-	defer store.Close()
 
 	e := echo.New()
-	e.Use(middleware.CORS())
+	e.HideBanner = true
+	e.HidePort = true
+	e.HTTPErrorHandler = rest.ErrorHandler(logger)
+	e.IPExtractor = middleware.IPExtractor(cfg.TrustedProxies)
+	e.Validator = validator.New()
+	e.Use(otelecho.Middleware(cfg.AppName, otelecho.WithSkipper(skipTracing)))
+	e.Use(middleware.RequestLogger(logger))
+	e.Use(middleware.Metrics())
+	e.Use(middleware.CORS(e, cfg.CORS))
+	e.Use(middleware.Language())
 	e.Use(middleware.SetRequestContextWithTimeout(cfg.RedisTTL))
 
 	api := e.Group("/api/v1")
 
-	jwtManager := token.NewJWTManager(cfg.JWTSecretKey)
-
 	addDoc(e)
-	public := api.Group("")
-	authGroup := api.Group("")
+	rl := newRateLimiter(cfg, c, logger)
 
-	m := middleware.NewMiddleware(cfg.JWTSecretKey, logger)
+	// Probes are not rate limited, they come from the orchestrator's few IPs
+	rest.NewHealthHandler(api, checks)
 
-	authGroup.Use(m.JWTAuth())
-	{
+	public := api.Group("", rl.Policy("public"))
+	authGroup := api.Group("")
 
-		userRepo := postgres.NewUserRepository(store.DB, logger)
-		userService := user_service.NewUserService(userRepo, cfg, c, logger, jwtManager)
-		rest.NewUserHandler(public, authGroup, userService, cfg, c, logger)
+	m := middleware.NewMiddleware(cfg.JWTSecretKey, userService, auditRepo, logger)
 
+	authGroup.Use(m.JWTAuth(), rl.Policy("private"))
+	{
+		// Register, login, verify-email and resend-code share the stricter "auth" policy
+		rest.NewUserHandler(public.Group("", rl.Policy("auth")), authGroup, userService, cfg, c, logger)
+		rest.NewExportHandler(public, authGroup, exportService, logger)
 	}
 
+	lc.Go("export-janitor", func(ctx context.Context) error {
+		return exportService.RunJanitor(ctx, time.Hour)
+	})
+
 	e.GET("/api/swagger/*", echoSwagger.WrapHandler)
 
-	api.GET("/health", func(c echo.Context) error {
-		return c.String(http.StatusOK, "✅ OK")
-	})
+	if cfg.MetricsToken != "" {
+		e.GET("/metrics", echo.WrapHandler(metrics.Handler()), middleware.StaticToken(cfg.MetricsToken))
+	} else {
+		logger.Warn().Msg("METRICS_TOKEN is not set, /metrics is disabled")
+	}
 
 	for _, r := range e.Routes() {
-		log.Printf("%s %s", r.Method, r.Path)
+		logger.Debug().Str("method", r.Method).Str("path", r.Path).Msg("route")
 	}
 
-	log.Printf("🚀 %s running on %s", cfg.AppName, cfg.AppPort)
-	e.Logger.Fatal(e.Start(cfg.AppPort))
+	logger.Info().Str("app", cfg.AppName).Str("addr", cfg.AppPort).Msg("Starting HTTP server")
+	lc.Server("http", func() error { return e.Start(cfg.AppPort) }, e.Shutdown)
+
+	if err := lc.Run(ctx); err != nil {
+		logger.Fatal().Err(err).Msg("Application stopped with error")
+	}
 }
 
-func addDoc(e *echo.Echo) {
-	var swaggerUIHTML = `<!DOCTYPE html>
+// newRateLimiter picks the Redis backend when available, so limits hold across replicas
+func newRateLimiter(cfg config.Config, c cache.ICache, logger zerolog.Logger) *middleware.RateLimiter {
+	if !cfg.RateLimitEnabled {
+		return nil
+	}
+
+	policies, err := ratelimit.ParsePolicies(cfg.RateLimitPolicies)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Rate limit config error")
+	}
+
+	var store ratelimit.Store = ratelimit.NewMemoryStore()
+	if client := cache.RedisClient(c); cfg.RateLimitBackend == "redis" && client != nil {
+		store = ratelimit.NewRedisStore(client)
+	}
+	return middleware.NewRateLimiter(store, policies, logger)
+}
+
+// skipTracing keeps probes and scrapes out of the traces
+func skipTracing(c echo.Context) bool {
+	path := c.Request().URL.Path
+	return path == "/metrics" || strings.HasPrefix(path, "/api/v1/health")
+}
+
+// swaggerUIHTML loads Swagger UI from the assets bundled into the binary,
+// so the docs page works without internet access
+const swaggerUIHTML = `<!DOCTYPE html>
 <html>
 <head>
     <title>API Docs</title>
     <meta charset="UTF-8">
-    <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+    <link rel="stylesheet" href="/api/v1/docs/assets/swagger-ui.css">
 </head>
 <body>
     <div id="swagger-ui"></div>
-    <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+    <script src="/api/v1/docs/assets/swagger-ui-bundle.js"></script>
     <script>
         SwaggerUIBundle({
-            url: "/api/v1/dual_education.yaml",
+            url: "/api/v1/swagger.yaml",
             dom_id: '#swagger-ui'
         });
     </script>
 </body>
 </html>`
 
-	e.GET("/api/v1/dual_education.yaml", func(c echo.Context) error {
-		return c.Blob(http.StatusOK, "application/yaml", swaggerFile)
+func addDoc(e *echo.Echo) {
+	e.GET("/api/v1/swagger.yaml", func(c echo.Context) error {
+		return c.Blob(http.StatusOK, "application/yaml", docs.Spec)
 	})
 
 	// Serve Swagger UI
 	e.GET("/api/v1/docs", func(c echo.Context) error {
 		return c.HTML(http.StatusOK, swaggerUIHTML)
 	})
+	e.StaticFS("/api/v1/docs/assets", swaggerFiles.FS)
 }
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/infosec554/clean-archtectura/config"
+	"github.com/infosec554/clean-archtectura/internal/repository/postgres"
+	"github.com/infosec554/clean-archtectura/migrations"
+)
+
+const migrateUsage = `usage: app migrate <command>
+
+  up             apply all pending migrations
+  down N         roll back the last N migrations
+  status         show the applied and pending versions
+  force V        set the version without migrating (-1 for none), clearing the dirty flag
+  create NAME    add an empty up/down pair to migrations/postgres`
+
+// migrationsDir is where "create" writes, relative to the repository root
+const migrationsDir = "migrations/postgres"
+
+var migrationName = regexp.MustCompile(`[^a-z0-9]+`)
+
+// migrator is the part of *postgres.Migrator the commands use
+type migrator interface {
+	Up(ctx context.Context) error
+	Down(ctx context.Context, n int) error
+	Force(ctx context.Context, version int) error
+	Status(ctx context.Context) (postgres.MigrationStatus, error)
+}
+
+// migrateCommand is a parsed "app migrate" command other than create
+type migrateCommand func(ctx context.Context, m migrator, w io.Writer) error
+
+// runMigrate implements "app migrate"; every command but create connects to
+// the database, once the arguments are known to be valid
+func runMigrate(ctx context.Context, cfg config.Config, args []string) error {
+	if len(args) > 0 && args[0] == "create" {
+		if len(args) != 2 {
+			return errors.New(migrateUsage)
+		}
+		return createMigration(migrationsDir, args[1])
+	}
+
+	command, err := parseMigrate(args)
+	if err != nil {
+		return err
+	}
+
+	store, err := postgres.New(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	return command(ctx, store.Migrator(), os.Stdout)
+}
+
+func parseMigrate(args []string) (migrateCommand, error) {
+	if len(args) == 0 {
+		return nil, errors.New(migrateUsage)
+	}
+
+	command, args := args[0], args[1:]
+	switch command {
+	case "up":
+		if len(args) != 0 {
+			return nil, errors.New(migrateUsage)
+		}
+		return func(ctx context.Context, m migrator, _ io.Writer) error {
+			return m.Up(ctx)
+		}, nil
+
+	case "down":
+		n, err := intArg(args)
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context, m migrator, _ io.Writer) error {
+			return m.Down(ctx, n)
+		}, nil
+
+	case "force":
+		v, err := intArg(args)
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context, m migrator, _ io.Writer) error {
+			return m.Force(ctx, v)
+		}, nil
+
+	case "status":
+		if len(args) != 0 {
+			return nil, errors.New(migrateUsage)
+		}
+		return printStatus, nil
+	}
+
+	return nil, errors.New(migrateUsage)
+}
+
+func printStatus(ctx context.Context, m migrator, w io.Writer) error {
+	status, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "version: %d\ndirty:   %t\nlatest:  %d\n", status.Version, status.Dirty, status.Latest)
+	if len(status.Pending) == 0 {
+		fmt.Fprintln(w, "pending: none")
+	}
+	for _, v := range status.Pending {
+		fmt.Fprintf(w, "pending: %04d\n", v)
+	}
+	return nil
+}
+
+func intArg(args []string) (int, error) {
+	if len(args) != 1 {
+		return 0, errors.New(migrateUsage)
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", args[0])
+	}
+	return n, nil
+}
+
+// createMigration writes NNNN_name.up.sql and NNNN_name.down.sql with the next version
+func createMigration(dir, name string) error {
+	name = strings.Trim(migrationName.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return errors.New("migration name must contain letters or digits")
+	}
+
+	latest, err := migrations.Latest(os.DirFS(dir))
+	if err != nil {
+		return fmt.Errorf("read %s: %w", dir, err)
+	}
+
+	base := fmt.Sprintf("%04d_%s", latest+1, name)
+	for _, suffix := range []string{".up.sql", ".down.sql"} {
+		path := filepath.Join(dir, base+suffix)
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			return err
+		}
+		fmt.Println(path)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/infosec554/clean-archtectura/config"
+	"github.com/infosec554/clean-archtectura/internal/repository/postgres"
+)
+
+// fakeMigrator records the calls made to it
+type fakeMigrator struct {
+	calls []string
+}
+
+func (f *fakeMigrator) Up(context.Context) error {
+	f.calls = append(f.calls, "up")
+	return nil
+}
+
+func (f *fakeMigrator) Down(_ context.Context, n int) error {
+	f.calls = append(f.calls, fmt.Sprintf("down %d", n))
+	return nil
+}
+
+func (f *fakeMigrator) Force(_ context.Context, version int) error {
+	f.calls = append(f.calls, fmt.Sprintf("force %d", version))
+	return nil
+}
+
+func (f *fakeMigrator) Status(context.Context) (postgres.MigrationStatus, error) {
+	f.calls = append(f.calls, "status")
+	return postgres.MigrationStatus{Version: 3, Latest: 5, Pending: []uint{4, 5}}, nil
+}
+
+func TestParseMigrate(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string // the call made, "" for a usage error
+	}{
+		{[]string{"up"}, "up"},
+		{[]string{"down", "2"}, "down 2"},
+		{[]string{"force", "7"}, "force 7"},
+		{[]string{"force", "-1"}, "force -1"},
+		{[]string{"status"}, "status"},
+		{nil, ""},
+		{[]string{"up", "1"}, ""},
+		{[]string{"down"}, ""},
+		{[]string{"down", "two"}, ""},
+		{[]string{"down", "1", "2"}, ""},
+		{[]string{"force"}, ""},
+		{[]string{"status", "now"}, ""},
+		{[]string{"redo"}, ""},
+		// create never reaches the database, so runMigrate handles it
+		{[]string{"create", "name"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.args), func(t *testing.T) {
+			command, err := parseMigrate(tt.args)
+			if tt.want == "" {
+				if err == nil {
+					t.Fatal("parseMigrate succeeded, want a usage error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseMigrate = %v", err)
+			}
+
+			m := &fakeMigrator{}
+			if err := command(context.Background(), m, &bytes.Buffer{}); err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(m.calls, []string{tt.want}) {
+				t.Errorf("calls = %v, want [%s]", m.calls, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrintStatus(t *testing.T) {
+	var out bytes.Buffer
+	if err := printStatus(context.Background(), &fakeMigrator{}, &out); err != nil {
+		t.Fatal(err)
+	}
+	want := "version: 3\ndirty:   false\nlatest:  5\npending: 0004\npending: 0005\n"
+	if out.String() != want {
+		t.Errorf("status output = %q, want %q", out.String(), want)
+	}
+}
+
+func TestRunMigrateCreateUsage(t *testing.T) {
+	for _, args := range [][]string{{"create"}, {"create", "a", "b"}} {
+		if err := runMigrate(context.Background(), config.Config{}, args); err == nil || err.Error() != migrateUsage {
+			t.Errorf("runMigrate(%v) = %v, want the usage", args, err)
+		}
+	}
+}
+
+func TestCreateMigration(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"0001_init.up.sql", "0001_init.down.sql", "0002_users.up.sql", "0002_users.down.sql"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := createMigration(dir, "Add Orders-Table!"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"0003_add_orders_table.up.sql", "0003_add_orders_table.down.sql"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+
+	if err := createMigration(dir, "--"); err == nil {
+		t.Error("createMigration accepted a name without letters or digits")
+	}
+}
@@ -2,73 +2,251 @@ package config
 
 import (
 	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/joho/godotenv"
-	"github.com/spf13/cast"
 )
 
 type Config struct {
 	AppName     string
 	AppPort     string
+	AppBaseURL  string
 	Environment string
 
+	// TrustedProxies are the CIDRs of proxies whose X-Forwarded-For is
+	// believed; without any, the client IP is the connection's peer address
+	TrustedProxies []string
+
+	ShutdownTimeout    time.Duration
+	ShutdownDrainDelay time.Duration
+	HealthCheckTimeout time.Duration
+	MetricsToken       string
+
+	TracingExporter    string
+	TracingSampleRatio float64
+
+	RateLimitEnabled  bool
+	RateLimitBackend  string
+	RateLimitPolicies string
+
 	PostgresHost     string
 	PostgresPort     string
 	PostgresUser     string
 	PostgresPassword string
 	PostgresDB       string
 
+	// DBSSLMode is a libpq sslmode: disable, allow, prefer, require,
+	// verify-ca or verify-full; DBSSLRootCert is the CA bundle for the
+	// verify modes
+	DBSSLMode     string
+	DBSSLRootCert string
+
+	// Connection pool
+	DBMaxConns          int
+	DBMinConns          int
+	DBMaxConnLifetime   time.Duration
+	DBMaxConnIdleTime   time.Duration
+	DBHealthCheckPeriod time.Duration
+
+	// DBStatementCacheSize is how many prepared statements each connection
+	// keeps; 0 disables caching, as PgBouncer in transaction mode requires
+	DBStatementCacheSize int
+
+	// DBReplicaHosts are read replicas as host or host:port, sharing the
+	// primary's credentials, database name and pool settings. Replicas that
+	// fail the health check or lag more than DBReplicaMaxLagBytes of WAL
+	// behind the primary stop receiving reads until they recover.
+	DBReplicaHosts       []string
+	DBReplicaMaxLagBytes int
+	DBReplicaCheckPeriod time.Duration
+
+	// DBAutoMigrate applies pending migrations when the server starts;
+	// disable it to run "app migrate up" as a separate deploy step
+	DBAutoMigrate        bool
+	DBMigrateLockTimeout time.Duration
+
+	// DBTxIsolation is the isolation level of transactions started without
+	// explicit options: read_committed, repeatable_read or serializable.
+	// Transactions failing on a serialization conflict or deadlock are run
+	// again up to DBTxMaxRetries times.
+	DBTxIsolation  string
+	DBTxMaxRetries int
+
+	// CacheBackend is redis, or memory for local development and tests
+	CacheBackend string
+
 	RedisHost     string
 	RedisPort     string
 	RedisPassword string
 	RedisDB       int
 	RedisTTL      time.Duration
 
+	// User lookups are cached in process for UserCacheLocalTTL and in
+	// the shared cache for UserCacheTTL; missing users for UserCacheNegativeTTL
+	UserCacheSize        int
+	UserCacheTTL         time.Duration
+	UserCacheLocalTTL    time.Duration
+	UserCacheNegativeTTL time.Duration
+
 	JWTSecretKey      string
 	AccessExpireTime  time.Duration
 	RefreshExpireTime time.Duration
+	ImpersonationTTL  time.Duration
 
-	BrevoAPIKey     string
+	BrevoAPIKey      string
 	BrevoSenderEmail string
 	BrevoSenderName  string
-}
 
-func Load() Config {
-	_ = godotenv.Load(".env")
+	ExportDir string
+	ExportTTL time.Duration
+
+	// WorkerInProcess runs the job worker inside the API server; turn it
+	// off when jobs are handled by "app worker" processes instead.
+	// WorkerQueues lists queue=concurrency pairs; a job may run again if
+	// it does not finish within WorkerLease.
+	WorkerInProcess    bool
+	WorkerQueues       string
+	WorkerPollInterval time.Duration
+	WorkerLease        time.Duration
+
+	// The outbox relay runs wherever the worker does. It is woken by
+	// Postgres notifications and polls every OutboxPollInterval in case
+	// one is missed; delivered events are kept for OutboxRetention. An
+	// event that fails OutboxMaxAttempts deliveries is set aside as dead.
+	OutboxPollInterval time.Duration
+	OutboxBatchSize    int
+	OutboxRetention    time.Duration
+	OutboxMaxAttempts  int
+
+	MinioEndpoint string
+
+	CORS CORSConfig
+}
 
-	cfg := Config{}
+// CORSConfig is the cross-origin policy. Origins are exact values such as
+// https://app.example.com or wildcard subdomains such as https://*.example.com.
+type CORSConfig struct {
+	AllowOrigins     []string
+	AllowMethods     []string
+	AllowHeaders     []string
+	ExposeHeaders    []string
+	MaxAge           time.Duration
+	AllowCredentials bool
+}
 
-	cfg.AppName = cast.ToString(getOrDefault("APP_NAME", "clean-archtectura"))
-	cfg.AppPort = cast.ToString(getOrDefault("APP_PORT", ":8080"))
-	cfg.Environment = cast.ToString(getOrDefault("ENVIRONMENT", "development"))
+// Environments accepted in ENVIRONMENT
+const (
+	EnvDevelopment = "development"
+	EnvTest        = "test"
+	EnvStaging     = "staging"
+	EnvProduction  = "production"
+)
 
-	cfg.PostgresHost = cast.ToString(getOrDefault("DB_HOST", "localhost"))
-	cfg.PostgresPort = cast.ToString(getOrDefault("DB_PORT", "5432"))
-	cfg.PostgresUser = cast.ToString(getOrDefault("DB_USER", "postgres"))
-	cfg.PostgresPassword = cast.ToString(getOrDefault("DB_PASSWORD", "1234"))
-	cfg.PostgresDB = cast.ToString(getOrDefault("DB_NAME", "mydb"))
+// defaultCORSOrigins lets local frontends call the API in development;
+// every other environment must list its origins explicitly
+var defaultCORSOrigins = map[string]string{
+	EnvDevelopment: "http://localhost:3000,http://localhost:5173",
+}
 
-	cfg.RedisHost = cast.ToString(getOrDefault("REDIS_HOST", "localhost"))
-	cfg.RedisPort = cast.ToString(getOrDefault("REDIS_PORT", "6379"))
-	cfg.RedisPassword = cast.ToString(getOrDefault("REDIS_PASSWORD", ""))
-	cfg.RedisDB = cast.ToInt(getOrDefault("REDIS_DB", 0))
-	cfg.RedisTTL = cast.ToDuration(getOrDefault("REDIS_TTL", "10m"))
+// Load builds the configuration from defaults, the optional YAML file named by
+// CONFIG_FILE, the environment (including .env) and <KEY>_FILE secret files,
+// each layer overriding the previous one. The result is validated.
+func Load() (Config, error) {
+	_ = godotenv.Load(".env")
 
-	cfg.JWTSecretKey = cast.ToString(getOrDefault("JWT_SECRET_KEY", "supersecretkey"))
-	cfg.AccessExpireTime = cast.ToDuration(getOrDefault("ACCESS_TOKEN_TTL", "24h"))
-	cfg.RefreshExpireTime = cast.ToDuration(getOrDefault("REFRESH_TOKEN_TTL", "168h"))
+	src, err := newSource(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		return Config{}, err
+	}
 
-	cfg.BrevoAPIKey      = cast.ToString(getOrDefault("BREVO_API_KEY", ""))
-	cfg.BrevoSenderEmail = cast.ToString(getOrDefault("BREVO_SENDER_EMAIL", "noreply@example.com"))
-	cfg.BrevoSenderName  = cast.ToString(getOrDefault("BREVO_SENDER_NAME", "MyApp"))
+	cfg := Config{}
 
-	return cfg
-}
+	cfg.AppName = src.string("APP_NAME", "clean-archtectura")
+	cfg.AppPort = src.string("APP_PORT", ":8080")
+	cfg.AppBaseURL = src.string("APP_BASE_URL", "http://localhost:8080")
+	cfg.Environment = src.string("ENVIRONMENT", EnvDevelopment)
+	cfg.TrustedProxies = src.list("TRUSTED_PROXIES", "")
+	cfg.ShutdownTimeout = src.duration("SHUTDOWN_TIMEOUT", "30s")
+	cfg.ShutdownDrainDelay = src.duration("SHUTDOWN_DRAIN_DELAY", "0s")
+	cfg.HealthCheckTimeout = src.duration("HEALTH_CHECK_TIMEOUT", "2s")
+	cfg.MetricsToken = src.string("METRICS_TOKEN", "")
+
+	cfg.TracingExporter = src.string("TRACING_EXPORTER", "none")
+	cfg.TracingSampleRatio = src.float("TRACING_SAMPLE_RATIO", 1.0)
+
+	cfg.RateLimitEnabled = src.bool("RATE_LIMIT_ENABLED", true)
+	cfg.RateLimitBackend = src.string("RATE_LIMIT_BACKEND", "redis")
+	cfg.RateLimitPolicies = src.string("RATE_LIMIT_POLICIES", "public=60/1m:ip,auth=10/1m:ip,private=120/1m:user")
+
+	cfg.PostgresHost = src.string("DB_HOST", "localhost")
+	cfg.PostgresPort = src.string("DB_PORT", "5432")
+	cfg.PostgresUser = src.string("DB_USER", "postgres")
+	cfg.PostgresPassword = src.string("DB_PASSWORD", "1234")
+	cfg.PostgresDB = src.string("DB_NAME", "mydb")
+	cfg.DBSSLMode = src.string("DB_SSLMODE", "disable")
+	cfg.DBSSLRootCert = src.string("DB_SSLROOTCERT", "")
+	cfg.DBMaxConns = src.int("DB_MAX_CONNS", 20)
+	cfg.DBMinConns = src.int("DB_MIN_CONNS", 2)
+	cfg.DBMaxConnLifetime = src.duration("DB_MAX_CONN_LIFETIME", "1h")
+	cfg.DBMaxConnIdleTime = src.duration("DB_MAX_CONN_IDLE_TIME", "30m")
+	cfg.DBHealthCheckPeriod = src.duration("DB_HEALTH_CHECK_PERIOD", "1m")
+	cfg.DBStatementCacheSize = src.int("DB_STATEMENT_CACHE_SIZE", 512)
+	cfg.DBReplicaHosts = src.list("DB_REPLICA_HOSTS", "")
+	cfg.DBReplicaMaxLagBytes = src.int("DB_REPLICA_MAX_LAG_BYTES", 16<<20)
+	cfg.DBReplicaCheckPeriod = src.duration("DB_REPLICA_CHECK_PERIOD", "5s")
+	cfg.DBAutoMigrate = src.bool("DB_AUTO_MIGRATE", true)
+	cfg.DBMigrateLockTimeout = src.duration("DB_MIGRATE_LOCK_TIMEOUT", "5m")
+	cfg.DBTxIsolation = src.string("DB_TX_ISOLATION", "read_committed")
+	cfg.DBTxMaxRetries = src.int("DB_TX_MAX_RETRIES", 3)
+
+	cfg.CacheBackend = src.string("CACHE_BACKEND", "redis")
+	cfg.RedisHost = src.string("REDIS_HOST", "localhost")
+	cfg.RedisPort = src.string("REDIS_PORT", "6379")
+	cfg.RedisPassword = src.string("REDIS_PASSWORD", "")
+	cfg.RedisDB = src.int("REDIS_DB", 0)
+	cfg.RedisTTL = src.duration("REDIS_TTL", "10m")
+
+	cfg.UserCacheSize = src.int("USER_CACHE_SIZE", 10000)
+	cfg.UserCacheTTL = src.duration("USER_CACHE_TTL", "5m")
+	cfg.UserCacheLocalTTL = src.duration("USER_CACHE_LOCAL_TTL", "30s")
+	cfg.UserCacheNegativeTTL = src.duration("USER_CACHE_NEGATIVE_TTL", "30s")
+
+	cfg.JWTSecretKey = src.string("JWT_SECRET_KEY", "supersecretkey")
+	cfg.AccessExpireTime = src.duration("ACCESS_TOKEN_TTL", "24h")
+	cfg.RefreshExpireTime = src.duration("REFRESH_TOKEN_TTL", "168h")
+	cfg.ImpersonationTTL = src.duration("IMPERSONATION_TOKEN_TTL", "15m")
+
+	cfg.BrevoAPIKey = src.string("BREVO_API_KEY", "")
+	cfg.BrevoSenderEmail = src.string("BREVO_SENDER_EMAIL", "noreply@example.com")
+	cfg.BrevoSenderName = src.string("BREVO_SENDER_NAME", "MyApp")
+
+	cfg.ExportDir = src.string("EXPORT_DIR", filepath.Join(os.TempDir(), "exports"))
+	cfg.ExportTTL = src.duration("EXPORT_TTL", "48h")
+
+	cfg.WorkerInProcess = src.bool("WORKER_IN_PROCESS", true)
+	cfg.WorkerQueues = src.string("WORKER_QUEUES", "default=2,email=5")
+	cfg.WorkerPollInterval = src.duration("WORKER_POLL_INTERVAL", "1s")
+	cfg.WorkerLease = src.duration("WORKER_LEASE", "5m")
+
+	cfg.OutboxPollInterval = src.duration("OUTBOX_POLL_INTERVAL", "5s")
+	cfg.OutboxBatchSize = src.int("OUTBOX_BATCH_SIZE", 100)
+	cfg.OutboxRetention = src.duration("OUTBOX_RETENTION", "168h")
+	cfg.OutboxMaxAttempts = src.int("OUTBOX_MAX_ATTEMPTS", 10)
+
+	cfg.MinioEndpoint = src.string("MINIO_ENDPOINT", "")
+
+	cfg.CORS = CORSConfig{
+		AllowOrigins:     src.list("CORS_ALLOWED_ORIGINS", defaultCORSOrigins[cfg.Environment]),
+		AllowMethods:     src.list("CORS_ALLOWED_METHODS", "GET,POST,PUT,PATCH,DELETE"),
+		AllowHeaders:     src.list("CORS_ALLOWED_HEADERS", "Accept,Accept-Language,Authorization,Content-Type,X-Request-ID,X-API-Key"),
+		ExposeHeaders:    src.list("CORS_EXPOSED_HEADERS", "X-Request-ID,Content-Disposition,RateLimit-Limit,RateLimit-Remaining,RateLimit-Reset,RateLimit-Policy,Retry-After"),
+		MaxAge:           src.duration("CORS_MAX_AGE", "10m"),
+		AllowCredentials: src.bool("CORS_ALLOW_CREDENTIALS", true),
+	}
 
-func getOrDefault(key string, defaultValue any) any {
-	if value := os.Getenv(key); value != "" {
-		return value
+	if err := src.err(); err != nil {
+		return Config{}, err
 	}
-	return defaultValue
+	return cfg, cfg.Validate()
 }
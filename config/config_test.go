@@ -0,0 +1,202 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadLayering(t *testing.T) {
+	file := writeFile(t, "config.yaml", `
+app_name: from-yaml
+DB_PORT: 6543
+DB_HOST: yaml-host
+SHUTDOWN_TIMEOUT: 45s
+CORS_ALLOWED_ORIGINS:
+  - https://a.example.com
+  - https://b.example.com
+`)
+	t.Setenv("CONFIG_FILE", file)
+	t.Setenv("ENVIRONMENT", EnvDevelopment)
+	t.Setenv("DB_PORT", "7654")
+	t.Setenv("DB_PASSWORD", "from-env")
+	t.Setenv("DB_PASSWORD_FILE", writeFile(t, "db_password", "from-file\n"))
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		got  any
+		want any
+	}{
+		{"default", cfg.AppPort, ":8080"},
+		{"yaml over default, keys in any case", cfg.AppName, "from-yaml"},
+		{"yaml", cfg.PostgresHost, "yaml-host"},
+		{"env over yaml", cfg.PostgresPort, "7654"},
+		{"secret file over env, without the newline", cfg.PostgresPassword, "from-file"},
+		{"yaml duration", cfg.ShutdownTimeout, 45 * time.Second},
+		{"yaml sequence", strings.Join(cfg.CORS.AllowOrigins, ","), "https://a.example.com,https://b.example.com"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestLoadReportsEveryInvalidValue(t *testing.T) {
+	t.Setenv("ENVIRONMENT", EnvDevelopment)
+	t.Setenv("SHUTDOWN_TIMEOUT", "soon")
+	t.Setenv("DB_MAX_CONNS", "many")
+	t.Setenv("JWT_SECRET_KEY_FILE", filepath.Join(t.TempDir(), "missing"))
+
+	_, err := Load()
+	if err == nil {
+		t.Fatal("Load succeeded")
+	}
+	for _, key := range []string{"SHUTDOWN_TIMEOUT", "DB_MAX_CONNS", "JWT_SECRET_KEY_FILE"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("error does not mention %s: %v", key, err)
+		}
+	}
+}
+
+func TestLoadMissingConfigFile(t *testing.T) {
+	t.Setenv("CONFIG_FILE", filepath.Join(t.TempDir(), "missing.yaml"))
+	if _, err := Load(); err == nil {
+		t.Error("Load succeeded without the config file")
+	}
+}
+
+// defaults loads the configuration a development machine starts with
+func defaults(t *testing.T) Config {
+	t.Helper()
+	t.Setenv("ENVIRONMENT", EnvDevelopment)
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return cfg
+}
+
+func TestValidate(t *testing.T) {
+	strong := func(c *Config) {
+		c.PostgresPassword = "db-password-from-the-vault"
+		c.JWTSecretKey = strings.Repeat("k", minSecretLength)
+	}
+	production := func(c *Config) {
+		strong(c)
+		c.Environment = EnvProduction
+		c.DBSSLMode = "verify-full"
+		c.BrevoAPIKey = "xkeysib-real"
+		c.AppBaseURL = "https://api.example.com"
+		c.CORS.AllowOrigins = []string{"https://app.example.com"}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		errs   []string // variables the error must name; none means valid
+	}{
+		{"development defaults", func(*Config) {}, nil},
+		{"test with default secrets", func(c *Config) { c.Environment = EnvTest }, []string{"DB_PASSWORD", "JWT_SECRET_KEY"}},
+		{"staging with default secrets", func(c *Config) { c.Environment = EnvStaging }, []string{"DB_PASSWORD", "JWT_SECRET_KEY"}},
+		{"staging with secrets", func(c *Config) { strong(c); c.Environment = EnvStaging }, nil},
+		{"production", production, nil},
+		{"production with a short key", func(c *Config) { production(c); c.JWTSecretKey = "short-but-not-default" }, []string{"JWT_SECRET_KEY"}},
+		{"production over plain http", func(c *Config) {
+			production(c)
+			c.AppBaseURL = "http://api.example.com"
+			c.DBSSLMode = "prefer"
+		}, []string{"APP_BASE_URL", "DB_SSLMODE"}},
+		{"wildcard origin with credentials", func(c *Config) { c.CORS.AllowOrigins = []string{"*"} }, []string{"CORS_ALLOWED_ORIGINS"}},
+		{"wildcard origin without credentials", func(c *Config) {
+			c.CORS.AllowOrigins = []string{"*"}
+			c.CORS.AllowCredentials = false
+		}, nil},
+		{"unknown environment", func(c *Config) { c.Environment = "prod" }, []string{"ENVIRONMENT"}},
+		{"bad proxy", func(c *Config) { c.TrustedProxies = []string{"10.0.0.1"} }, []string{"TRUSTED_PROXIES"}},
+		{"several problems", func(c *Config) {
+			c.DBMaxConns = 0
+			c.TracingSampleRatio = 2
+		}, []string{"DB_MAX_CONNS", "TRACING_SAMPLE_RATIO"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := defaults(t)
+			tt.modify(&cfg)
+
+			err := cfg.Validate()
+			if len(tt.errs) == 0 {
+				if err != nil {
+					t.Errorf("Validate = %v, want valid", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("Validate succeeded")
+			}
+			for _, key := range tt.errs {
+				if !strings.Contains(err.Error(), key+":") {
+					t.Errorf("error does not name %s: %v", key, err)
+				}
+			}
+		})
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := defaults(t)
+	cfg.PostgresPassword = "db-secret"
+	cfg.JWTSecretKey = "jwt-secret"
+	cfg.BrevoAPIKey = "brevo-secret"
+	cfg.RedisPassword = ""
+	cfg.MetricsToken = "metrics-secret"
+
+	r := cfg.Redacted()
+	for name, got := range map[string]string{
+		"DB_PASSWORD":    r.PostgresPassword,
+		"JWT_SECRET_KEY": r.JWTSecretKey,
+		"BREVO_API_KEY":  r.BrevoAPIKey,
+		"METRICS_TOKEN":  r.MetricsToken,
+	} {
+		if got != redacted {
+			t.Errorf("%s = %q, want redacted", name, got)
+		}
+	}
+	if r.RedisPassword != "" {
+		t.Errorf("unset REDIS_PASSWORD = %q, want empty", r.RedisPassword)
+	}
+	if cfg.JWTSecretKey != "jwt-secret" {
+		t.Error("Redacted modified the original")
+	}
+
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"db-secret", "jwt-secret", "brevo-secret", "metrics-secret"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("JSON leaks %q", secret)
+		}
+	}
+	if !slices.Contains(r.CORS.AllowOrigins, "http://localhost:3000") {
+		t.Error("Redacted dropped other settings")
+	}
+}
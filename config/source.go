@@ -0,0 +1,121 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cast"
+	"gopkg.in/yaml.v3"
+)
+
+// source resolves a key through the configuration layers, lowest precedence
+// first: the default passed by the caller, the optional YAML file, the
+// environment, and finally a secret file named by <KEY>_FILE.
+// Conversion failures are collected so Load can report them all at once.
+type source struct {
+	file map[string]string
+	errs []error
+}
+
+// newSource reads the YAML file at path, if any. The file is flat and uses
+// the environment variable names as keys; lists may be written as sequences.
+func newSource(path string) (*source, error) {
+	s := &source{file: make(map[string]string)}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config file: %w", err)
+	}
+
+	var raw map[string]any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	for key, value := range raw {
+		if list, ok := value.([]any); ok {
+			value = strings.Join(cast.ToStringSlice(list), ",")
+		}
+		s.file[strings.ToUpper(key)] = cast.ToString(value)
+	}
+	return s, nil
+}
+
+// lookup returns the highest-precedence value set for key
+func (s *source) lookup(key string) (string, bool) {
+	if path := os.Getenv(key + "_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			s.errs = append(s.errs, fmt.Errorf("%s_FILE: %w", key, err))
+			return "", false
+		}
+		return strings.TrimRight(string(data), "\r\n"), true
+	}
+	if value := os.Getenv(key); value != "" {
+		return value, true
+	}
+	if value, ok := s.file[key]; ok {
+		return value, true
+	}
+	return "", false
+}
+
+func (s *source) string(key, def string) string {
+	if value, ok := s.lookup(key); ok {
+		return value
+	}
+	return def
+}
+
+func (s *source) list(key, def string) []string {
+	return splitList(s.string(key, def))
+}
+
+func (s *source) duration(key, def string) time.Duration {
+	return convert(s, key, def, cast.ToDurationE)
+}
+
+func (s *source) int(key string, def int) int {
+	return convert(s, key, def, cast.ToIntE)
+}
+
+func (s *source) float(key string, def float64) float64 {
+	return convert(s, key, def, cast.ToFloat64E)
+}
+
+func (s *source) bool(key string, def bool) bool {
+	return convert(s, key, def, cast.ToBoolE)
+}
+
+func (s *source) err() error {
+	return errors.Join(s.errs...)
+}
+
+func convert[T any](s *source, key string, def any, fn func(any) (T, error)) T {
+	raw, ok := s.lookup(key)
+	if !ok {
+		value, _ := fn(def)
+		return value
+	}
+	value, err := fn(raw)
+	if err != nil {
+		s.errs = append(s.errs, fmt.Errorf("%s: invalid value %q", key, raw))
+	}
+	return value
+}
+
+// splitList parses a comma-separated value, dropping empty items
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,132 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// minSecretLength is the shortest JWT secret accepted in production; HS256
+// keys shorter than the hash output weaken the signature
+const minSecretLength = 32
+
+// insecureSecrets are the shipped defaults and other well-known placeholders
+var insecureSecrets = []string{"", "1234", "supersecretkey", "secret", "password", "postgres", "changeme", "your-brevo-api-key-here"}
+
+const redacted = "[REDACTED]"
+
+// sslModes are the libpq sslmode values, weakest first; from "require" on
+// the connection is encrypted
+var sslModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+// Validate reports every invalid setting at once. Outside development default
+// secrets are refused; production additionally refuses weak secrets and
+// settings that are only safe locally.
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(slices.Contains([]string{EnvDevelopment, EnvTest, EnvStaging, EnvProduction}, c.Environment),
+		"ENVIRONMENT: unknown environment %q", c.Environment)
+	check(c.AppPort != "", "APP_PORT: must be set")
+	_, err := url.ParseRequestURI(c.AppBaseURL)
+	check(err == nil, "APP_BASE_URL: invalid URL %q", c.AppBaseURL)
+
+	for _, cidr := range c.TrustedProxies {
+		_, _, err := net.ParseCIDR(cidr)
+		check(err == nil, "TRUSTED_PROXIES: invalid CIDR %q", cidr)
+	}
+
+	check(c.ShutdownTimeout > 0, "SHUTDOWN_TIMEOUT: must be positive")
+	check(c.ShutdownDrainDelay >= 0, "SHUTDOWN_DRAIN_DELAY: must not be negative")
+	check(c.HealthCheckTimeout > 0, "HEALTH_CHECK_TIMEOUT: must be positive")
+
+	check(slices.Contains([]string{"none", "stdout", "otlp"}, c.TracingExporter),
+		"TRACING_EXPORTER: unknown exporter %q", c.TracingExporter)
+	check(c.TracingSampleRatio >= 0 && c.TracingSampleRatio <= 1, "TRACING_SAMPLE_RATIO: must be between 0 and 1")
+	check(slices.Contains([]string{"redis", "memory"}, c.RateLimitBackend),
+		"RATE_LIMIT_BACKEND: unknown backend %q", c.RateLimitBackend)
+
+	check(slices.Contains([]string{"redis", "memory"}, c.CacheBackend),
+		"CACHE_BACKEND: unknown backend %q", c.CacheBackend)
+	check(slices.Contains(sslModes, c.DBSSLMode), "DB_SSLMODE: unknown mode %q", c.DBSSLMode)
+	check(c.DBMaxConns > 0, "DB_MAX_CONNS: must be positive")
+	check(c.DBMinConns >= 0 && c.DBMinConns <= c.DBMaxConns, "DB_MIN_CONNS: must be between 0 and DB_MAX_CONNS")
+	check(c.DBMaxConnLifetime > 0, "DB_MAX_CONN_LIFETIME: must be positive")
+	check(c.DBMaxConnIdleTime > 0, "DB_MAX_CONN_IDLE_TIME: must be positive")
+	check(c.DBHealthCheckPeriod > 0, "DB_HEALTH_CHECK_PERIOD: must be positive")
+	check(c.DBStatementCacheSize >= 0, "DB_STATEMENT_CACHE_SIZE: must not be negative")
+	check(c.DBReplicaMaxLagBytes > 0, "DB_REPLICA_MAX_LAG_BYTES: must be positive")
+	check(c.DBReplicaCheckPeriod > 0, "DB_REPLICA_CHECK_PERIOD: must be positive")
+	check(c.DBMigrateLockTimeout > 0, "DB_MIGRATE_LOCK_TIMEOUT: must be positive")
+	check(slices.Contains([]string{"read_committed", "repeatable_read", "serializable"}, c.DBTxIsolation),
+		"DB_TX_ISOLATION: unknown isolation level %q", c.DBTxIsolation)
+	check(c.DBTxMaxRetries >= 0, "DB_TX_MAX_RETRIES: must not be negative")
+	check(c.RedisDB >= 0, "REDIS_DB: must not be negative")
+	check(c.RedisTTL > 0, "REDIS_TTL: must be positive")
+	check(c.UserCacheSize > 0, "USER_CACHE_SIZE: must be positive")
+	check(c.UserCacheTTL > 0, "USER_CACHE_TTL: must be positive")
+	check(c.UserCacheLocalTTL > 0, "USER_CACHE_LOCAL_TTL: must be positive")
+	check(c.UserCacheNegativeTTL > 0, "USER_CACHE_NEGATIVE_TTL: must be positive")
+	check(c.AccessExpireTime > 0, "ACCESS_TOKEN_TTL: must be positive")
+	check(c.RefreshExpireTime > 0, "REFRESH_TOKEN_TTL: must be positive")
+	check(c.ImpersonationTTL > 0, "IMPERSONATION_TOKEN_TTL: must be positive")
+	check(c.ExportTTL > 0, "EXPORT_TTL: must be positive")
+	check(c.WorkerPollInterval > 0, "WORKER_POLL_INTERVAL: must be positive")
+	check(c.WorkerLease > 0, "WORKER_LEASE: must be positive")
+	check(c.OutboxPollInterval > 0, "OUTBOX_POLL_INTERVAL: must be positive")
+	check(c.OutboxBatchSize > 0, "OUTBOX_BATCH_SIZE: must be positive")
+	check(c.OutboxRetention > 0, "OUTBOX_RETENTION: must be positive")
+	check(c.OutboxMaxAttempts > 0, "OUTBOX_MAX_ATTEMPTS: must be positive")
+	check(!c.CORS.AllowCredentials || !slices.Contains(c.CORS.AllowOrigins, "*"),
+		"CORS_ALLOWED_ORIGINS: \"*\" cannot be combined with CORS_ALLOW_CREDENTIALS, list the origins instead")
+
+	// Shared test and staging environments are reachable too; only a
+	// developer's machine may run on the shipped defaults
+	if c.Environment != EnvDevelopment {
+		check(!insecure(c.PostgresPassword), "DB_PASSWORD: default or insecure value is only allowed in development")
+		check(!insecure(c.JWTSecretKey), "JWT_SECRET_KEY: default or insecure value is only allowed in development")
+	}
+
+	if c.Environment == EnvProduction {
+		check(slices.Contains(sslModes[3:], c.DBSSLMode), "DB_SSLMODE: %q does not require TLS and is not allowed in production", c.DBSSLMode)
+		check(len(c.JWTSecretKey) >= minSecretLength, "JWT_SECRET_KEY: must be at least %d characters in production", minSecretLength)
+		check(!insecure(c.BrevoAPIKey), "BREVO_API_KEY: must be set in production")
+		check(c.MetricsToken == "" || len(c.MetricsToken) >= 16, "METRICS_TOKEN: must be at least 16 characters in production")
+		check(strings.HasPrefix(c.AppBaseURL, "https://"), "APP_BASE_URL: must use https in production")
+		check(c.CacheBackend != "memory", "CACHE_BACKEND: memory is not shared between replicas and is not allowed in production")
+		check(!slices.Contains(c.CORS.AllowOrigins, "*"), "CORS_ALLOWED_ORIGINS: \"*\" is not allowed in production")
+	}
+
+	return errors.Join(errs...)
+}
+
+func insecure(secret string) bool {
+	return slices.Contains(insecureSecrets, strings.ToLower(secret))
+}
+
+// Redacted returns a copy that is safe to log: secrets that are set are
+// replaced by a placeholder, so it still shows which ones are configured.
+func (c Config) Redacted() Config {
+	for _, secret := range []*string{&c.PostgresPassword, &c.RedisPassword, &c.JWTSecretKey, &c.BrevoAPIKey, &c.MetricsToken} {
+		if *secret != "" {
+			*secret = redacted
+		}
+	}
+	return c
+}
+
+// MarshalJSON encodes the redacted configuration, so logging a Config
+// never leaks secrets
+func (c Config) MarshalJSON() ([]byte, error) {
+	type plain Config
+	return json.Marshal(plain(c.Redacted()))
+}
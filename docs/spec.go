@@ -0,0 +1,11 @@
+package docs
+
+import _ "embed"
+
+// Spec is the generated OpenAPI document, served at /api/v1/swagger.yaml.
+// It is generated from the handler annotations only, and the tests in
+// internal/rest fail when it documents a route the server does not have.
+// Regenerate it with: swag init -g main.go -d app,internal/rest,domain,pkg/health -o docs
+//
+//go:embed swagger.yaml
+var Spec []byte
@@ -11,6 +11,104 @@ var (
 	ErrConflict = errors.New("your Item already exist")
 	// ErrBadParamInput will throw if the given request-body or params is not valid
 	ErrBadParamInput = errors.New("given Param is not valid")
+	// ErrValidation will throw if the request is well-formed but its values are not acceptable
+	ErrValidation = errors.New("validation failed")
+	// ErrUnauthorized will throw if the caller is not authenticated
+	ErrUnauthorized = errors.New("authentication required")
 	// ErrForbidden will throw if the user does not have permission
 	ErrForbidden = errors.New("you do not have permission to perform this action")
+	// ErrGone will throw if the requested item existed but is no longer available
+	ErrGone = errors.New("your requested Item is no longer available")
+	// ErrTooManyRequests will throw if the caller exceeded a rate limit
+	ErrTooManyRequests = errors.New("too many requests")
 )
+
+// FieldError describes why a single request field was rejected
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule,omitempty"`
+	Message string `json:"message"`
+}
+
+// Error is a typed domain error. Kind is one of the sentinel errors above, so
+// callers can use errors.Is(err, domain.ErrNotFound). Message is safe to show
+// to clients, while Err keeps the underlying cause for logs only.
+type Error struct {
+	Kind    error
+	Message string
+	Fields  []FieldError
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() []error {
+	if e.Err != nil {
+		return []error{e.Kind, e.Err}
+	}
+	return []error{e.Kind}
+}
+
+// NotFound returns an ErrNotFound error with a client-safe message
+func NotFound(msg string) error {
+	return &Error{Kind: ErrNotFound, Message: msg}
+}
+
+// Conflict returns an ErrConflict error, optionally naming the conflicting fields
+func Conflict(msg string, fields ...FieldError) error {
+	return &Error{Kind: ErrConflict, Message: msg, Fields: fields}
+}
+
+// BadRequest returns an ErrBadParamInput error for malformed requests
+func BadRequest(msg string) error {
+	return &Error{Kind: ErrBadParamInput, Message: msg}
+}
+
+// Validation returns an ErrValidation error with per-field details
+func Validation(msg string, fields ...FieldError) error {
+	return &Error{Kind: ErrValidation, Message: msg, Fields: fields}
+}
+
+// Unauthorized returns an ErrUnauthorized error
+func Unauthorized(msg string) error {
+	return &Error{Kind: ErrUnauthorized, Message: msg}
+}
+
+// Forbidden returns an ErrForbidden error
+func Forbidden(msg string) error {
+	return &Error{Kind: ErrForbidden, Message: msg}
+}
+
+// Gone returns an ErrGone error
+func Gone(msg string) error {
+	return &Error{Kind: ErrGone, Message: msg}
+}
+
+// TooManyRequests returns an ErrTooManyRequests error
+func TooManyRequests(msg string) error {
+	return &Error{Kind: ErrTooManyRequests, Message: msg}
+}
+
+// Wrap attaches a kind and a client-safe message to an underlying error
+func Wrap(kind error, msg string, err error) error {
+	return &Error{Kind: kind, Message: msg, Err: err}
+}
+
+// Internal wraps an unexpected error; its details are never shown to clients
+func Internal(err error) error {
+	return &Error{Kind: ErrInternalServerError, Message: "internal server error", Err: err}
+}
+
+// AsError returns the typed domain error in err's chain, if any
+func AsError(err error) (*Error, bool) {
+	var e *Error
+	if errors.As(err, &e) {
+		return e, true
+	}
+	return nil, false
+}
@@ -0,0 +1,136 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Job statuses. Completed jobs are deleted, so a job is either waiting to
+// run, running, or dead after exhausting its attempts.
+const (
+	StatusAvailable = "available"
+	StatusRunning   = "running"
+	StatusDead      = "dead"
+)
+
+const (
+	DefaultQueue       = "default"
+	DefaultMaxAttempts = 5
+)
+
+// ErrLeaseLost is returned when recording a job's result finds the job no
+// longer held by that attempt: its lease ran out and another worker claimed
+// it, or it was already finished
+var ErrLeaseLost = errors.New("job lease lost")
+
+// Job is a unit of background work claimed by a worker
+type Job struct {
+	ID          uuid.UUID       `json:"id" db:"id"`
+	Queue       string          `json:"queue" db:"queue"`
+	Kind        string          `json:"kind" db:"kind"`
+	Payload     json.RawMessage `json:"payload" db:"payload"`
+	Status      string          `json:"status" db:"status"`
+	Attempt     int             `json:"attempt" db:"attempt"`
+	MaxAttempts int             `json:"max_attempts" db:"max_attempts"`
+	UniqueKey   string          `json:"unique_key,omitempty" db:"unique_key"`
+	RunAt       time.Time       `json:"run_at" db:"run_at"`
+	LastError   string          `json:"last_error,omitempty" db:"last_error"`
+	CreatedAt   time.Time       `json:"created_at" db:"created_at"`
+}
+
+// NewJob is a job to enqueue
+type NewJob struct {
+	Queue       string
+	Kind        string
+	Payload     json.RawMessage
+	MaxAttempts int
+
+	// UniqueKey, when set, skips the job while another one with the same
+	// key is waiting or running
+	UniqueKey string
+
+	// RunAt delays the job; zero means as soon as possible
+	RunAt time.Time
+}
+
+// Kind ties a job name to its payload type, so the code that enqueues a job
+// and the handler that runs it agree on the payload
+type Kind[T any] struct {
+	Name        string
+	Queue       string
+	MaxAttempts int
+}
+
+// Option adjusts a job built by Kind.New
+type Option func(*NewJob)
+
+// Delay runs the job no earlier than d from now
+func Delay(d time.Duration) Option {
+	return func(j *NewJob) { j.RunAt = time.Now().Add(d) }
+}
+
+// At runs the job no earlier than t
+func At(t time.Time) Option {
+	return func(j *NewJob) { j.RunAt = t }
+}
+
+// Unique skips the job while another one with the same key is pending
+func Unique(key string) Option {
+	return func(j *NewJob) { j.UniqueKey = key }
+}
+
+// New builds a job of this kind carrying payload
+func (k Kind[T]) New(payload T, opts ...Option) (NewJob, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return NewJob{}, err
+	}
+
+	job := NewJob{
+		Queue:       k.Queue,
+		Kind:        k.Name,
+		Payload:     data,
+		MaxAttempts: k.MaxAttempts,
+	}
+	if job.Queue == "" {
+		job.Queue = DefaultQueue
+	}
+	if job.MaxAttempts <= 0 {
+		job.MaxAttempts = DefaultMaxAttempts
+	}
+	for _, opt := range opts {
+		opt(&job)
+	}
+	return job, nil
+}
+
+// Decode unmarshals a job payload of this kind
+func (k Kind[T]) Decode(payload []byte) (T, error) {
+	var v T
+	err := json.Unmarshal(payload, &v)
+	return v, err
+}
+
+// permanentError marks a failure that retrying cannot fix
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps a handler error so the job goes to the dead letters right
+// away instead of being retried
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err: err}
+}
+
+// IsPermanent reports whether err was wrapped by Permanent
+func IsPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
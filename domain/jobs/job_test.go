@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestKindNew(t *testing.T) {
+	type payload struct {
+		To string `json:"to"`
+	}
+
+	plain := Kind[payload]{Name: "test.plain"}
+	job, err := plain.New(payload{To: "a@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Queue != DefaultQueue || job.MaxAttempts != DefaultMaxAttempts {
+		t.Fatalf("defaults = %q, %d", job.Queue, job.MaxAttempts)
+	}
+	if job.UniqueKey != "" || !job.RunAt.IsZero() {
+		t.Fatalf("job without options = %+v", job)
+	}
+	if got, err := plain.Decode(job.Payload); err != nil || got.To != "a@example.com" {
+		t.Fatalf("Decode = %+v, %v", got, err)
+	}
+
+	at := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	email := Kind[payload]{Name: "test.email", Queue: "email", MaxAttempts: 8}
+	job, err = email.New(payload{}, Unique("send:a@example.com"), At(at))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Queue != "email" || job.MaxAttempts != 8 || job.Kind != "test.email" {
+		t.Fatalf("job = %+v", job)
+	}
+	if job.UniqueKey != "send:a@example.com" || !job.RunAt.Equal(at) {
+		t.Fatalf("options not applied: %+v", job)
+	}
+
+	job, _ = email.New(payload{}, Delay(time.Hour))
+	if d := time.Until(job.RunAt); d < 59*time.Minute || d > time.Hour {
+		t.Fatalf("Delay(1h) runs in %v", d)
+	}
+}
+
+func TestPermanent(t *testing.T) {
+	base := errors.New("address rejected")
+
+	if Permanent(nil) != nil {
+		t.Fatal("Permanent(nil) != nil")
+	}
+	if IsPermanent(base) {
+		t.Fatal("plain error reported as permanent")
+	}
+
+	err := fmt.Errorf("send: %w", Permanent(base))
+	if !IsPermanent(err) {
+		t.Fatal("wrapped permanent error not recognized")
+	}
+	if !errors.Is(err, base) || err.Error() != "send: address rejected" {
+		t.Fatalf("permanent error hides its cause: %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event is an outbox entry: a fact about a domain change, written in the
+// same transaction as the change and delivered to its subscribers after it
+// commits
+type Event struct {
+	ID        uuid.UUID       `json:"id" db:"id"`
+	Topic     string          `json:"topic" db:"topic"`
+	Payload   json.RawMessage `json:"payload" db:"payload"`
+	Attempts  int             `json:"attempts" db:"attempts"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+}
+
+// NewEvent is an event to write to the outbox
+type NewEvent struct {
+	Topic   string
+	Payload json.RawMessage
+}
+
+// Topic ties an event name to its payload type, so the code that publishes
+// an event and its subscribers agree on the payload
+type Topic[T any] struct {
+	Name string
+}
+
+// New builds an event of this topic carrying payload
+func (t Topic[T]) New(payload T) (NewEvent, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return NewEvent{}, err
+	}
+	return NewEvent{Topic: t.Name, Payload: data}, nil
+}
+
+// Decode unmarshals an event payload of this topic
+func (t Topic[T]) Decode(payload []byte) (T, error) {
+	var v T
+	err := json.Unmarshal(payload, &v)
+	return v, err
+}
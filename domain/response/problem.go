@@ -0,0 +1,16 @@
+package response
+
+import "github.com/infosec554/clean-archtectura/domain"
+
+// ContentTypeProblem is the media type of RFC 7807 error responses
+const ContentTypeProblem = "application/problem+json"
+
+// Problem is an RFC 7807 problem details document
+type Problem struct {
+	Type     string              `json:"type"`
+	Title    string              `json:"title"`
+	Status   int                 `json:"status"`
+	Detail   string              `json:"detail,omitempty"`
+	Instance string              `json:"instance,omitempty"`
+	Errors   []domain.FieldError `json:"errors,omitempty"`
+}
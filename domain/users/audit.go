@@ -0,0 +1,52 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	errs "github.com/infosec554/clean-archtectura/domain"
+)
+
+const (
+	// AuditActionImpersonationStart is recorded when an admin obtains an impersonation token
+	AuditActionImpersonationStart = "impersonation.start"
+	// AuditActionImpersonatedRequest is recorded for every request made with an impersonation token
+	AuditActionImpersonatedRequest = "impersonation.request"
+)
+
+// ErrImpersonationForbidden is returned when an admin tries to impersonate another privileged account
+var ErrImpersonationForbidden = errs.Forbidden("privileged accounts cannot be impersonated")
+
+// ErrImpersonatedAction is returned for account takeover actions, such as
+// changing the password, attempted with an impersonation token
+var ErrImpersonatedAction = errs.Forbidden("this action is not allowed while impersonating a user")
+
+// AuditEntry is a single record of the audit trail
+type AuditEntry struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	ActorID    uuid.UUID  `json:"actor_id" db:"actor_id"`
+	SubjectID  *uuid.UUID `json:"subject_id,omitempty" db:"subject_id"`
+	Action     string     `json:"action" db:"action"`
+	Reason     string     `json:"reason,omitempty" db:"reason"`
+	Method     string     `json:"method,omitempty" db:"method"`
+	Path       string     `json:"path,omitempty" db:"path"`
+	StatusCode int        `json:"status_code,omitempty" db:"status_code"`
+	IP         string     `json:"ip,omitempty" db:"ip"`
+	UserAgent  string     `json:"user_agent,omitempty" db:"user_agent"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
+
+// ImpersonateRequest — why the admin needs to act as the user
+type ImpersonateRequest struct {
+	Reason    string `json:"reason" validate:"required,max=500"`
+	IP        string `json:"-"`
+	UserAgent string `json:"-"`
+}
+
+// ImpersonationResponse a short-lived access token acting as the target user
+type ImpersonationResponse struct {
+	User        UserResponse `json:"user"`
+	AccessToken string       `json:"access_token"`
+	ExpiresAt   time.Time    `json:"expires_at"`
+}
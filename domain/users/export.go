@@ -0,0 +1,40 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ExportStatus is the lifecycle state of a personal data export
+type ExportStatus string
+
+const (
+	ExportStatusPending    ExportStatus = "pending"
+	ExportStatusProcessing ExportStatus = "processing"
+	ExportStatusReady      ExportStatus = "ready"
+	ExportStatusFailed     ExportStatus = "failed"
+	ExportStatusExpired    ExportStatus = "expired"
+)
+
+// DataExport represents a single "export my data" job
+type DataExport struct {
+	ID        uuid.UUID    `json:"id" db:"id"`
+	UserID    uuid.UUID    `json:"user_id" db:"user_id"`
+	Status    ExportStatus `json:"status" db:"status"`
+	FilePath  string       `json:"-" db:"file_path"`
+	TokenHash string       `json:"-" db:"token_hash"`
+	Error     string       `json:"error,omitempty" db:"error"`
+	ExpiresAt *time.Time   `json:"expires_at,omitempty" db:"expires_at"`
+	CreatedAt time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at" db:"updated_at"`
+}
+
+// DataExportResponse for returning export job state
+type DataExportResponse struct {
+	ID        uuid.UUID    `json:"id"`
+	Status    ExportStatus `json:"status"`
+	ExpiresAt *time.Time   `json:"expires_at,omitempty"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
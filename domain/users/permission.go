@@ -0,0 +1,39 @@
+package domain
+
+// Role of a user account
+type Role string
+
+const (
+	RoleUser  Role = "user"
+	RoleAdmin Role = "admin"
+)
+
+// Permission grants access to a protected action
+type Permission string
+
+const (
+	// PermManageUserStatus allows suspending, unsuspending and banning accounts
+	PermManageUserStatus Permission = "users:status"
+	// PermSearchUsers allows searching all accounts
+	PermSearchUsers Permission = "users:search"
+	// PermImpersonate allows acting as a regular user for support purposes
+	PermImpersonate Permission = "users:impersonate"
+)
+
+var rolePermissions = map[Role][]Permission{
+	RoleAdmin: {
+		PermManageUserStatus,
+		PermSearchUsers,
+		PermImpersonate,
+	},
+}
+
+// Has reports whether the role is granted the permission
+func (r Role) Has(p Permission) bool {
+	for _, granted := range rolePermissions[r] {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,150 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+
+	errs "github.com/infosec554/clean-archtectura/domain"
+)
+
+// Locale is a supported UI and email language
+type Locale string
+
+const (
+	LocaleUz     Locale = "uz" // Latin script
+	LocaleUzCyrl Locale = "uz-Cyrl"
+	LocaleRu     Locale = "ru"
+	LocaleEn     Locale = "en"
+)
+
+const (
+	DefaultLocale   = LocaleUz
+	DefaultTimezone = "Asia/Tashkent"
+)
+
+// SupportedLocales lists the languages a user can choose
+var SupportedLocales = []Locale{LocaleUz, LocaleUzCyrl, LocaleRu, LocaleEn}
+
+// IsSupported reports whether the locale can be selected
+func (l Locale) IsSupported() bool {
+	for _, s := range SupportedLocales {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+// NotificationChannel a delivery channel a user can opt in or out of
+type NotificationChannel string
+
+const (
+	ChannelEmail NotificationChannel = "email"
+	ChannelSMS   NotificationChannel = "sms"
+	ChannelPush  NotificationChannel = "push"
+)
+
+// NotificationSettings per-channel opt-ins
+type NotificationSettings struct {
+	Email bool `json:"email"`
+	SMS   bool `json:"sms"`
+	Push  bool `json:"push"`
+}
+
+// Preferences per-user settings, stored as a JSONB document
+type Preferences struct {
+	Locale        Locale               `json:"locale"`
+	Timezone      string               `json:"timezone"`
+	Notifications NotificationSettings `json:"notifications"`
+}
+
+// DefaultPreferences are applied to every key a user has not set
+func DefaultPreferences() Preferences {
+	return Preferences{
+		Locale:   DefaultLocale,
+		Timezone: DefaultTimezone,
+		Notifications: NotificationSettings{
+			Email: true,
+			SMS:   false,
+			Push:  true,
+		},
+	}
+}
+
+// Validate checks the locale and the IANA timezone name
+func (p Preferences) Validate() error {
+	var fields []errs.FieldError
+	if !p.Locale.IsSupported() {
+		fields = append(fields, errs.FieldError{
+			Field: "locale", Rule: "oneof", Message: fmt.Sprintf("unsupported locale %q", p.Locale),
+		})
+	}
+	if _, err := time.LoadLocation(p.Timezone); err != nil || p.Timezone == "" || p.Timezone == "Local" {
+		fields = append(fields, errs.FieldError{
+			Field: "timezone", Rule: "timezone", Message: fmt.Sprintf("unknown timezone %q", p.Timezone),
+		})
+	}
+	if len(fields) > 0 {
+		return errs.Validation("invalid preferences", fields...)
+	}
+	return nil
+}
+
+// Location returns the user's timezone, falling back to the default one
+func (p Preferences) Location() *time.Location {
+	for _, name := range []string{p.Timezone, DefaultTimezone} {
+		if loc, err := time.LoadLocation(name); err == nil && name != "" {
+			return loc
+		}
+	}
+	return time.UTC
+}
+
+// Allows reports whether the user opted in to non-essential messages on the channel
+func (p Preferences) Allows(ch NotificationChannel) bool {
+	switch ch {
+	case ChannelEmail:
+		return p.Notifications.Email
+	case ChannelSMS:
+		return p.Notifications.SMS
+	case ChannelPush:
+		return p.Notifications.Push
+	}
+	return false
+}
+
+// UpdatePreferencesRequest partial update, only the given fields change
+type UpdatePreferencesRequest struct {
+	Locale        *Locale                     `json:"locale,omitempty" validate:"omitempty,oneof=uz uz-Cyrl ru en"`
+	Timezone      *string                     `json:"timezone,omitempty" validate:"omitempty,timezone"`
+	Notifications *UpdateNotificationSettings `json:"notifications,omitempty"`
+}
+
+// UpdateNotificationSettings partial update of the channel opt-ins
+type UpdateNotificationSettings struct {
+	Email *bool `json:"email,omitempty"`
+	SMS   *bool `json:"sms,omitempty"`
+	Push  *bool `json:"push,omitempty"`
+}
+
+// Apply returns a copy of p with the requested changes
+func (p Preferences) Apply(req UpdatePreferencesRequest) Preferences {
+	if req.Locale != nil {
+		p.Locale = *req.Locale
+	}
+	if req.Timezone != nil {
+		p.Timezone = *req.Timezone
+	}
+	if n := req.Notifications; n != nil {
+		if n.Email != nil {
+			p.Notifications.Email = *n.Email
+		}
+		if n.SMS != nil {
+			p.Notifications.SMS = *n.SMS
+		}
+		if n.Push != nil {
+			p.Notifications.Push = *n.Push
+		}
+	}
+	return p
+}
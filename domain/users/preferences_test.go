@@ -0,0 +1,80 @@
+package domain
+
+import "testing"
+
+func TestAllows(t *testing.T) {
+	prefs := Preferences{Notifications: NotificationSettings{Email: false, SMS: true, Push: false}}
+
+	tests := []struct {
+		ch   NotificationChannel
+		want bool
+	}{
+		{ChannelEmail, false},
+		{ChannelSMS, true},
+		{ChannelPush, false},
+		{"pigeon", false},
+	}
+	for _, tt := range tests {
+		if got := prefs.Allows(tt.ch); got != tt.want {
+			t.Errorf("Allows(%s) = %t, want %t", tt.ch, got, tt.want)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	ru := LocaleRu
+	tz := "Europe/Moscow"
+	off := false
+
+	tests := []struct {
+		name string
+		req  UpdatePreferencesRequest
+		want Preferences
+	}{
+		{"empty", UpdatePreferencesRequest{}, DefaultPreferences()},
+		{
+			"locale and timezone",
+			UpdatePreferencesRequest{Locale: &ru, Timezone: &tz},
+			Preferences{Locale: LocaleRu, Timezone: tz, Notifications: DefaultPreferences().Notifications},
+		},
+		{
+			"one channel keeps the others",
+			UpdatePreferencesRequest{Notifications: &UpdateNotificationSettings{Email: &off}},
+			Preferences{Locale: DefaultLocale, Timezone: DefaultTimezone, Notifications: NotificationSettings{Email: false, SMS: false, Push: true}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DefaultPreferences().Apply(tt.req); got != tt.want {
+				t.Errorf("Apply = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPreferencesValidate(t *testing.T) {
+	empty := ""
+	bad := "Mars/Olympus"
+	local := "Local"
+	fr := Locale("fr")
+
+	tests := []struct {
+		name  string
+		req   UpdatePreferencesRequest
+		valid bool
+	}{
+		{"defaults", UpdatePreferencesRequest{}, true},
+		{"empty timezone", UpdatePreferencesRequest{Timezone: &empty}, false},
+		{"unknown timezone", UpdatePreferencesRequest{Timezone: &bad}, false},
+		{"server timezone", UpdatePreferencesRequest{Timezone: &local}, false},
+		{"unsupported locale", UpdatePreferencesRequest{Locale: &fr}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := DefaultPreferences().Apply(tt.req).Validate()
+			if (err == nil) != tt.valid {
+				t.Errorf("Validate = %v, want valid %t", err, tt.valid)
+			}
+		})
+	}
+}
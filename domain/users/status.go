@@ -0,0 +1,86 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	errs "github.com/infosec554/clean-archtectura/domain"
+)
+
+// AccountStatus is the lifecycle state of a user account
+type AccountStatus string
+
+const (
+	AccountStatusActive    AccountStatus = "active"
+	AccountStatusSuspended AccountStatus = "suspended"
+	AccountStatusBanned    AccountStatus = "banned"
+	AccountStatusLocked    AccountStatus = "locked"
+)
+
+var (
+	// ErrAccountSuspended is returned when a suspended account tries to sign in
+	ErrAccountSuspended = errs.Forbidden("account is suspended")
+	// ErrAccountBanned is returned when a banned account tries to sign in
+	ErrAccountBanned = errs.Forbidden("account is banned")
+	// ErrAccountLocked is returned when a locked account tries to sign in
+	ErrAccountLocked = errs.Forbidden("account is locked")
+	// ErrInvalidStatusTransition is returned when the requested status change is not allowed
+	ErrInvalidStatusTransition = errs.Conflict("invalid account status transition")
+)
+
+// Err returns the sign-in error for a non-active status, or nil
+func (s AccountStatus) Err() error {
+	switch s {
+	case AccountStatusSuspended:
+		return ErrAccountSuspended
+	case AccountStatusBanned:
+		return ErrAccountBanned
+	case AccountStatusLocked:
+		return ErrAccountLocked
+	}
+	return nil
+}
+
+// EffectiveStatus treats a suspension whose end date has passed as active
+func (u User) EffectiveStatus(now time.Time) AccountStatus {
+	if u.Status == "" {
+		return AccountStatusActive
+	}
+	if u.Status == AccountStatusSuspended && u.SuspendedUntil != nil && now.After(*u.SuspendedUntil) {
+		return AccountStatusActive
+	}
+	return u.Status
+}
+
+// AccountState is what authorization needs to know about an account now,
+// as opposed to when its token was issued
+type AccountState struct {
+	Status AccountStatus `json:"status"`
+	Role   Role          `json:"role"`
+}
+
+// StatusChange is a status transition performed by an admin
+type StatusChange struct {
+	UserID         uuid.UUID
+	Status         AccountStatus
+	Reason         string
+	ChangedBy      uuid.UUID
+	SuspendedUntil *time.Time
+}
+
+// SuspendRequest — reason and optional end of the suspension
+type SuspendRequest struct {
+	Reason string     `json:"reason" validate:"required,max=500"`
+	Until  *time.Time `json:"until,omitempty"`
+}
+
+// BanRequest — reason of the ban
+type BanRequest struct {
+	Reason string `json:"reason" validate:"required,max=500"`
+}
+
+// UnsuspendRequest — optional note
+type UnsuspendRequest struct {
+	Reason string `json:"reason,omitempty" validate:"max=500"`
+}
@@ -14,8 +14,18 @@ type User struct {
 	Email         *string   `json:"email,omitempty" db:"email"`
 	Password      *string   `json:"-" db:"password"`
 	EmailVerified bool      `json:"email_verified" db:"email_verified"`
-	CreatedAt     time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at" db:"updated_at"`
+	Role          Role      `json:"role" db:"role"`
+
+	Status          AccountStatus `json:"status" db:"status"`
+	StatusReason    string        `json:"status_reason,omitempty" db:"status_reason"`
+	StatusChangedBy *uuid.UUID    `json:"status_changed_by,omitempty" db:"status_changed_by"`
+	StatusChangedAt *time.Time    `json:"status_changed_at,omitempty" db:"status_changed_at"`
+	SuspendedUntil  *time.Time    `json:"suspended_until,omitempty" db:"suspended_until"`
+
+	Preferences Preferences `json:"preferences" db:"preferences"`
+
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
 }
 
 // CreateUser request for creating a new user
@@ -23,16 +33,16 @@ type CreateUser struct {
 	FirstName string `json:"first_name" validate:"required,min=2,max=100"`
 	LastName  string `json:"last_name" validate:"required,min=2,max=100"`
 	Email     string `json:"email,omitempty" validate:"omitempty,email"`
-	Password  string `json:"password,omitempty" validate:"omitempty,min=6,max=50"`
+	Password  string `json:"password,omitempty" validate:"omitempty,strongpassword,max=50"`
 }
 
 // UpdateUser request for updating a user
 type UpdateUser struct {
 	ID        uuid.UUID `json:"-" validate:"required"`
-	FirstName string    `json:"first_name,omitempty"`
-	LastName  string    `json:"last_name,omitempty"`
+	FirstName string    `json:"first_name,omitempty" validate:"omitempty,min=2,max=100"`
+	LastName  string    `json:"last_name,omitempty" validate:"omitempty,min=2,max=100"`
 	Email     string    `json:"email,omitempty" validate:"omitempty,email"`
-	Password  string    `json:"password,omitempty" validate:"omitempty,min=6,max=50"`
+	Password  string    `json:"password,omitempty" validate:"omitempty,strongpassword,max=50"`
 }
 
 // UserResponse for returning user data
@@ -42,17 +52,24 @@ type UserResponse struct {
 	LastName      string    `json:"last_name"`
 	Email         string    `json:"email,omitempty"`
 	EmailVerified bool      `json:"email_verified"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
+	Role          Role      `json:"role"`
+
+	Status          AccountStatus `json:"status"`
+	StatusReason    string        `json:"status_reason,omitempty"`
+	StatusChangedAt *time.Time    `json:"status_changed_at,omitempty"`
+	SuspendedUntil  *time.Time    `json:"suspended_until,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
-// VerifyEmailRequest — email + code
+// VerifyEmailRequest — email and the code sent to it
 type VerifyEmailRequest struct {
 	Email string `json:"email" validate:"required,email"`
 	Code  string `json:"code" validate:"required,len=6"`
 }
 
-// ResendCodeRequest — faqat email
+// ResendCodeRequest — the email to send a new code to
 type ResendCodeRequest struct {
 	Email string `json:"email" validate:"required,email"`
 }
@@ -78,7 +95,7 @@ type ResetPasswordRequest struct {
 // UpdatePasswordRequest ...
 type UpdatePasswordRequest struct {
 	OldPassword string `json:"old_password" validate:"required"`
-	NewPassword string `json:"new_password" validate:"required,min=6"`
+	NewPassword string `json:"new_password" validate:"required,strongpassword,max=50"`
 }
 
 // UserList for paginated user listing
@@ -93,3 +110,38 @@ type Meta struct {
 	PageSize  int `json:"page_size"`
 	PageCount int `json:"page_count"`
 }
+
+// SearchUsersRequest query parameters of the user search
+type SearchUsersRequest struct {
+	Query    string `query:"q" validate:"required,min=2,max=100"`
+	Page     int    `query:"page" validate:"omitempty,min=1"`
+	PageSize int    `query:"page_size" validate:"omitempty,min=1,max=100"`
+}
+
+// UserHighlight holds user fields as HTML, escaped, with matched terms
+// wrapped in <mark></mark>
+type UserHighlight struct {
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Email     string `json:"email,omitempty"`
+}
+
+// UserSearchHit a ranked search match as returned by the repository
+type UserSearchHit struct {
+	User      User
+	Rank      float64
+	Highlight UserHighlight
+}
+
+// UserSearchResult a single ranked search hit
+type UserSearchResult struct {
+	User      UserResponse  `json:"user"`
+	Rank      float64       `json:"rank"`
+	Highlight UserHighlight `json:"highlight"`
+}
+
+// UserSearchList for paginated search results
+type UserSearchList struct {
+	List []UserSearchResult `json:"list"`
+	Meta Meta               `json:"meta"`
+}
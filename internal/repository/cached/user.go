@@ -0,0 +1,224 @@
+// Package cached decorates repositories with read-through caching.
+package cached
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+
+	errs "github.com/infosec554/clean-archtectura/domain"
+	domain "github.com/infosec554/clean-archtectura/domain/users"
+	"github.com/infosec554/clean-archtectura/internal/repository/postgres"
+	"github.com/infosec554/clean-archtectura/pkg/cache"
+	"github.com/infosec554/clean-archtectura/pkg/tracing"
+)
+
+// UserStore is the repository being cached
+type UserStore interface {
+	GetByEmail(ctx context.Context, email string) (domain.User, error)
+	GetCredentials(ctx context.Context, email string) (domain.User, error)
+	GetByID(ctx context.Context, id uuid.UUID) (domain.User, error)
+	GetByIDForUpdate(ctx context.Context, id uuid.UUID) (domain.User, error)
+	Create(ctx context.Context, req *domain.CreateUser) (string, error)
+	Update(ctx context.Context, req *domain.UpdateUser, passwordHash string) (string, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+	SetEmailVerified(ctx context.Context, email string) error
+	SetStatus(ctx context.Context, change domain.StatusChange) error
+	Search(ctx context.Context, text string, limit, offset int) ([]domain.UserSearchHit, int, error)
+	MergePreferences(ctx context.Context, id uuid.UUID, patch domain.UpdatePreferencesRequest) (domain.Preferences, error)
+}
+
+// UserRepository caches GetByID and GetByEmail. Users are stored by id; the
+// email key only maps to the id, so it changes only when the email does.
+// Every write invalidates the keys it affects; Search, GetCredentials and
+// GetByIDForUpdate are passed through.
+//
+// Cached users carry no password hash, as domain.User leaves it out of
+// JSON: a hash must come from the database, never from a copy that may
+// predate a password change.
+//
+// Inside a transaction reads skip the cache, so they see the transaction's
+// own writes and uncommitted rows are never cached, and invalidation waits
+// for the commit.
+type UserRepository struct {
+	UserStore
+	cache       *cache.Tiered
+	ttl         time.Duration
+	negativeTTL time.Duration
+	logger      zerolog.Logger
+}
+
+func NewUserRepository(store UserStore, c *cache.Tiered, ttl, negativeTTL time.Duration, logger zerolog.Logger) *UserRepository {
+	return &UserRepository{
+		UserStore:   store,
+		cache:       c,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		logger:      logger.With().Str("repository", "cached_user").Logger(),
+	}
+}
+
+func idKey(id uuid.UUID) string {
+	return "user:id:" + id.String()
+}
+
+func emailKey(email string) string {
+	return "user:email:" + email
+}
+
+func (r *UserRepository) GetByID(ctx context.Context, id uuid.UUID) (_ domain.User, err error) {
+	if postgres.InTx(ctx) {
+		return r.UserStore.GetByID(ctx, id)
+	}
+
+	ctx, span := tracing.Start(ctx, "CachedUserRepository.GetByID")
+	defer tracing.End(span, &err)
+
+	raw, err := r.cache.Fetch(ctx, idKey(id), r.ttl, r.negativeTTL, func(ctx context.Context) (string, error) {
+		user, err := r.UserStore.GetByID(ctx, id)
+		if err != nil {
+			return "", notFound(err)
+		}
+		data, err := json.Marshal(user)
+		return string(data), err
+	})
+	if err != nil {
+		return domain.User{}, userError(err)
+	}
+
+	var user domain.User
+	if err := json.Unmarshal([]byte(raw), &user); err != nil {
+		return domain.User{}, err
+	}
+	return user, nil
+}
+
+func (r *UserRepository) GetByEmail(ctx context.Context, email string) (_ domain.User, err error) {
+	if postgres.InTx(ctx) {
+		return r.UserStore.GetByEmail(ctx, email)
+	}
+
+	ctx, span := tracing.Start(ctx, "CachedUserRepository.GetByEmail")
+	defer tracing.End(span, &err)
+
+	id, err := r.cache.Fetch(ctx, emailKey(email), r.ttl, r.negativeTTL, func(ctx context.Context) (string, error) {
+		user, err := r.UserStore.GetByEmail(ctx, email)
+		if err != nil {
+			return "", notFound(err)
+		}
+		return user.ID.String(), nil
+	})
+	if err != nil {
+		return domain.User{}, userError(err)
+	}
+
+	userID, err := uuid.Parse(id)
+	if err != nil {
+		return domain.User{}, err
+	}
+	return r.GetByID(ctx, userID)
+}
+
+// Create drops a cached "not found" for the new email
+func (r *UserRepository) Create(ctx context.Context, req *domain.CreateUser) (string, error) {
+	id, err := r.UserStore.Create(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	if req.Email != "" {
+		r.invalidate(ctx, emailKey(req.Email))
+	}
+	return id, nil
+}
+
+func (r *UserRepository) Update(ctx context.Context, req *domain.UpdateUser, passwordHash string) (string, error) {
+	keys := r.keysOf(ctx, req.ID)
+	if req.Email != "" {
+		keys = append(keys, emailKey(req.Email))
+	}
+
+	id, err := r.UserStore.Update(ctx, req, passwordHash)
+	if err != nil {
+		return "", err
+	}
+	r.invalidate(ctx, keys...)
+	return id, nil
+}
+
+func (r *UserRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	keys := r.keysOf(ctx, id)
+	if err := r.UserStore.Delete(ctx, id); err != nil {
+		return err
+	}
+	r.invalidate(ctx, keys...)
+	return nil
+}
+
+func (r *UserRepository) SetEmailVerified(ctx context.Context, email string) error {
+	keys := []string{emailKey(email)}
+	if user, err := r.GetByEmail(ctx, email); err == nil {
+		keys = append(keys, idKey(user.ID))
+	}
+
+	if err := r.UserStore.SetEmailVerified(ctx, email); err != nil {
+		return err
+	}
+	r.invalidate(ctx, keys...)
+	return nil
+}
+
+func (r *UserRepository) SetStatus(ctx context.Context, change domain.StatusChange) error {
+	if err := r.UserStore.SetStatus(ctx, change); err != nil {
+		return err
+	}
+	r.invalidate(ctx, idKey(change.UserID))
+	return nil
+}
+
+func (r *UserRepository) MergePreferences(ctx context.Context, id uuid.UUID, patch domain.UpdatePreferencesRequest) (domain.Preferences, error) {
+	prefs, err := r.UserStore.MergePreferences(ctx, id, patch)
+	if err != nil {
+		return domain.Preferences{}, err
+	}
+	r.invalidate(ctx, idKey(id))
+	return prefs, nil
+}
+
+// keysOf returns the id key and, if the user is known, the current email key
+func (r *UserRepository) keysOf(ctx context.Context, id uuid.UUID) []string {
+	keys := []string{idKey(id)}
+	if user, err := r.GetByID(ctx, id); err == nil && user.Email != nil {
+		keys = append(keys, emailKey(*user.Email))
+	}
+	return keys
+}
+
+// invalidate logs failures instead of returning them: the write has
+// already succeeded, and the local tier expires on its own
+func (r *UserRepository) invalidate(ctx context.Context, keys ...string) {
+	postgres.AfterCommit(ctx, func(ctx context.Context) {
+		if err := r.cache.Invalidate(ctx, keys...); err != nil {
+			r.logger.Error().Ctx(ctx).Err(err).Strs("keys", keys).Msg("Failed to invalidate cached user")
+		}
+	})
+}
+
+// notFound turns the repository's not-found error into the cache's, so the
+// result is cached
+func notFound(err error) error {
+	if errors.Is(err, errs.ErrNotFound) {
+		return cache.ErrNotFound
+	}
+	return err
+}
+
+func userError(err error) error {
+	if errors.Is(err, cache.ErrNotFound) {
+		return errs.NotFound("user not found")
+	}
+	return err
+}
@@ -0,0 +1,153 @@
+package cached
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+
+	errs "github.com/infosec554/clean-archtectura/domain"
+	domain "github.com/infosec554/clean-archtectura/domain/users"
+	"github.com/infosec554/clean-archtectura/pkg/cache"
+)
+
+// fakeStore holds users in memory and counts reads; calls the tests do not
+// need panic through the nil interface
+type fakeStore struct {
+	UserStore
+	users map[uuid.UUID]domain.User
+	reads int
+}
+
+func (f *fakeStore) GetByID(_ context.Context, id uuid.UUID) (domain.User, error) {
+	f.reads++
+	user, ok := f.users[id]
+	if !ok {
+		return domain.User{}, errs.NotFound("user not found")
+	}
+	return user, nil
+}
+
+func (f *fakeStore) GetByEmail(_ context.Context, email string) (domain.User, error) {
+	f.reads++
+	for _, user := range f.users {
+		if user.Email != nil && *user.Email == email {
+			return user, nil
+		}
+	}
+	return domain.User{}, errs.NotFound("user not found")
+}
+
+func (f *fakeStore) Create(_ context.Context, req *domain.CreateUser) (string, error) {
+	id := uuid.New()
+	f.users[id] = domain.User{ID: id, FirstName: req.FirstName, Email: &req.Email}
+	return id.String(), nil
+}
+
+func (f *fakeStore) Update(_ context.Context, req *domain.UpdateUser, _ string) (string, error) {
+	user := f.users[req.ID]
+	if req.FirstName != "" {
+		user.FirstName = req.FirstName
+	}
+	if req.Email != "" {
+		user.Email = &req.Email
+	}
+	f.users[req.ID] = user
+	return req.ID.String(), nil
+}
+
+func (f *fakeStore) SetStatus(_ context.Context, change domain.StatusChange) error {
+	user := f.users[change.UserID]
+	user.Status = change.Status
+	f.users[change.UserID] = user
+	return nil
+}
+
+func newTestRepository() (*UserRepository, *fakeStore, domain.User) {
+	email, hash := "aziz@example.com", "$2a$10$hash"
+	user := domain.User{ID: uuid.New(), FirstName: "Aziz", Email: &email, Password: &hash, Status: domain.AccountStatusActive}
+	store := &fakeStore{users: map[uuid.UUID]domain.User{user.ID: user}}
+	tier := cache.NewTiered("users", cache.NewMemory(), 100, time.Minute, time.Hour)
+	return NewUserRepository(store, tier, time.Minute, time.Minute, zerolog.Nop()), store, user
+}
+
+func TestGetByIDCachesWithoutPassword(t *testing.T) {
+	ctx := context.Background()
+	repo, store, user := newTestRepository()
+
+	for i := 0; i < 2; i++ {
+		got, err := repo.GetByID(ctx, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.FirstName != user.FirstName {
+			t.Fatalf("GetByID #%d = %+v", i, got)
+		}
+		if got.Password != nil {
+			t.Fatalf("GetByID #%d returned a password hash", i)
+		}
+	}
+	if store.reads != 1 {
+		t.Fatalf("store read %d times, want once", store.reads)
+	}
+
+	if _, err := repo.GetByEmail(ctx, *user.Email); err != nil {
+		t.Fatal(err)
+	}
+	if store.reads != 2 {
+		t.Fatalf("store read %d times, want the email lookup to reuse the cached user", store.reads)
+	}
+}
+
+func TestWritesInvalidate(t *testing.T) {
+	ctx := context.Background()
+	repo, _, user := newTestRepository()
+
+	if _, err := repo.GetByEmail(ctx, *user.Email); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Update(ctx, &domain.UpdateUser{ID: user.ID, FirstName: "Bobur", Email: "bobur@example.com"}, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, _ := repo.GetByID(ctx, user.ID); got.FirstName != "Bobur" {
+		t.Fatalf("GetByID after Update = %q, want the new name", got.FirstName)
+	}
+	if _, err := repo.GetByEmail(ctx, *user.Email); !errors.Is(err, errs.ErrNotFound) {
+		t.Fatalf("GetByEmail(old email) error = %v, want not found", err)
+	}
+	if got, err := repo.GetByEmail(ctx, "bobur@example.com"); err != nil || got.ID != user.ID {
+		t.Fatalf("GetByEmail(new email) = %v, %v", got.ID, err)
+	}
+
+	if err := repo.SetStatus(ctx, domain.StatusChange{UserID: user.ID, Status: domain.AccountStatusBanned}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := repo.GetByID(ctx, user.ID); got.Status != domain.AccountStatusBanned {
+		t.Fatalf("GetByID after SetStatus = %q, want banned", got.Status)
+	}
+}
+
+func TestCreateDropsNegativeEntry(t *testing.T) {
+	ctx := context.Background()
+	repo, store, _ := newTestRepository()
+
+	for i := 0; i < 2; i++ {
+		if _, err := repo.GetByEmail(ctx, "new@example.com"); !errors.Is(err, errs.ErrNotFound) {
+			t.Fatalf("GetByEmail #%d error = %v, want not found", i, err)
+		}
+	}
+	if store.reads != 1 {
+		t.Fatalf("store read %d times, want the miss cached", store.reads)
+	}
+
+	if _, err := repo.Create(ctx, &domain.CreateUser{FirstName: "New", Email: "new@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := repo.GetByEmail(ctx, "new@example.com"); err != nil || got.FirstName != "New" {
+		t.Fatalf("GetByEmail after Create = %+v, %v", got, err)
+	}
+}
@@ -0,0 +1,80 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+
+	domain "github.com/infosec554/clean-archtectura/domain/users"
+)
+
+type AuditRepository struct {
+	DB     *sql.DB
+	logger zerolog.Logger
+}
+
+func NewAuditRepository(db *sql.DB, logger zerolog.Logger) *AuditRepository {
+	return &AuditRepository{
+		DB:     db,
+		logger: logger.With().Str("repository", "audit").Logger(),
+	}
+}
+
+// Create appends an entry to the audit trail
+func (r *AuditRepository) Create(ctx context.Context, entry domain.AuditEntry) error {
+	query := `
+		INSERT INTO audit_logs (actor_id, subject_id, action, reason, method, path, status_code, ip, user_agent)
+		VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), NULLIF($6, ''), NULLIF($7, 0), NULLIF($8, ''), NULLIF($9, ''))
+	`
+
+	_, err := conn(ctx, r.DB).ExecContext(ctx, query,
+		entry.ActorID,
+		entry.SubjectID,
+		entry.Action,
+		entry.Reason,
+		entry.Method,
+		entry.Path,
+		entry.StatusCode,
+		entry.IP,
+		entry.UserAgent,
+	)
+	if err != nil {
+		r.logger.Error().Ctx(ctx).Err(err).Str("actor_id", entry.ActorID.String()).Str("action", entry.Action).Msg("Error writing audit entry")
+		return translateError(err, "audit entry")
+	}
+
+	return nil
+}
+
+// ListByUser returns the entries the user acted in or was the subject of,
+// oldest first
+func (r *AuditRepository) ListByUser(ctx context.Context, userID uuid.UUID) ([]domain.AuditEntry, error) {
+	query := `
+		SELECT id, actor_id, subject_id, action, COALESCE(reason, ''), COALESCE(method, ''), COALESCE(path, ''),
+			COALESCE(status_code, 0), COALESCE(ip, ''), COALESCE(user_agent, ''), created_at
+		FROM audit_logs
+		WHERE actor_id = $1 OR subject_id = $1
+		ORDER BY created_at
+	`
+
+	rows, err := conn(ctx, r.DB).QueryContext(ctx, query, userID)
+	if err != nil {
+		r.logger.Error().Ctx(ctx).Err(err).Str("user_id", userID.String()).Msg("Error listing audit entries")
+		return nil, translateError(err, "audit entry")
+	}
+	defer rows.Close()
+
+	var list []domain.AuditEntry
+	for rows.Next() {
+		var e domain.AuditEntry
+		err := rows.Scan(&e.ID, &e.ActorID, &e.SubjectID, &e.Action, &e.Reason, &e.Method, &e.Path,
+			&e.StatusCode, &e.IP, &e.UserAgent, &e.CreatedAt)
+		if err != nil {
+			return nil, translateError(err, "audit entry")
+		}
+		list = append(list, e)
+	}
+	return list, rows.Err()
+}
@@ -0,0 +1,210 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+
+	errs "github.com/infosec554/clean-archtectura/domain"
+	domain "github.com/infosec554/clean-archtectura/domain/users"
+)
+
+type DataExportRepository struct {
+	DB     *sql.DB
+	logger zerolog.Logger
+}
+
+func NewDataExportRepository(db *sql.DB, logger zerolog.Logger) *DataExportRepository {
+	return &DataExportRepository{
+		DB:     db,
+		logger: logger.With().Str("repository", "data_export").Logger(),
+	}
+}
+
+const dataExportColumns = `id, user_id, status, file_path, token_hash, error, expires_at, created_at, updated_at`
+
+// Create inserts a pending export job for the user. It returns a conflict
+// error, without aborting the transaction in ctx, when the user already has
+// an export in progress.
+func (r *DataExportRepository) Create(ctx context.Context, userID uuid.UUID) (domain.DataExport, error) {
+	query := `
+		INSERT INTO data_exports (user_id, status)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) WHERE status IN ('pending', 'processing') DO NOTHING
+		RETURNING ` + dataExportColumns
+
+	export, err := scanDataExport(conn(ctx, r.DB).QueryRowContext(ctx, query, userID, domain.ExportStatusPending))
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.DataExport{}, errs.Conflict("data export already in progress")
+	}
+	if err != nil {
+		r.logger.Error().Ctx(ctx).Err(err).Str("user_id", userID.String()).Msg("Error creating data export")
+		return domain.DataExport{}, translateError(err, "data export")
+	}
+
+	return export, nil
+}
+
+// GetByID retrieves a single export job by ID
+func (r *DataExportRepository) GetByID(ctx context.Context, id uuid.UUID) (domain.DataExport, error) {
+	query := `SELECT ` + dataExportColumns + ` FROM data_exports WHERE id = $1`
+
+	export, err := scanDataExport(conn(ctx, r.DB).QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.DataExport{}, errs.NotFound("data export not found")
+		}
+		r.logger.Error().Ctx(ctx).Err(err).Str("export_id", id.String()).Msg("Error scanning data export by ID")
+		return domain.DataExport{}, translateError(err, "data export")
+	}
+
+	return export, nil
+}
+
+// ListByUser returns the user's export jobs, newest first
+func (r *DataExportRepository) ListByUser(ctx context.Context, userID uuid.UUID) ([]domain.DataExport, error) {
+	query := `SELECT ` + dataExportColumns + ` FROM data_exports WHERE user_id = $1 ORDER BY created_at DESC`
+
+	rows, err := conn(ctx, r.DB).QueryContext(ctx, query, userID)
+	if err != nil {
+		r.logger.Error().Ctx(ctx).Err(err).Str("user_id", userID.String()).Msg("Error listing data exports")
+		return nil, translateError(err, "data export")
+	}
+	defer rows.Close()
+
+	var list []domain.DataExport
+	for rows.Next() {
+		export, err := scanDataExport(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, export)
+	}
+
+	return list, rows.Err()
+}
+
+// ListExpired returns ready exports whose download window has passed
+func (r *DataExportRepository) ListExpired(ctx context.Context, now time.Time) ([]domain.DataExport, error) {
+	query := `SELECT ` + dataExportColumns + ` FROM data_exports WHERE status = $1 AND expires_at < $2`
+
+	rows, err := conn(ctx, r.DB).QueryContext(ctx, query, domain.ExportStatusReady, now)
+	if err != nil {
+		r.logger.Error().Ctx(ctx).Err(err).Msg("Error listing expired data exports")
+		return nil, translateError(err, "data export")
+	}
+	defer rows.Close()
+
+	var list []domain.DataExport
+	for rows.Next() {
+		export, err := scanDataExport(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, export)
+	}
+
+	return list, rows.Err()
+}
+
+// ListStale returns exports that have been pending or processing for longer
+// than olderThan, measured on the database clock
+func (r *DataExportRepository) ListStale(ctx context.Context, olderThan time.Duration) ([]domain.DataExport, error) {
+	query := `
+		SELECT ` + dataExportColumns + ` FROM data_exports
+		WHERE status IN ($1, $2) AND updated_at < NOW() - $3 * INTERVAL '1 second'
+	`
+
+	rows, err := conn(ctx, r.DB).QueryContext(ctx, query, domain.ExportStatusPending, domain.ExportStatusProcessing, olderThan.Seconds())
+	if err != nil {
+		r.logger.Error().Ctx(ctx).Err(err).Msg("Error listing stale data exports")
+		return nil, translateError(err, "data export")
+	}
+	defer rows.Close()
+
+	var list []domain.DataExport
+	for rows.Next() {
+		export, err := scanDataExport(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, export)
+	}
+
+	return list, rows.Err()
+}
+
+// SetStatus moves an export job to the given status, recording an error message for failures
+func (r *DataExportRepository) SetStatus(ctx context.Context, id uuid.UUID, status domain.ExportStatus, errMsg string) error {
+	query := `UPDATE data_exports SET status = $1, error = NULLIF($2, ''), updated_at = NOW() WHERE id = $3`
+
+	if _, err := conn(ctx, r.DB).ExecContext(ctx, query, status, errMsg, id); err != nil {
+		r.logger.Error().Ctx(ctx).Err(err).Str("export_id", id.String()).Msg("Error updating data export status")
+		return translateError(err, "data export")
+	}
+	return nil
+}
+
+// MarkReady stores the archive location and download token of a finished export
+func (r *DataExportRepository) MarkReady(ctx context.Context, id uuid.UUID, filePath, tokenHash string, expiresAt time.Time) error {
+	query := `
+		UPDATE data_exports
+		SET status = $1, file_path = $2, token_hash = $3, expires_at = $4, error = NULL, updated_at = NOW()
+		WHERE id = $5
+	`
+
+	if _, err := conn(ctx, r.DB).ExecContext(ctx, query, domain.ExportStatusReady, filePath, tokenHash, expiresAt, id); err != nil {
+		r.logger.Error().Ctx(ctx).Err(err).Str("export_id", id.String()).Msg("Error marking data export ready")
+		return translateError(err, "data export")
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanDataExport(row rowScanner) (domain.DataExport, error) {
+	var (
+		export    domain.DataExport
+		filePath  sql.NullString
+		tokenHash sql.NullString
+		errMsg    sql.NullString
+		expiresAt sql.NullTime
+		createdAt sql.NullTime
+		updatedAt sql.NullTime
+	)
+
+	if err := row.Scan(
+		&export.ID,
+		&export.UserID,
+		&export.Status,
+		&filePath,
+		&tokenHash,
+		&errMsg,
+		&expiresAt,
+		&createdAt,
+		&updatedAt,
+	); err != nil {
+		return domain.DataExport{}, err
+	}
+
+	export.FilePath = filePath.String
+	export.TokenHash = tokenHash.String
+	export.Error = errMsg.String
+	if expiresAt.Valid {
+		export.ExpiresAt = &expiresAt.Time
+	}
+	if createdAt.Valid {
+		export.CreatedAt = createdAt.Time
+	}
+	if updatedAt.Valid {
+		export.UpdatedAt = updatedAt.Time
+	}
+
+	return export, nil
+}
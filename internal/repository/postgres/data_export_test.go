@@ -0,0 +1,23 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+
+	errs "github.com/infosec554/clean-archtectura/domain"
+)
+
+func TestCreateDataExportInProgress(t *testing.T) {
+	f, db := newFakeDB()
+	// ON CONFLICT DO NOTHING returns no row
+	f.answer("INSERT INTO data_exports", nil)
+
+	_, err := NewDataExportRepository(db, zerolog.Nop()).Create(context.Background(), uuid.New())
+	if !errors.Is(err, errs.ErrConflict) {
+		t.Fatalf("Create error = %v, want a conflict", err)
+	}
+}
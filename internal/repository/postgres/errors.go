@@ -0,0 +1,66 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/infosec554/clean-archtectura/domain"
+)
+
+// uniqueFields maps unique constraint names to the request field they protect
+var uniqueFields = map[string]string{
+	"users_email_key": "email",
+}
+
+// translateError converts driver errors into typed domain errors.
+// entity names the record in not-found messages, e.g. "user".
+func translateError(err error, entity string) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := domain.AsError(err); ok {
+		return err
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.NotFound(entity + " not found")
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return domain.Internal(err)
+	}
+
+	switch pgErr.Code {
+	case pgerrcode.UniqueViolation:
+		field := uniqueFields[pgErr.ConstraintName]
+		if field == "" {
+			return domain.Wrap(domain.ErrConflict, entity+" already exists", err)
+		}
+		return &domain.Error{
+			Kind:    domain.ErrConflict,
+			Message: entity + " already exists",
+			Fields:  []domain.FieldError{{Field: field, Rule: "unique", Message: field + " is already taken"}},
+			Err:     err,
+		}
+	case pgerrcode.ForeignKeyViolation:
+		return domain.Wrap(domain.ErrConflict, "referenced record does not exist or is still in use", err)
+	case pgerrcode.NotNullViolation:
+		return &domain.Error{
+			Kind:    domain.ErrValidation,
+			Message: "required value is missing",
+			Fields:  []domain.FieldError{{Field: pgErr.ColumnName, Rule: "required", Message: pgErr.ColumnName + " is required"}},
+			Err:     err,
+		}
+	case pgerrcode.CheckViolation:
+		return domain.Wrap(domain.ErrValidation, "value is not allowed", err)
+	case pgerrcode.InvalidTextRepresentation:
+		return domain.Wrap(domain.ErrValidation, "value has an invalid format", err)
+	case pgerrcode.StringDataRightTruncationDataException:
+		return domain.Wrap(domain.ErrValidation, "value is too long", err)
+	}
+
+	return domain.Internal(err)
+}
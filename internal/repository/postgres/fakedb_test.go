@@ -0,0 +1,213 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// fakeDB is a database/sql connector that records the statements it runs
+// and fails those listed in fail. It supports what the tests need:
+// transactions, Exec affecting one row unless affected says otherwise, and
+// queries answered with the one row in rows, or none if it is empty. The
+// maps are keyed by the query, or by any part of it that identifies it.
+type fakeDB struct {
+	mu       sync.Mutex
+	log      []string
+	conns    []int
+	opened   int
+	args     map[string][]driver.Value
+	fail     map[string]error
+	rows     map[string][]driver.Value
+	affected map[string]*int64
+}
+
+func newFakeDB() (*fakeDB, *sql.DB) {
+	f := &fakeDB{
+		args:     make(map[string][]driver.Value),
+		fail:     make(map[string]error),
+		rows:     make(map[string][]driver.Value),
+		affected: make(map[string]*int64),
+	}
+	return f, sql.OpenDB(f)
+}
+
+// affect makes statements matching query report n affected rows
+func (f *fakeDB) affect(query string, n int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.affected[query] = &n
+}
+
+// answer makes query return a single row of values, or fail with err
+func (f *fakeDB) answer(query string, err error, values ...driver.Value) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rows[query] = values
+	f.fail[query] = err
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.opened++
+	return &fakeConn{db: f, id: f.opened}, nil
+}
+
+func (f *fakeDB) Driver() driver.Driver {
+	return fakeDriver{}
+}
+
+func (f *fakeDB) run(conn int, query string, args ...driver.NamedValue) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.log = append(f.log, query)
+	f.conns = append(f.conns, conn)
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	f.args[query] = values
+	return lookup(f.fail, query)
+}
+
+// lookup returns the value whose key is query or a part of it
+func lookup[V any](m map[string]V, query string) V {
+	if v, ok := m[query]; ok {
+		return v
+	}
+	for key, v := range m {
+		if strings.Contains(query, key) {
+			return v
+		}
+	}
+	var zero V
+	return zero
+}
+
+// lastArgs returns the arguments of the last statement containing part
+func (f *fakeDB) lastArgs(part string) []driver.Value {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := len(f.log) - 1; i >= 0; i-- {
+		if strings.Contains(f.log[i], part) {
+			return f.args[f.log[i]]
+		}
+	}
+	return nil
+}
+
+// connections returns the connection each statement containing part ran on,
+// in order; connections are numbered from 1 as they are opened
+func (f *fakeDB) connections(part string) []int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var conns []int
+	for i, query := range f.log {
+		if strings.Contains(query, part) {
+			conns = append(conns, f.conns[i])
+		}
+	}
+	return conns
+}
+
+func (f *fakeDB) statements() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.log...)
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("fakeDriver: use sql.OpenDB")
+}
+
+type fakeConn struct {
+	db *fakeDB
+	id int
+}
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("fakeConn: prepared statements are not supported")
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakeConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	if err := c.db.run(c.id, "BEGIN"); err != nil {
+		return nil, err
+	}
+	return fakeTx{conn: c}, nil
+}
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := c.db.run(c.id, query, args...); err != nil {
+		return nil, err
+	}
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	if n := lookup(c.db.affected, query); n != nil {
+		return driver.RowsAffected(*n), nil
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := c.db.run(c.id, query, args...); err != nil {
+		return nil, err
+	}
+	c.db.mu.Lock()
+	values := lookup(c.db.rows, query)
+	c.db.mu.Unlock()
+	return &fakeRows{values: values}, nil
+}
+
+type fakeRows struct {
+	values []driver.Value
+	done   bool
+}
+
+func (r *fakeRows) Columns() []string {
+	columns := make([]string, len(r.values))
+	for i := range columns {
+		columns[i] = fmt.Sprintf("c%d", i)
+	}
+	return columns
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.done || len(r.values) == 0 {
+		return io.EOF
+	}
+	r.done = true
+	copy(dest, r.values)
+	return nil
+}
+
+type fakeTx struct {
+	conn *fakeConn
+}
+
+func (t fakeTx) Commit() error {
+	return t.conn.db.run(t.conn.id, "COMMIT")
+}
+
+func (t fakeTx) Rollback() error {
+	return t.conn.db.run(t.conn.id, "ROLLBACK")
+}
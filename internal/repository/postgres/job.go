@@ -0,0 +1,226 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+
+	jobs "github.com/infosec554/clean-archtectura/domain/jobs"
+)
+
+// JobRepository stores the background job queue. Enqueue runs on the
+// transaction in the context, so a job can be committed together with the
+// change that caused it.
+type JobRepository struct {
+	DB     *sql.DB
+	logger zerolog.Logger
+}
+
+func NewJobRepository(db *sql.DB, logger zerolog.Logger) *JobRepository {
+	return &JobRepository{
+		DB:     db,
+		logger: logger.With().Str("repository", "job").Logger(),
+	}
+}
+
+const jobColumns = `id, queue, kind, payload, status, attempt, max_attempts, unique_key, run_at, last_error, created_at`
+
+// Enqueue adds a job. It reports false, without an error, when the job has
+// a unique key that another waiting or running job already holds.
+func (r *JobRepository) Enqueue(ctx context.Context, job jobs.NewJob) (bool, error) {
+	query := `
+		INSERT INTO jobs (queue, kind, payload, max_attempts, unique_key, run_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), COALESCE($6, NOW()))
+		ON CONFLICT (unique_key) WHERE unique_key IS NOT NULL AND status <> 'dead' DO NOTHING
+		RETURNING id
+	`
+
+	var runAt *time.Time
+	if !job.RunAt.IsZero() {
+		runAt = &job.RunAt
+	}
+	payload := []byte(job.Payload)
+	if payload == nil {
+		payload = []byte("{}")
+	}
+
+	var id uuid.UUID
+	err := conn(ctx, r.DB).QueryRowContext(ctx, query,
+		job.Queue,
+		job.Kind,
+		payload,
+		job.MaxAttempts,
+		job.UniqueKey,
+		runAt,
+	).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		r.logger.Debug().Ctx(ctx).Str("kind", job.Kind).Str("unique_key", job.UniqueKey).Msg("Job already pending")
+		return false, nil
+	}
+	if err != nil {
+		r.logger.Error().Ctx(ctx).Err(err).Str("kind", job.Kind).Msg("Error enqueuing job")
+		return false, translateError(err, "job")
+	}
+
+	return true, nil
+}
+
+// Claim locks up to limit due jobs of queue for lease and counts the
+// attempt. Jobs whose lease ran out, because their worker died, are due
+// again, unless that was their last attempt: those are buried. SKIP LOCKED
+// lets workers claim concurrently without waiting on each other.
+func (r *JobRepository) Claim(ctx context.Context, queue string, limit int, lease time.Duration) ([]jobs.Job, error) {
+	if err := r.buryAbandoned(ctx, queue); err != nil {
+		return nil, err
+	}
+
+	query := `
+		UPDATE jobs
+		SET status = 'running',
+			attempt = attempt + 1,
+			locked_until = NOW() + $3 * INTERVAL '1 second',
+			updated_at = NOW()
+		WHERE id IN (
+			SELECT id FROM jobs
+			WHERE queue = $1
+				AND ((status = 'available' AND run_at <= NOW())
+					OR (status = 'running' AND locked_until < NOW() AND attempt < max_attempts))
+			ORDER BY run_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + jobColumns
+
+	rows, err := r.DB.QueryContext(ctx, query, queue, limit, lease.Seconds())
+	if err != nil {
+		return nil, translateError(err, "job")
+	}
+	defer rows.Close()
+
+	var list []jobs.Job
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, translateError(err, "job")
+		}
+		list = append(list, job)
+	}
+	return list, rows.Err()
+}
+
+// buryAbandoned moves jobs whose lease ran out on their last attempt to the
+// dead letters; running them again would exceed max_attempts
+func (r *JobRepository) buryAbandoned(ctx context.Context, queue string) error {
+	query := `
+		UPDATE jobs
+		SET status = 'dead',
+			last_error = 'lease expired on the last attempt',
+			locked_until = NULL,
+			updated_at = NOW()
+		WHERE queue = $1
+			AND status = 'running'
+			AND locked_until < NOW()
+			AND attempt >= max_attempts
+		RETURNING id, kind
+	`
+	rows, err := r.DB.QueryContext(ctx, query, queue)
+	if err != nil {
+		return translateError(err, "job")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id   uuid.UUID
+			kind string
+		)
+		if err := rows.Scan(&id, &kind); err != nil {
+			return translateError(err, "job")
+		}
+		r.logger.Warn().Ctx(ctx).Str("job_id", id.String()).Str("queue", queue).Str("kind", kind).Msg("Job abandoned on its last attempt, buried")
+	}
+	return rows.Err()
+}
+
+// Complete removes a job that succeeded. Like Retry and Bury it applies only
+// while the attempt still holds the job; once its lease ran out and another
+// worker claimed it, the result is stale and jobs.ErrLeaseLost is returned.
+func (r *JobRepository) Complete(ctx context.Context, id uuid.UUID, attempt int) error {
+	query := `DELETE FROM jobs WHERE id = $1 AND attempt = $2 AND status = 'running'`
+	return r.fenced(ctx, query, id, attempt)
+}
+
+// Retry releases a failed job to run again after delay, measured on the
+// database clock like the rest of the schedule
+func (r *JobRepository) Retry(ctx context.Context, id uuid.UUID, attempt int, delay time.Duration, errMsg string) error {
+	query := `
+		UPDATE jobs
+		SET status = 'available',
+			run_at = NOW() + $3 * INTERVAL '1 second',
+			last_error = $4,
+			locked_until = NULL,
+			updated_at = NOW()
+		WHERE id = $1 AND attempt = $2 AND status = 'running'
+	`
+	return r.fenced(ctx, query, id, attempt, delay.Seconds(), errMsg)
+}
+
+// Bury moves a job to the dead letters, where it stays for inspection
+func (r *JobRepository) Bury(ctx context.Context, id uuid.UUID, attempt int, errMsg string) error {
+	query := `
+		UPDATE jobs
+		SET status = 'dead', last_error = $3, locked_until = NULL, updated_at = NOW()
+		WHERE id = $1 AND attempt = $2 AND status = 'running'
+	`
+	return r.fenced(ctx, query, id, attempt, errMsg)
+}
+
+// fenced runs a statement on the job that reports ErrLeaseLost when it
+// matched no row
+func (r *JobRepository) fenced(ctx context.Context, query string, args ...any) error {
+	res, err := r.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		return translateError(err, "job")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return translateError(err, "job")
+	}
+	if n == 0 {
+		return jobs.ErrLeaseLost
+	}
+	return nil
+}
+
+func scanJob(row rowScanner) (jobs.Job, error) {
+	var (
+		job       jobs.Job
+		payload   []byte
+		uniqueKey sql.NullString
+		lastError sql.NullString
+	)
+	err := row.Scan(
+		&job.ID,
+		&job.Queue,
+		&job.Kind,
+		&payload,
+		&job.Status,
+		&job.Attempt,
+		&job.MaxAttempts,
+		&uniqueKey,
+		&job.RunAt,
+		&lastError,
+		&job.CreatedAt,
+	)
+	if err != nil {
+		return jobs.Job{}, err
+	}
+	job.Payload = payload
+	job.UniqueKey = uniqueKey.String
+	job.LastError = lastError.String
+	return job, nil
+}
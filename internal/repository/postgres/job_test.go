@@ -0,0 +1,111 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+
+	jobs "github.com/infosec554/clean-archtectura/domain/jobs"
+)
+
+func TestEnqueue(t *testing.T) {
+	kind := jobs.Kind[map[string]string]{Name: "test.job", Queue: "email", MaxAttempts: 3}
+	job, err := kind.New(map[string]string{"to": "a@example.com"}, jobs.Unique("send:a@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		values []any
+		err    error
+		want   bool
+	}{
+		{"inserted", []any{uuid.NewString()}, nil, true},
+		{"unique key held by a pending job", nil, nil, false},
+		{"database error", nil, errors.New("connection refused"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, db := newFakeDB()
+			f.answer("INSERT INTO jobs", tt.err)
+			if tt.values != nil {
+				f.answer("INSERT INTO jobs", nil, tt.values[0])
+			}
+
+			ok, err := NewJobRepository(db, zerolog.Nop()).Enqueue(context.Background(), job)
+			if ok != tt.want || (err != nil) != (tt.err != nil) {
+				t.Fatalf("Enqueue = %v, %v, want %v, error %v", ok, err, tt.want, tt.err)
+			}
+
+			args := f.lastArgs("INSERT INTO jobs")
+			if len(args) < 5 || args[0] != "email" || args[1] != "test.job" || args[4] != "send:a@example.com" {
+				t.Fatalf("Enqueue args = %v", args)
+			}
+		})
+	}
+}
+
+func TestClaimBuriesAbandoned(t *testing.T) {
+	f, db := newFakeDB()
+	f.answer("SET status = 'dead'", nil, uuid.NewString(), "test.job")
+
+	if _, err := NewJobRepository(db, zerolog.Nop()).Claim(context.Background(), "email", 5, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	log := f.statements()
+	if len(log) != 2 || !strings.Contains(log[0], "status = 'dead'") || !strings.Contains(log[0], "attempt >= max_attempts") {
+		t.Fatalf("statements = %q, want the abandoned jobs buried first", log)
+	}
+	if !strings.Contains(log[1], "locked_until < NOW() AND attempt < max_attempts") {
+		t.Fatalf("claim query reclaims jobs that used their last attempt: %s", log[1])
+	}
+}
+
+// Results are recorded only while the attempt still holds the job
+func TestJobResultFencing(t *testing.T) {
+	id := uuid.New()
+	calls := []struct {
+		name  string
+		query string
+		call  func(r *JobRepository) error
+	}{
+		{"complete", "DELETE FROM jobs", func(r *JobRepository) error {
+			return r.Complete(context.Background(), id, 2)
+		}},
+		{"retry", "SET status = 'available'", func(r *JobRepository) error {
+			return r.Retry(context.Background(), id, 2, time.Minute, "failed")
+		}},
+		{"bury", "SET status = 'dead'", func(r *JobRepository) error {
+			return r.Bury(context.Background(), id, 2, "failed")
+		}},
+	}
+	for _, c := range calls {
+		t.Run(c.name, func(t *testing.T) {
+			f, db := newFakeDB()
+			repo := NewJobRepository(db, zerolog.Nop())
+
+			if err := c.call(repo); err != nil {
+				t.Fatalf("held job: %v", err)
+			}
+			log := f.statements()
+			if !strings.Contains(log[0], "attempt = $2 AND status = 'running'") {
+				t.Fatalf("statement is not fenced: %s", log[0])
+			}
+			if args := f.lastArgs(c.query); len(args) < 2 || args[1] != int64(2) {
+				t.Fatalf("args = %v, want the attempt second", args)
+			}
+
+			f.affect(c.query, 0)
+			if err := c.call(repo); !errors.Is(err, jobs.ErrLeaseLost) {
+				t.Fatalf("lost job error = %v, want ErrLeaseLost", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	migratepg "github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/rs/zerolog"
+
+	"github.com/infosec554/clean-archtectura/migrations"
+)
+
+// migrationLockID is the advisory lock key held while migrating, so replicas
+// starting together apply migrations one at a time
+const migrationLockID int64 = 0x636c65616e5f6d67
+
+// MigrationStatus describes the schema version against the migrations built into the binary
+type MigrationStatus struct {
+	Version uint
+	Dirty   bool
+	Latest  uint
+	Pending []uint
+}
+
+// Migrator applies the embedded migrations under a Postgres advisory lock
+type Migrator struct {
+	db          *sql.DB
+	source      fs.FS
+	lockTimeout time.Duration
+}
+
+func NewMigrator(db *sql.DB, lockTimeout time.Duration) *Migrator {
+	return &Migrator{db: db, source: migrations.Postgres, lockTimeout: lockTimeout}
+}
+
+// Up applies all pending migrations
+func (m *Migrator) Up(ctx context.Context) error {
+	logger := zerolog.Ctx(ctx).With().Str("component", "migrate").Logger()
+
+	return m.run(ctx, func(mg *migrate.Migrate) error {
+		err := mg.Up()
+		if errors.Is(err, migrate.ErrNoChange) {
+			logger.Info().Msg("No new migrations to apply")
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("migration up error: %w", err)
+		}
+		logger.Info().Msg("Migrations applied successfully")
+		return nil
+	})
+}
+
+// Down rolls back the last n migrations
+func (m *Migrator) Down(ctx context.Context, n int) error {
+	if n <= 0 {
+		return fmt.Errorf("migration down error: step count must be positive, got %d", n)
+	}
+	return m.run(ctx, func(mg *migrate.Migrate) error {
+		if err := mg.Steps(-n); err != nil {
+			return fmt.Errorf("migration down error: %w", err)
+		}
+		return nil
+	})
+}
+
+// Force sets the schema version without running migrations and clears the
+// dirty flag; -1 means no version. It is the way out of a failed migration.
+func (m *Migrator) Force(ctx context.Context, version int) error {
+	return m.run(ctx, func(mg *migrate.Migrate) error {
+		if err := mg.Force(version); err != nil {
+			return fmt.Errorf("migration force error: %w", err)
+		}
+		return nil
+	})
+}
+
+// Status reports the applied version and the migrations still to run
+func (m *Migrator) Status(ctx context.Context) (MigrationStatus, error) {
+	versions, err := migrations.Versions(m.source)
+	if err != nil {
+		return MigrationStatus{}, fmt.Errorf("migration source error: %w", err)
+	}
+
+	var status MigrationStatus
+	err = m.run(ctx, func(mg *migrate.Migrate) error {
+		version, dirty, err := mg.Version()
+		if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+			return fmt.Errorf("read schema version: %w", err)
+		}
+		status.Version, status.Dirty = version, dirty
+		return nil
+	})
+	if err != nil {
+		return MigrationStatus{}, err
+	}
+
+	for _, v := range versions {
+		if v > status.Version {
+			status.Pending = append(status.Pending, v)
+		}
+		status.Latest = max(status.Latest, v)
+	}
+	return status, nil
+}
+
+// run holds the advisory lock for the duration of fn. migrate works on a
+// connection of its own, so closing it does not close db.
+func (m *Migrator) run(ctx context.Context, fn func(*migrate.Migrate) error) error {
+	return m.withLock(ctx, func() error {
+		return m.migrate(ctx, fn)
+	})
+}
+
+// withLock runs fn while holding the advisory lock. The lock belongs to the
+// session that took it, so it is taken and released on one dedicated
+// connection that stays out of the pool until then.
+func (m *Migrator) withLock(ctx context.Context, fn func() error) error {
+	lockConn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("migration lock error: %w", err)
+	}
+	defer lockConn.Close()
+
+	if err := m.lock(ctx, lockConn); err != nil {
+		return err
+	}
+	defer func() {
+		// Background: the unlock must run even if ctx was cancelled
+		_, _ = lockConn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockID)
+	}()
+
+	return fn()
+}
+
+func (m *Migrator) migrate(ctx context.Context, fn func(*migrate.Migrate) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("migration init error: %w", err)
+	}
+	driver, err := migratepg.WithConnection(ctx, conn, &migratepg.Config{})
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("migration init error: %w", err)
+	}
+	source, err := iofs.New(m.source, ".")
+	if err != nil {
+		driver.Close()
+		return fmt.Errorf("migration source error: %w", err)
+	}
+	mg, err := migrate.NewWithInstance("iofs", source, "postgres", driver)
+	if err != nil {
+		driver.Close()
+		return fmt.Errorf("migration init error: %w", err)
+	}
+	defer mg.Close()
+
+	return fn(mg)
+}
+
+// lock polls for the advisory lock until lockTimeout, logging while another
+// instance holds it
+func (m *Migrator) lock(ctx context.Context, conn *sql.Conn) error {
+	ctx, cancel := context.WithTimeout(ctx, m.lockTimeout)
+	defer cancel()
+
+	logger := zerolog.Ctx(ctx).With().Str("component", "migrate").Logger()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for waited := false; ; waited = true {
+		var locked bool
+		err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, migrationLockID).Scan(&locked)
+		if err != nil {
+			return fmt.Errorf("migration lock error: %w", err)
+		}
+		if locked {
+			return nil
+		}
+		if !waited {
+			logger.Info().Msg("Waiting for another instance to finish migrating")
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("migration lock error: not acquired within %s", m.lockTimeout)
+		case <-ticker.C:
+		}
+	}
+}
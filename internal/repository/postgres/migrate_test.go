@@ -0,0 +1,93 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestMigratorWithLock(t *testing.T) {
+	failed := errors.New("migration failed")
+	tests := []struct {
+		name     string
+		locked   bool
+		lockErr  error
+		fnErr    error
+		wantErr  bool
+		wantRun  bool
+		unlocked bool
+	}{
+		{name: "acquired", locked: true, wantRun: true, unlocked: true},
+		{name: "fn fails", locked: true, fnErr: failed, wantErr: true, wantRun: true, unlocked: true},
+		{name: "held by another instance", locked: false, wantErr: true},
+		{name: "lock query fails", lockErr: errors.New("connection reset"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, db := newFakeDB()
+			defer db.Close()
+			if tt.locked {
+				fake.answer("pg_try_advisory_lock", nil, true)
+			} else {
+				fake.answer("pg_try_advisory_lock", tt.lockErr, false)
+			}
+			m := NewMigrator(db, 20*time.Millisecond)
+
+			ran := false
+			err := m.withLock(context.Background(), func() error {
+				ran = true
+				// Stands in for migrate, which takes a connection of its own
+				if _, err := db.ExecContext(context.Background(), `SELECT 'migrate'`); err != nil {
+					return err
+				}
+				return tt.fnErr
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("withLock = %v, want error %t", err, tt.wantErr)
+			}
+			if tt.fnErr != nil && !errors.Is(err, tt.fnErr) {
+				t.Errorf("withLock = %v, want the error of fn", err)
+			}
+			if ran != tt.wantRun {
+				t.Errorf("fn ran = %t, want %t", ran, tt.wantRun)
+			}
+
+			lock := fake.connections("pg_try_advisory_lock")
+			unlock := fake.connections("pg_advisory_unlock")
+			if !tt.unlocked {
+				if len(unlock) != 0 {
+					t.Errorf("unlocked a lock that was not taken")
+				}
+				return
+			}
+			if len(lock) != 1 || !slices.Equal(lock, unlock) {
+				t.Fatalf("lock ran on connections %v, unlock on %v, want the same one", lock, unlock)
+			}
+			if work := fake.connections("'migrate'"); len(work) != 1 || work[0] == lock[0] {
+				t.Errorf("fn ran on connection %v, want one other than the lock's %d", work, lock[0])
+			}
+		})
+	}
+}
+
+func TestMigratorWithLockCancelled(t *testing.T) {
+	fake, db := newFakeDB()
+	defer db.Close()
+	fake.answer("pg_try_advisory_lock", nil, true)
+	m := NewMigrator(db, time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err := m.withLock(ctx, func() error {
+		cancel()
+		return ctx.Err()
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("withLock = %v, want context.Canceled", err)
+	}
+	// The lock is released even though the caller gave up
+	if got := fake.connections("pg_advisory_unlock"); !slices.Equal(got, fake.connections("pg_try_advisory_lock")) {
+		t.Errorf("unlock ran on connections %v, want the lock's", got)
+	}
+}
@@ -0,0 +1,35 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Listen calls fn with the payload of every notification on channel until
+// ctx is done or the connection fails. It holds a connection of its own for
+// as long as it runs; notifications sent while it is not listening are lost,
+// so callers must not depend on them for correctness.
+func (s *Store) Listen(ctx context.Context, channel string, fn func(payload string)) error {
+	c, err := s.Pool.Acquire(ctx)
+	if err != nil {
+		return translateError(err, "connection")
+	}
+	// The connection is left listening, so it never goes back to the pool
+	conn := c.Hijack()
+	defer conn.Close(context.WithoutCancel(ctx))
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+		return translateError(err, "connection")
+	}
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		fn(n.Payload)
+	}
+}
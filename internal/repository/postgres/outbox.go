@@ -0,0 +1,125 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+
+	outbox "github.com/infosec554/clean-archtectura/domain/outbox"
+)
+
+// OutboxChannel is notified when a transaction that wrote outbox entries
+// commits
+const OutboxChannel = "outbox"
+
+// OutboxRepository stores the transactional outbox. Every method runs on
+// the transaction in the context: Add with the domain change it records,
+// the rest with the relay's delivery of the entries.
+type OutboxRepository struct {
+	DB     *sql.DB
+	logger zerolog.Logger
+}
+
+func NewOutboxRepository(db *sql.DB, logger zerolog.Logger) *OutboxRepository {
+	return &OutboxRepository{
+		DB:     db,
+		logger: logger.With().Str("repository", "outbox").Logger(),
+	}
+}
+
+// Add writes an event. Call it in the transaction of the change the event
+// records, so the event exists if and only if the change committed.
+func (r *OutboxRepository) Add(ctx context.Context, event outbox.NewEvent) error {
+	payload := []byte(event.Payload)
+	if payload == nil {
+		payload = []byte("{}")
+	}
+
+	_, err := conn(ctx, r.DB).ExecContext(ctx, `INSERT INTO outbox (topic, payload) VALUES ($1, $2)`, event.Topic, payload)
+	if err != nil {
+		r.logger.Error().Ctx(ctx).Err(err).Str("topic", event.Topic).Msg("Error adding outbox event")
+		return translateError(err, "outbox event")
+	}
+	return nil
+}
+
+// Pending locks up to limit undelivered events that are due, oldest first.
+// The locks last until the transaction in ctx ends; SKIP LOCKED lets
+// several relays share the outbox without delivering an event twice at once.
+func (r *OutboxRepository) Pending(ctx context.Context, limit int) ([]outbox.Event, error) {
+	query := `
+		SELECT id, topic, payload, attempts, created_at
+		FROM outbox
+		WHERE delivered_at IS NULL AND dead_at IS NULL AND available_at <= NOW()
+		ORDER BY created_at
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	`
+
+	rows, err := conn(ctx, r.DB).QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, translateError(err, "outbox event")
+	}
+	defer rows.Close()
+
+	var list []outbox.Event
+	for rows.Next() {
+		var (
+			event   outbox.Event
+			payload []byte
+		)
+		if err := rows.Scan(&event.ID, &event.Topic, &payload, &event.Attempts, &event.CreatedAt); err != nil {
+			return nil, translateError(err, "outbox event")
+		}
+		event.Payload = payload
+		list = append(list, event)
+	}
+	return list, rows.Err()
+}
+
+// MarkDelivered records that every subscriber handled the event
+func (r *OutboxRepository) MarkDelivered(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE outbox SET delivered_at = NOW(), last_error = NULL WHERE id = $1`
+	if _, err := conn(ctx, r.DB).ExecContext(ctx, query, id); err != nil {
+		return translateError(err, "outbox event")
+	}
+	return nil
+}
+
+// MarkFailed counts a failed delivery and holds the event back for delay
+func (r *OutboxRepository) MarkFailed(ctx context.Context, id uuid.UUID, delay time.Duration, errMsg string) error {
+	query := `
+		UPDATE outbox
+		SET attempts = attempts + 1,
+			available_at = NOW() + $2 * INTERVAL '1 second',
+			last_error = $3
+		WHERE id = $1
+	`
+	if _, err := conn(ctx, r.DB).ExecContext(ctx, query, id, delay.Seconds(), errMsg); err != nil {
+		return translateError(err, "outbox event")
+	}
+	return nil
+}
+
+// MarkDead counts the last failed delivery and sets the event aside; it is
+// kept, with its error, but no longer delivered
+func (r *OutboxRepository) MarkDead(ctx context.Context, id uuid.UUID, errMsg string) error {
+	query := `UPDATE outbox SET attempts = attempts + 1, dead_at = NOW(), last_error = $2 WHERE id = $1`
+	if _, err := conn(ctx, r.DB).ExecContext(ctx, query, id, errMsg); err != nil {
+		return translateError(err, "outbox event")
+	}
+	return nil
+}
+
+// Purge deletes events delivered longer than retention ago
+func (r *OutboxRepository) Purge(ctx context.Context, retention time.Duration) (int64, error) {
+	query := `DELETE FROM outbox WHERE delivered_at < NOW() - $1 * INTERVAL '1 second'`
+	res, err := conn(ctx, r.DB).ExecContext(ctx, query, retention.Seconds())
+	if err != nil {
+		return 0, translateError(err, "outbox event")
+	}
+	return res.RowsAffected()
+}
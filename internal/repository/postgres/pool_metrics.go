@@ -0,0 +1,67 @@
+package postgres
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// poolCollector exports the pgx pool statistics of the primary and every
+// replica. The database/sql stats of Store.DB show little, since it hands
+// every connection back to the pool.
+type poolCollector struct {
+	pools []namedPool
+
+	acquired, idle, total, max         *prometheus.Desc
+	acquires, emptyAcquires, cancelled *prometheus.Desc
+	acquireSeconds                     *prometheus.Desc
+}
+
+type namedPool struct {
+	name string
+	pool *pgxpool.Pool
+}
+
+// Collector returns a Prometheus collector for the connection pools,
+// labelled "primary" or by replica address
+func (s *Store) Collector(database string) prometheus.Collector {
+	labels := prometheus.Labels{"db_name": database}
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName("app", "db_pool", name), help, []string{"pool"}, labels)
+	}
+
+	pools := []namedPool{{name: "primary", pool: s.Pool}}
+	for _, r := range s.Replicas.replicas {
+		pools = append(pools, namedPool{name: r.name, pool: r.pool})
+	}
+	return &poolCollector{
+		pools:          pools,
+		acquired:       desc("acquired_conns", "Connections currently in use."),
+		idle:           desc("idle_conns", "Idle connections in the pool."),
+		total:          desc("total_conns", "Open connections, including ones being established."),
+		max:            desc("max_conns", "Maximum size of the pool."),
+		acquires:       desc("acquires_total", "Connections acquired from the pool."),
+		emptyAcquires:  desc("empty_acquires_total", "Acquires that had to wait for a connection or open one."),
+		cancelled:      desc("cancelled_acquires_total", "Acquires cancelled by their context."),
+		acquireSeconds: desc("acquire_seconds_total", "Total time spent waiting to acquire connections."),
+	}
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{c.acquired, c.idle, c.total, c.max, c.acquires, c.emptyAcquires, c.cancelled, c.acquireSeconds} {
+		ch <- d
+	}
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, p := range c.pools {
+		stat := p.pool.Stat()
+		ch <- prometheus.MustNewConstMetric(c.acquired, prometheus.GaugeValue, float64(stat.AcquiredConns()), p.name)
+		ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(stat.IdleConns()), p.name)
+		ch <- prometheus.MustNewConstMetric(c.total, prometheus.GaugeValue, float64(stat.TotalConns()), p.name)
+		ch <- prometheus.MustNewConstMetric(c.max, prometheus.GaugeValue, float64(stat.MaxConns()), p.name)
+		ch <- prometheus.MustNewConstMetric(c.acquires, prometheus.CounterValue, float64(stat.AcquireCount()), p.name)
+		ch <- prometheus.MustNewConstMetric(c.emptyAcquires, prometheus.CounterValue, float64(stat.EmptyAcquireCount()), p.name)
+		ch <- prometheus.MustNewConstMetric(c.cancelled, prometheus.CounterValue, float64(stat.CanceledAcquireCount()), p.name)
+		ch <- prometheus.MustNewConstMetric(c.acquireSeconds, prometheus.CounterValue, stat.AcquireDuration().Seconds(), p.name)
+	}
+}
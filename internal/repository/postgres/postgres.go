@@ -3,59 +3,187 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
-	"strings"
+	"net"
+	"net/url"
+	"time"
 
-	"github.com/golang-migrate/migrate/v4"
-	_ "github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
-	_ "github.com/lib/pq"
+	"github.com/XSAM/otelsql"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
 
 	"github.com/infosec554/clean-archtectura/config"
+	"github.com/infosec554/clean-archtectura/migrations"
+	"github.com/infosec554/clean-archtectura/pkg/health"
 )
 
+// Store owns the pgx connection pool. DB is a database/sql handle over the
+// same pool for the repositories and migrations; it keeps no idle
+// connections of its own, so the pool settings apply to both.
 type Store struct {
-	DB *sql.DB
+	Pool *pgxpool.Pool
+	DB   *sql.DB
+
+	// Replicas serves reads that tolerate replication lag
+	Replicas *ReplicaSet
+
+	// expectedVersion is the newest migration shipped with this build
+	expectedVersion    uint
+	migrateLockTimeout time.Duration
+	txOptions          TxOptions
 }
 
 func New(ctx context.Context, cfg config.Config) (*Store, error) {
-	dsn := fmt.Sprintf(
-		"postgres://%s:%s@%s:%s/%s?sslmode=disable",
-		cfg.PostgresUser, cfg.PostgresPassword, cfg.PostgresHost, cfg.PostgresPort, cfg.PostgresDB,
-	)
+	poolCfg, err := poolConfig(cfg, cfg.PostgresHost, cfg.PostgresPort)
+	if err != nil {
+		return nil, fmt.Errorf("config error: %w", err)
+	}
 
-	db, err := sql.Open("postgres", dsn)
+	pool, err := pgxpool.NewWithConfig(ctx, poolCfg)
 	if err != nil {
 		return nil, fmt.Errorf("open error: %w", err)
 	}
-	if err = db.PingContext(ctx); err != nil {
+	if err = pool.Ping(ctx); err != nil {
+		pool.Close()
 		return nil, fmt.Errorf("ping error: %w", err)
 	}
+	db := openDB(pool)
 
-	cwd, _ := os.Getwd()
-	mPath := filepath.Join(cwd, "migrations", "postgres")
-
-	m, err := migrate.New("file://"+mPath, dsn)
+	expected, err := migrations.Latest(migrations.Postgres)
 	if err != nil {
-		return nil, fmt.Errorf("migration init error: %w", err)
+		pool.Close()
+		return nil, fmt.Errorf("migration source error: %w", err)
 	}
 
-	if err = m.Up(); err != nil {
-		if strings.Contains(err.Error(), "no change") {
-			fmt.Println("⚠️  No new migrations to apply")
-		} else {
-			return nil, fmt.Errorf("migration up error: %w", err)
+	replicas := &ReplicaSet{primary: db, maxLag: int64(cfg.DBReplicaMaxLagBytes), period: cfg.DBReplicaCheckPeriod}
+	for _, hostPort := range cfg.DBReplicaHosts {
+		host, port, err := net.SplitHostPort(hostPort)
+		if err != nil {
+			host, port = hostPort, cfg.PostgresPort
+		}
+		replicaCfg, err := poolConfig(cfg, host, port)
+		if err != nil {
+			_ = replicas.close()
+			pool.Close()
+			return nil, fmt.Errorf("replica %s config error: %w", hostPort, err)
+		}
+		// Connections are opened lazily: an unreachable replica is ejected,
+		// it does not stop the service from starting
+		replicaPool, err := pgxpool.NewWithConfig(ctx, replicaCfg)
+		if err != nil {
+			_ = replicas.close()
+			pool.Close()
+			return nil, fmt.Errorf("replica %s open error: %w", hostPort, err)
 		}
-	} else {
-		fmt.Println("✅ Migrations applied successfully")
+		replicas.replicas = append(replicas.replicas, &replica{
+			name: net.JoinHostPort(host, port),
+			pool: replicaPool,
+			db:   openDB(replicaPool),
+		})
+	}
+	replicas.checkAll(ctx)
+
+	logger := zerolog.Ctx(ctx).With().Str("component", "postgres").Logger()
+	logger.Info().
+		Int32("max_conns", poolCfg.MaxConns).
+		Int32("min_conns", poolCfg.MinConns).
+		Str("sslmode", cfg.DBSSLMode).
+		Int("replicas", len(replicas.replicas)).
+		Msg("PostgreSQL connected successfully")
+	return &Store{
+		Pool:               pool,
+		DB:                 db,
+		Replicas:           replicas,
+		expectedVersion:    expected,
+		migrateLockTimeout: cfg.DBMigrateLockTimeout,
+		txOptions:          TxOptions{Isolation: isolationLevel(cfg.DBTxIsolation), MaxRetries: cfg.DBTxMaxRetries},
+	}, nil
+}
+
+// poolConfig builds the pool settings for the server at host:port. TLS is
+// configured through the libpq sslmode and sslrootcert parameters, which pgx
+// interprets the same way.
+func poolConfig(cfg config.Config, host, port string) (*pgxpool.Config, error) {
+	query := url.Values{"sslmode": {cfg.DBSSLMode}}
+	if cfg.DBSSLRootCert != "" {
+		query.Set("sslrootcert", cfg.DBSSLRootCert)
+	}
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(cfg.PostgresUser, cfg.PostgresPassword),
+		Host:     net.JoinHostPort(host, port),
+		Path:     cfg.PostgresDB,
+		RawQuery: query.Encode(),
+	}
+
+	poolCfg, err := pgxpool.ParseConfig(dsn.String())
+	if err != nil {
+		return nil, err
 	}
+	poolCfg.MaxConns = int32(cfg.DBMaxConns)
+	poolCfg.MinConns = int32(cfg.DBMinConns)
+	poolCfg.MaxConnLifetime = cfg.DBMaxConnLifetime
+	poolCfg.MaxConnIdleTime = cfg.DBMaxConnIdleTime
+	poolCfg.HealthCheckPeriod = cfg.DBHealthCheckPeriod
 
-	fmt.Println("✅ PostgreSQL connected successfully")
-	return &Store{DB: db}, nil
+	// Statements are prepared once per connection and reused; without a
+	// cache every query runs as an unnamed statement
+	poolCfg.ConnConfig.StatementCacheCapacity = cfg.DBStatementCacheSize
+	if cfg.DBStatementCacheSize == 0 {
+		poolCfg.ConnConfig.DefaultQueryExecMode = pgx.QueryExecModeExec
+	}
+	return poolCfg, nil
+}
+
+// openDB returns a database/sql handle over pool in which every query gets
+// a client span under the caller's span
+func openDB(pool *pgxpool.Pool) *sql.DB {
+	db := otelsql.OpenDB(stdlib.GetPoolConnector(pool),
+		otelsql.WithAttributes(attribute.String("db.system.name", "postgresql")),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{OmitRows: true, OmitConnResetSession: true}),
+	)
+	db.SetMaxIdleConns(0)
+	return db
+}
+
+// Migrator returns a migrator for the embedded migrations on this database
+func (s *Store) Migrator() *Migrator {
+	return NewMigrator(s.DB, s.migrateLockTimeout)
+}
+
+// HealthChecks reports connectivity, whether the schema is at the version
+// this build expects, and the state of each replica
+func (s *Store) HealthChecks() []health.Check {
+	return append([]health.Check{
+		{Name: "postgres", Critical: true, Fn: s.Pool.Ping},
+		{Name: "migrations", Critical: true, Fn: s.checkMigrations},
+	}, s.Replicas.HealthChecks()...)
+}
+
+func (s *Store) checkMigrations(ctx context.Context) error {
+	var (
+		version uint
+		dirty   bool
+	)
+	err := s.DB.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+	if dirty {
+		return fmt.Errorf("schema version %d is dirty", version)
+	}
+	if version != s.expectedVersion {
+		return fmt.Errorf("schema version %d, expected %d", version, s.expectedVersion)
+	}
+	return nil
 }
 
 func (s *Store) Close() error {
-	return s.DB.Close()
+	err := errors.Join(s.Replicas.close(), s.DB.Close())
+	s.Pool.Close()
+	return err
 }
@@ -0,0 +1,96 @@
+package postgres
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/infosec554/clean-archtectura/config"
+)
+
+func TestPoolConfig(t *testing.T) {
+	base := config.Config{
+		PostgresUser:         "app",
+		PostgresPassword:     "p@ss/word",
+		PostgresDB:           "mydb",
+		DBSSLMode:            "disable",
+		DBMaxConns:           20,
+		DBMinConns:           2,
+		DBMaxConnLifetime:    time.Hour,
+		DBMaxConnIdleTime:    30 * time.Minute,
+		DBHealthCheckPeriod:  time.Minute,
+		DBStatementCacheSize: 512,
+	}
+
+	tests := []struct {
+		name      string
+		edit      func(*config.Config)
+		wantCache int
+		wantMode  pgx.QueryExecMode
+		wantMax   int32
+		wantMin   int32
+	}{
+		{name: "direct", wantCache: 512, wantMode: pgx.QueryExecModeCacheStatement, wantMax: 20, wantMin: 2},
+		{
+			name:      "pgbouncer",
+			edit:      func(c *config.Config) { c.DBStatementCacheSize = 0 },
+			wantCache: 0, wantMode: pgx.QueryExecModeExec, wantMax: 20, wantMin: 2,
+		},
+		{
+			name:      "sized pool",
+			edit:      func(c *config.Config) { c.DBMaxConns, c.DBMinConns, c.DBStatementCacheSize = 50, 0, 64 },
+			wantCache: 64, wantMode: pgx.QueryExecModeCacheStatement, wantMax: 50, wantMin: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := base
+			if tt.edit != nil {
+				tt.edit(&cfg)
+			}
+			got, err := poolConfig(cfg, "db.internal", "6432")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got.ConnConfig.StatementCacheCapacity != tt.wantCache {
+				t.Errorf("StatementCacheCapacity = %d, want %d", got.ConnConfig.StatementCacheCapacity, tt.wantCache)
+			}
+			if got.ConnConfig.DefaultQueryExecMode != tt.wantMode {
+				t.Errorf("DefaultQueryExecMode = %v, want %v", got.ConnConfig.DefaultQueryExecMode, tt.wantMode)
+			}
+			if got.MaxConns != tt.wantMax || got.MinConns != tt.wantMin {
+				t.Errorf("pool size = %d..%d, want %d..%d", got.MinConns, got.MaxConns, tt.wantMin, tt.wantMax)
+			}
+			if got.MaxConnLifetime != time.Hour || got.MaxConnIdleTime != 30*time.Minute || got.HealthCheckPeriod != time.Minute {
+				t.Errorf("lifetime, idle time, health check = %v, %v, %v, want the configured ones",
+					got.MaxConnLifetime, got.MaxConnIdleTime, got.HealthCheckPeriod)
+			}
+
+			conn := got.ConnConfig
+			if conn.Host != "db.internal" || conn.Port != 6432 || conn.User != "app" || conn.Password != "p@ss/word" || conn.Database != "mydb" {
+				t.Errorf("connection = %s@%s:%d/%s, want app@db.internal:6432/mydb", conn.User, conn.Host, conn.Port, conn.Database)
+			}
+			if conn.TLSConfig != nil {
+				t.Error("TLS configured with sslmode=disable")
+			}
+		})
+	}
+}
+
+func TestPoolConfigTLS(t *testing.T) {
+	cfg := config.Config{DBSSLMode: "verify-full", DBMaxConns: 1}
+	got, err := poolConfig(cfg, "db.internal", "5432")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tls := got.ConnConfig.TLSConfig; tls == nil || tls.InsecureSkipVerify || tls.ServerName != "db.internal" {
+		t.Errorf("TLS config = %+v, want verification of db.internal", tls)
+	}
+
+	cfg.DBSSLRootCert = "/does/not/exist.pem"
+	if _, err := poolConfig(cfg, "db.internal", "5432"); err == nil {
+		t.Error("poolConfig accepted a missing sslrootcert")
+	}
+}
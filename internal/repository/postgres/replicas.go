@@ -0,0 +1,190 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
+
+	"github.com/infosec554/clean-archtectura/pkg/health"
+	"github.com/infosec554/clean-archtectura/pkg/metrics"
+)
+
+// primaryLSNQuery returns how far the primary has written its WAL
+const primaryLSNQuery = `SELECT pg_current_wal_lsn()::text`
+
+// replicaStateQuery reports whether a server is in recovery, whether its
+// WAL receiver is streaming from the primary, and its lag: the bytes of WAL
+// up to the primary's LSN in $1 that it has not replayed, NULL if it has
+// replayed none. A server that is not in recovery reports no lag.
+//
+// The age of the last replayed transaction is no measure of lag: it keeps
+// growing while the primary is idle, on replicas that are fully caught up.
+const replicaStateQuery = `
+	SELECT
+		pg_is_in_recovery(),
+		EXISTS (SELECT 1 FROM pg_stat_wal_receiver WHERE status = 'streaming'),
+		CASE
+			WHEN NOT pg_is_in_recovery() THEN 0
+			ELSE GREATEST(pg_wal_lsn_diff($1::pg_lsn, pg_last_wal_replay_lsn()), 0)
+		END::int8
+`
+
+// ReplicaSet routes reads that tolerate replication lag to the healthy
+// replicas in turn, and to the primary when there are none. Writes, and
+// reads that must see them, use the primary directly.
+type ReplicaSet struct {
+	primary  *sql.DB
+	replicas []*replica
+	next     atomic.Uint64
+	maxLag   int64 // bytes of WAL
+	period   time.Duration
+}
+
+type replica struct {
+	name    string
+	pool    *pgxpool.Pool
+	db      *sql.DB
+	healthy atomic.Bool
+}
+
+type primaryKey struct{}
+
+// WithPrimary sends reads made with the returned context to the primary,
+// for paths that must see their own writes
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// Read returns where a lag-tolerant read should run: the transaction in
+// ctx, the primary if ctx asks for it, or the next healthy replica
+func (rs *ReplicaSet) Read(ctx context.Context) DBTX {
+	if st, ok := ctx.Value(txKey{}).(*txState); ok {
+		return st.tx
+	}
+	if forced, _ := ctx.Value(primaryKey{}).(bool); forced || len(rs.replicas) == 0 {
+		return rs.primary
+	}
+
+	n := uint64(len(rs.replicas))
+	start := rs.next.Add(1)
+	for i := range n {
+		if r := rs.replicas[(start+i)%n]; r.healthy.Load() {
+			return r.db
+		}
+	}
+	return rs.primary
+}
+
+// Monitor checks the replicas every period until ctx is done, ejecting the
+// ones that fail or lag too far behind and restoring them once they recover
+func (rs *ReplicaSet) Monitor(ctx context.Context) error {
+	if len(rs.replicas) == 0 {
+		<-ctx.Done()
+		return nil
+	}
+
+	ticker := time.NewTicker(rs.period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			rs.checkAll(ctx)
+		}
+	}
+}
+
+// HealthChecks reports each replica; they are not critical, as reads fall
+// back to the primary
+func (rs *ReplicaSet) HealthChecks() []health.Check {
+	checks := make([]health.Check, 0, len(rs.replicas))
+	for _, r := range rs.replicas {
+		checks = append(checks, health.Check{Name: "postgres_replica_" + r.name, Fn: func(ctx context.Context) error {
+			return rs.probe(ctx, r)
+		}})
+	}
+	return checks
+}
+
+func (rs *ReplicaSet) checkAll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, r := range rs.replicas {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rs.check(ctx, r)
+		}()
+	}
+	wg.Wait()
+}
+
+func (rs *ReplicaSet) check(ctx context.Context, r *replica) {
+	ctx, cancel := context.WithTimeout(ctx, rs.period)
+	defer cancel()
+
+	err := rs.probe(ctx, r)
+	healthy := err == nil
+	if healthy {
+		metrics.DBReplicaHealthy.WithLabelValues(r.name).Set(1)
+	} else {
+		metrics.DBReplicaHealthy.WithLabelValues(r.name).Set(0)
+	}
+	if r.healthy.Swap(healthy) == healthy {
+		return
+	}
+
+	logger := zerolog.Ctx(ctx).With().Str("component", "postgres").Str("replica", r.name).Logger()
+	if healthy {
+		logger.Info().Msg("Replica receiving reads")
+	} else {
+		logger.Warn().Err(err).Msg("Replica ejected")
+	}
+}
+
+// probe fails for a replica that is unreachable, has lost its connection to
+// the primary or lags too far behind. A replica whose WAL receiver is down
+// stops advancing while looking idle, so it is ejected however small its
+// lag seems.
+func (rs *ReplicaSet) probe(ctx context.Context, r *replica) error {
+	var primaryLSN string
+	if err := rs.primary.QueryRowContext(ctx, primaryLSNQuery).Scan(&primaryLSN); err != nil {
+		return fmt.Errorf("read primary WAL position: %w", err)
+	}
+
+	var (
+		inRecovery, streaming bool
+		lag                   sql.NullInt64
+	)
+	if err := r.db.QueryRowContext(ctx, replicaStateQuery, primaryLSN).Scan(&inRecovery, &streaming, &lag); err != nil {
+		return err
+	}
+	if inRecovery && !streaming {
+		return errors.New("WAL receiver is not streaming from the primary")
+	}
+	if !lag.Valid {
+		return errors.New("replica has not replayed any WAL yet")
+	}
+
+	metrics.DBReplicaLag.WithLabelValues(r.name).Set(float64(lag.Int64))
+	if lag.Int64 > rs.maxLag {
+		return fmt.Errorf("replication lag of %d bytes exceeds %d", lag.Int64, rs.maxLag)
+	}
+	return nil
+}
+
+func (rs *ReplicaSet) close() error {
+	var errs []error
+	for _, r := range rs.replicas {
+		errs = append(errs, r.db.Close())
+		r.pool.Close()
+	}
+	return errors.Join(errs...)
+}
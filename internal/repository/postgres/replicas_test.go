@@ -0,0 +1,185 @@
+package postgres
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestReplicaSet(names ...string) (*ReplicaSet, *fakeDB, map[string]*fakeDB) {
+	primary, primaryDB := newFakeDB()
+	primary.answer(primaryLSNQuery, nil, "0/3000000")
+
+	rs := &ReplicaSet{primary: primaryDB, maxLag: 1 << 20, period: time.Second}
+	fakes := make(map[string]*fakeDB)
+	for _, name := range names {
+		f, db := newFakeDB()
+		fakes[name] = f
+		rs.replicas = append(rs.replicas, &replica{name: name, db: db})
+	}
+	return rs, primary, fakes
+}
+
+// target names the replica, or "primary", that db belongs to
+func target(rs *ReplicaSet, db DBTX) string {
+	if db == DBTX(rs.primary) {
+		return "primary"
+	}
+	for _, r := range rs.replicas {
+		if db == DBTX(r.db) {
+			return r.name
+		}
+	}
+	return "unknown"
+}
+
+func TestReadRoundRobin(t *testing.T) {
+	ctx := context.Background()
+	rs, _, _ := newTestReplicaSet("a", "b", "c")
+	for _, r := range rs.replicas {
+		r.healthy.Store(true)
+	}
+
+	seen := make(map[string]int)
+	for range 6 {
+		seen[target(rs, rs.Read(ctx))]++
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		if seen[name] != 2 {
+			t.Fatalf("reads per replica = %v, want 2 each", seen)
+		}
+	}
+
+	rs.replicas[1].healthy.Store(false)
+	for range 4 {
+		if got := target(rs, rs.Read(ctx)); got == "b" {
+			t.Fatal("read sent to an ejected replica")
+		}
+	}
+}
+
+func TestReadFallback(t *testing.T) {
+	ctx := context.Background()
+
+	none, _, _ := newTestReplicaSet()
+	if got := target(none, none.Read(ctx)); got != "primary" {
+		t.Fatalf("Read without replicas went to %s, want primary", got)
+	}
+
+	rs, _, _ := newTestReplicaSet("a", "b")
+	if got := target(rs, rs.Read(ctx)); got != "primary" {
+		t.Fatalf("Read with every replica ejected went to %s, want primary", got)
+	}
+
+	rs.replicas[0].healthy.Store(true)
+	st := &txState{}
+	if got := rs.Read(context.WithValue(ctx, txKey{}, st)); got != DBTX(st.tx) {
+		t.Fatalf("Read in a transaction went to %s, want the transaction", target(rs, got))
+	}
+}
+
+func TestReadWithPrimary(t *testing.T) {
+	ctx := context.Background()
+	rs, _, _ := newTestReplicaSet("a", "b")
+	for _, r := range rs.replicas {
+		r.healthy.Store(true)
+	}
+
+	for range 4 {
+		if got := target(rs, rs.Read(WithPrimary(ctx))); got != "primary" {
+			t.Fatalf("Read with WithPrimary went to %s, want primary", got)
+		}
+	}
+	if got := target(rs, rs.Read(ctx)); got == "primary" {
+		t.Fatal("Read without WithPrimary went to the primary while replicas are healthy")
+	}
+
+	// A transaction still wins, as its reads must see its own writes
+	st := &txState{}
+	if got := rs.Read(context.WithValue(WithPrimary(ctx), txKey{}, st)); got != DBTX(st.tx) {
+		t.Fatalf("Read in a transaction went to %s, want the transaction", target(rs, got))
+	}
+}
+
+// TestProbeLagArgs checks that the replica measures its lag against the
+// primary's current WAL position
+func TestProbeLagArgs(t *testing.T) {
+	rs, _, fakes := newTestReplicaSet("a")
+	fakes["a"].answer(replicaStateQuery, nil, true, true, int64(0))
+
+	if err := rs.probe(context.Background(), rs.replicas[0]); err != nil {
+		t.Fatal(err)
+	}
+	if args := fakes["a"].lastArgs("pg_wal_lsn_diff"); len(args) != 1 || args[0] != "0/3000000" {
+		t.Fatalf("replica state args = %v, want the primary LSN", args)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		primary error
+		err     error
+		values  []driver.Value
+		healthy bool
+	}{
+		{"caught up", nil, nil, []driver.Value{true, true, int64(0)}, true},
+		{"lag within limit", nil, nil, []driver.Value{true, true, int64(4096)}, true},
+		{"lag at limit", nil, nil, []driver.Value{true, true, int64(1 << 20)}, true},
+		{"lag over limit", nil, nil, []driver.Value{true, true, int64(1<<20 + 1)}, false},
+		{"receiver disconnected", nil, nil, []driver.Value{true, false, int64(0)}, false},
+		{"nothing replayed", nil, nil, []driver.Value{true, true, nil}, false},
+		{"not in recovery", nil, nil, []driver.Value{false, false, int64(0)}, true},
+		{"unreachable", nil, errors.New("connection refused"), nil, false},
+		{"primary unreachable", errors.New("connection refused"), nil, []driver.Value{true, true, int64(0)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs, primary, fakes := newTestReplicaSet("a")
+			if tt.primary != nil {
+				primary.answer(primaryLSNQuery, tt.primary)
+			}
+			fakes["a"].answer(replicaStateQuery, tt.err, tt.values...)
+
+			r := rs.replicas[0]
+			r.healthy.Store(!tt.healthy)
+			rs.check(context.Background(), r)
+			if got := r.healthy.Load(); got != tt.healthy {
+				t.Fatalf("healthy = %v, want %v", got, tt.healthy)
+			}
+		})
+	}
+}
+
+func TestCheckRestoresReplica(t *testing.T) {
+	ctx := context.Background()
+	rs, _, fakes := newTestReplicaSet("a")
+	r := rs.replicas[0]
+
+	fakes["a"].answer(replicaStateQuery, nil, true, false, int64(0))
+	rs.checkAll(ctx)
+	if r.healthy.Load() || target(rs, rs.Read(ctx)) != "primary" {
+		t.Fatal("disconnected replica kept receiving reads")
+	}
+
+	fakes["a"].answer(replicaStateQuery, nil, true, true, int64(0))
+	rs.checkAll(ctx)
+	if !r.healthy.Load() || target(rs, rs.Read(ctx)) != "a" {
+		t.Fatal("recovered replica was not restored")
+	}
+}
+
+func TestProbeHealthCheck(t *testing.T) {
+	rs, _, fakes := newTestReplicaSet("a")
+	fakes["a"].answer(replicaStateQuery, nil, true, true, int64(64<<20))
+
+	checks := rs.HealthChecks()
+	if len(checks) != 1 || checks[0].Name != "postgres_replica_a" {
+		t.Fatalf("HealthChecks = %+v", checks)
+	}
+	if err := checks[0].Fn(context.Background()); err == nil {
+		t.Fatal("lagging replica passed its health check")
+	}
+}
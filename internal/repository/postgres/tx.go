@@ -0,0 +1,211 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/rs/zerolog"
+
+	"github.com/infosec554/clean-archtectura/domain"
+	"github.com/infosec554/clean-archtectura/pkg/metrics"
+	"github.com/infosec554/clean-archtectura/pkg/tracing"
+)
+
+// Backoff between attempts of a retried transaction
+const (
+	retryBaseDelay = 10 * time.Millisecond
+	retryMaxDelay  = 500 * time.Millisecond
+)
+
+// DBTX runs statements: the pool, or the transaction carried by a context
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// TxOptions configures a transaction started by WithTxOptions
+type TxOptions struct {
+	Isolation sql.IsolationLevel
+	ReadOnly  bool
+
+	// MaxRetries is how many times the transaction is run again after a
+	// serialization failure or deadlock
+	MaxRetries int
+}
+
+type txKey struct{}
+
+// txState is the transaction carried by a context. It belongs to the
+// goroutine that started it and must not be shared.
+type txState struct {
+	tx          *sql.Tx
+	savepoints  int
+	afterCommit []func(context.Context)
+}
+
+// conn returns the transaction in ctx, or db outside of one
+func conn(ctx context.Context, db *sql.DB) DBTX {
+	if st, ok := ctx.Value(txKey{}).(*txState); ok {
+		return st.tx
+	}
+	return db
+}
+
+// InTx reports whether ctx carries a transaction
+func InTx(ctx context.Context) bool {
+	_, ok := ctx.Value(txKey{}).(*txState)
+	return ok
+}
+
+// AfterCommit runs fn once the transaction in ctx has committed, or right
+// away outside of one. fn is dropped if the transaction, or the savepoint
+// it was registered in, rolls back.
+func AfterCommit(ctx context.Context, fn func(context.Context)) {
+	if st, ok := ctx.Value(txKey{}).(*txState); ok {
+		st.afterCommit = append(st.afterCommit, fn)
+		return
+	}
+	fn(ctx)
+}
+
+// WithTx runs fn in a transaction with the configured default options.
+// Repository calls made with the context passed to fn run on the
+// transaction; it commits if fn returns nil and rolls back otherwise.
+//
+// Called inside a transaction, WithTx runs fn in a savepoint instead, so a
+// failing fn undoes only its own changes.
+func (s *Store) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return s.WithTxOptions(ctx, s.txOptions, fn)
+}
+
+// WithTxOptions is WithTx with explicit options. fn may run more than once
+// and must not have side effects outside the transaction; use AfterCommit
+// for those. Inside a transaction the options are ignored, as a savepoint
+// shares the outer transaction.
+func (s *Store) WithTxOptions(ctx context.Context, opts TxOptions, fn func(ctx context.Context) error) (err error) {
+	if st, ok := ctx.Value(txKey{}).(*txState); ok {
+		return st.savepoint(ctx, fn)
+	}
+
+	ctx, span := tracing.Start(ctx, "postgres.WithTx")
+	defer tracing.End(span, &err)
+
+	for attempt := 0; ; attempt++ {
+		err := s.runTx(ctx, opts, fn)
+		if err == nil || !retryable(err) {
+			return err
+		}
+		if attempt >= opts.MaxRetries {
+			return domain.Wrap(domain.ErrConflict, "the record was changed concurrently, please retry", err)
+		}
+
+		metrics.DBTxRetries.Inc()
+		zerolog.Ctx(ctx).Debug().Ctx(ctx).Err(err).Int("attempt", attempt+1).Msg("Retrying transaction")
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff(attempt)):
+		}
+	}
+}
+
+func (s *Store) runTx(ctx context.Context, opts TxOptions, fn func(ctx context.Context) error) error {
+	tx, err := s.DB.BeginTx(ctx, &sql.TxOptions{Isolation: opts.Isolation, ReadOnly: opts.ReadOnly})
+	if err != nil {
+		return translateError(err, "transaction")
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	st := &txState{tx: tx}
+	if err := fn(context.WithValue(ctx, txKey{}, st)); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+			zerolog.Ctx(ctx).Error().Ctx(ctx).Err(rbErr).Msg("Error rolling back transaction")
+		}
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return translateError(err, "transaction")
+	}
+
+	for _, fn := range st.afterCommit {
+		fn(ctx)
+	}
+	return nil
+}
+
+func (st *txState) savepoint(ctx context.Context, fn func(ctx context.Context) error) error {
+	st.savepoints++
+	name := fmt.Sprintf("sp_%d", st.savepoints)
+	if _, err := st.tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return translateError(err, "transaction")
+	}
+
+	pending := len(st.afterCommit)
+	rollback := func() error {
+		st.afterCommit = st.afterCommit[:pending]
+		_, err := st.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name)
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(ctx); err != nil {
+		if rbErr := rollback(); rbErr != nil {
+			zerolog.Ctx(ctx).Error().Ctx(ctx).Err(rbErr).Str("savepoint", name).Msg("Error rolling back to savepoint")
+		}
+		return err
+	}
+	if _, err := st.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name); err != nil {
+		return translateError(err, "transaction")
+	}
+	return nil
+}
+
+// retryable reports whether err is a conflict with a concurrent transaction
+// that running the transaction again may resolve
+func retryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return pgErr.Code == pgerrcode.SerializationFailure || pgErr.Code == pgerrcode.DeadlockDetected
+}
+
+// backoff doubles the delay per attempt with full jitter, so transactions
+// that conflicted with each other do not collide again
+func backoff(attempt int) time.Duration {
+	delay := retryMaxDelay
+	if attempt < 6 {
+		delay = min(retryBaseDelay<<attempt, retryMaxDelay)
+	}
+	return rand.N(delay) + time.Millisecond
+}
+
+// isolationLevel maps the DB_TX_ISOLATION setting to a database/sql level
+func isolationLevel(name string) sql.IsolationLevel {
+	switch name {
+	case "repeatable_read":
+		return sql.LevelRepeatableRead
+	case "serializable":
+		return sql.LevelSerializable
+	}
+	return sql.LevelReadCommitted
+}
@@ -0,0 +1,201 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/infosec554/clean-archtectura/domain"
+)
+
+var errFailed = errors.New("failed")
+
+func newTestStore(maxRetries int) (*Store, *fakeDB) {
+	f, db := newFakeDB()
+	return &Store{DB: db, txOptions: TxOptions{MaxRetries: maxRetries}}, f
+}
+
+func TestWithTx(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		wantLog   []string
+		committed bool
+	}{
+		{"commit", nil, []string{"BEGIN", "INSERT", "COMMIT"}, true},
+		{"rollback", errFailed, []string{"BEGIN", "INSERT", "ROLLBACK"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, f := newTestStore(0)
+			var ran bool
+
+			err := store.WithTx(context.Background(), func(ctx context.Context) error {
+				if !InTx(ctx) {
+					t.Error("InTx = false inside WithTx")
+				}
+				if _, err := conn(ctx, store.DB).ExecContext(ctx, "INSERT"); err != nil {
+					return err
+				}
+				AfterCommit(ctx, func(context.Context) { ran = true })
+				if ran {
+					t.Error("AfterCommit ran before the commit")
+				}
+				return tt.err
+			})
+
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("WithTx error = %v, want %v", err, tt.err)
+			}
+			if got := f.statements(); !slices.Equal(got, tt.wantLog) {
+				t.Fatalf("statements = %q, want %q", got, tt.wantLog)
+			}
+			if ran != tt.committed {
+				t.Fatalf("AfterCommit ran = %v, want %v", ran, tt.committed)
+			}
+		})
+	}
+}
+
+func TestAfterCommitOutsideTx(t *testing.T) {
+	var ran bool
+	AfterCommit(context.Background(), func(context.Context) { ran = true })
+	if !ran {
+		t.Fatal("AfterCommit outside a transaction did not run right away")
+	}
+}
+
+func TestSavepoint(t *testing.T) {
+	store, f := newTestStore(0)
+	var ran []string
+	after := func(name string) func(context.Context) {
+		return func(context.Context) { ran = append(ran, name) }
+	}
+
+	err := store.WithTx(context.Background(), func(ctx context.Context) error {
+		AfterCommit(ctx, after("outer"))
+
+		err := store.WithTx(ctx, func(ctx context.Context) error {
+			AfterCommit(ctx, after("failed savepoint"))
+			return errFailed
+		})
+		if !errors.Is(err, errFailed) {
+			t.Errorf("savepoint error = %v, want %v", err, errFailed)
+		}
+
+		return store.WithTx(ctx, func(ctx context.Context) error {
+			AfterCommit(ctx, after("released savepoint"))
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantLog := []string{
+		"BEGIN",
+		"SAVEPOINT sp_1", "ROLLBACK TO SAVEPOINT sp_1",
+		"SAVEPOINT sp_2", "RELEASE SAVEPOINT sp_2",
+		"COMMIT",
+	}
+	if got := f.statements(); !slices.Equal(got, wantLog) {
+		t.Fatalf("statements = %q, want %q", got, wantLog)
+	}
+	if want := []string{"outer", "released savepoint"}; !slices.Equal(ran, want) {
+		t.Fatalf("after commit ran %q, want %q", ran, want)
+	}
+}
+
+func TestSavepointPanic(t *testing.T) {
+	store, f := newTestStore(0)
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("panic was swallowed")
+		}
+		want := []string{"BEGIN", "SAVEPOINT sp_1", "ROLLBACK TO SAVEPOINT sp_1", "ROLLBACK"}
+		if got := f.statements(); !slices.Equal(got, want) {
+			t.Fatalf("statements = %q, want %q", got, want)
+		}
+	}()
+
+	_ = store.WithTx(context.Background(), func(ctx context.Context) error {
+		return store.WithTx(ctx, func(context.Context) error {
+			panic("boom")
+		})
+	})
+}
+
+func TestWithTxRetry(t *testing.T) {
+	serialization := &pgconn.PgError{Code: pgerrcode.SerializationFailure}
+	deadlock := &pgconn.PgError{Code: pgerrcode.DeadlockDetected}
+	unique := &pgconn.PgError{Code: pgerrcode.UniqueViolation}
+
+	tests := []struct {
+		name     string
+		failures []error
+		retries  int
+		wantRuns int
+		want     error
+	}{
+		{"serialization failure", []error{serialization}, 3, 2, nil},
+		{"deadlock", []error{deadlock, deadlock}, 3, 3, nil},
+		{"retries exhausted", []error{serialization, serialization, serialization}, 2, 3, domain.ErrConflict},
+		{"no retries", []error{deadlock}, 0, 1, domain.ErrConflict},
+		{"other errors are not retried", []error{unique}, 3, 1, unique},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, f := newTestStore(tt.retries)
+			var runs, afterCommits int
+
+			err := store.WithTx(context.Background(), func(ctx context.Context) error {
+				runs++
+				AfterCommit(ctx, func(context.Context) { afterCommits++ })
+				if runs <= len(tt.failures) {
+					return tt.failures[runs-1]
+				}
+				return nil
+			})
+
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("WithTx error = %v, want %v", err, tt.want)
+			}
+			if runs != tt.wantRuns {
+				t.Fatalf("fn ran %d times, want %d", runs, tt.wantRuns)
+			}
+			if got := slices.Index(f.statements(), "COMMIT") >= 0; got != (tt.want == nil) {
+				t.Fatalf("committed = %v, want %v", got, tt.want == nil)
+			}
+			wantAfterCommits := 0
+			if tt.want == nil {
+				wantAfterCommits = 1
+			}
+			if afterCommits != wantAfterCommits {
+				t.Fatalf("after commit ran %d times, want %d", afterCommits, wantAfterCommits)
+			}
+		})
+	}
+}
+
+func TestWithTxRetryStopsOnCancel(t *testing.T) {
+	store, _ := newTestStore(5)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var runs int
+	err := store.WithTx(ctx, func(context.Context) error {
+		runs++
+		cancel()
+		return &pgconn.PgError{Code: pgerrcode.SerializationFailure}
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("WithTx error = %v, want context.Canceled", err)
+	}
+	if runs != 1 {
+		t.Fatalf("fn ran %d times after cancel, want 1", runs)
+	}
+}
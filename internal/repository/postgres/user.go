@@ -3,28 +3,41 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"html"
+	"strings"
+	"unicode"
 
 	"github.com/google/uuid"
 	"github.com/rs/zerolog"
 
+	errs "github.com/infosec554/clean-archtectura/domain"
 	domain "github.com/infosec554/clean-archtectura/domain/users"
+	"github.com/infosec554/clean-archtectura/pkg/tracing"
 )
 
+// UserRepository reads and writes users on the primary, so a user sees
+// their own changes; only Search goes to the replicas
 type UserRepository struct {
-	DB     *sql.DB
-	logger zerolog.Logger
+	DB       *sql.DB
+	replicas *ReplicaSet
+	logger   zerolog.Logger
 }
 
-func NewUserRepository(db *sql.DB, logger zerolog.Logger) *UserRepository {
+func NewUserRepository(db *sql.DB, replicas *ReplicaSet, logger zerolog.Logger) *UserRepository {
 	return &UserRepository{
-		DB:     db,
-		logger: logger.With().Str("repository", "user").Logger(),
+		DB:       db,
+		replicas: replicas,
+		logger:   logger.With().Str("repository", "user").Logger(),
 	}
 }
 
 // Create ...
-func (r *UserRepository) Create(ctx context.Context, req *domain.CreateUser) (string, error) {
+func (r *UserRepository) Create(ctx context.Context, req *domain.CreateUser) (_ string, err error) {
+	ctx, span := tracing.Start(ctx, "UserRepository.Create")
+	defer tracing.End(span, &err)
+
 	var id uuid.UUID
 	query := `
 		INSERT INTO users (first_name, last_name, email, password)
@@ -32,7 +45,7 @@ func (r *UserRepository) Create(ctx context.Context, req *domain.CreateUser) (st
 		RETURNING id
 	`
 
-	err := r.DB.QueryRowContext(ctx, query,
+	err = conn(ctx, r.DB).QueryRowContext(ctx, query,
 		req.FirstName,
 		req.LastName,
 		req.Email,
@@ -40,84 +53,86 @@ func (r *UserRepository) Create(ctx context.Context, req *domain.CreateUser) (st
 	).Scan(&id)
 
 	if err != nil {
-		r.logger.Error().Err(err).Msg("Error creating user")
-		return "", err
+		r.logger.Error().Ctx(ctx).Err(err).Msg("Error creating user")
+		return "", translateError(err, "user")
 	}
 
 	return id.String(), nil
 }
 
 // GetByID retrieves a single user by ID
-func (r *UserRepository) GetByID(ctx context.Context, id uuid.UUID) (domain.User, error) {
-	var (
-		user      domain.User
-		email     sql.NullString
-		password  sql.NullString
-		createdAt sql.NullTime
-		updatedAt sql.NullTime
-	)
+func (r *UserRepository) GetByID(ctx context.Context, id uuid.UUID) (_ domain.User, err error) {
+	ctx, span := tracing.Start(ctx, "UserRepository.GetByID")
+	defer tracing.End(span, &err)
 
-	query := `
-		SELECT id, first_name, last_name, email, password, email_verified, created_at, updated_at
-		FROM users
-		WHERE id = $1
-	`
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1`
 
-	if err := r.DB.QueryRowContext(ctx, query, id).Scan(
-		&user.ID,
-		&user.FirstName,
-		&user.LastName,
-		&email,
-		&password,
-		&user.EmailVerified,
-		&createdAt,
-		&updatedAt,
-	); err != nil {
+	user, err := scanUser(conn(ctx, r.DB).QueryRowContext(ctx, query, id))
+	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			r.logger.Warn().Str("user_id", id.String()).Msg("User not found")
-			return domain.User{}, errors.New("user not found")
+			r.logger.Warn().Ctx(ctx).Str("user_id", id.String()).Msg("User not found")
+			return domain.User{}, errs.NotFound("user not found")
 		}
-		r.logger.Error().Err(err).Str("user_id", id.String()).Msg("Error scanning user by ID")
-		return domain.User{}, err
+		r.logger.Error().Ctx(ctx).Err(err).Str("user_id", id.String()).Msg("Error scanning user by ID")
+		return domain.User{}, translateError(err, "user")
 	}
 
-	if email.Valid {
-		user.Email = &email.String
-	}
-	if password.Valid {
-		user.Password = &password.String
-	}
-	if createdAt.Valid {
-		user.CreatedAt = createdAt.Time
+	return user, nil
+}
+
+// GetByIDForUpdate retrieves a user by ID and locks the row until the
+// transaction in ctx ends, so a read-check-write on it cannot interleave
+// with another. It must be called inside a transaction.
+func (r *UserRepository) GetByIDForUpdate(ctx context.Context, id uuid.UUID) (_ domain.User, err error) {
+	ctx, span := tracing.Start(ctx, "UserRepository.GetByIDForUpdate")
+	defer tracing.End(span, &err)
+
+	if !InTx(ctx) {
+		return domain.User{}, errors.New("GetByIDForUpdate called outside a transaction")
 	}
-	if updatedAt.Valid {
-		user.UpdatedAt = updatedAt.Time
+
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1 FOR UPDATE`
+
+	user, err := scanUser(conn(ctx, r.DB).QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			r.logger.Warn().Ctx(ctx).Str("user_id", id.String()).Msg("User not found")
+			return domain.User{}, errs.NotFound("user not found")
+		}
+		r.logger.Error().Ctx(ctx).Err(err).Str("user_id", id.String()).Msg("Error locking user by ID")
+		return domain.User{}, translateError(err, "user")
 	}
 
 	return user, nil
 }
 
 // SetEmailVerified marks the user's email as verified
-func (r *UserRepository) SetEmailVerified(ctx context.Context, email string) error {
+func (r *UserRepository) SetEmailVerified(ctx context.Context, email string) (err error) {
+	ctx, span := tracing.Start(ctx, "UserRepository.SetEmailVerified")
+	defer tracing.End(span, &err)
+
 	query := `UPDATE users SET email_verified = TRUE, updated_at = NOW() WHERE email = $1`
 
-	result, err := r.DB.ExecContext(ctx, query, email)
+	result, err := conn(ctx, r.DB).ExecContext(ctx, query, email)
 	if err != nil {
-		r.logger.Error().Err(err).Str("email", email).Msg("Error setting email verified")
-		return err
+		r.logger.Error().Ctx(ctx).Err(err).Str("email", email).Msg("Error setting email verified")
+		return translateError(err, "user")
 	}
 
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected == 0 {
-		return errors.New("user not found")
+		return errs.NotFound("user not found")
 	}
 
-	r.logger.Info().Str("email", email).Msg("Email verified successfully")
+	r.logger.Info().Ctx(ctx).Str("email", email).Msg("Email verified successfully")
 	return nil
 }
 
 // Update updates an existing user
-func (r *UserRepository) Update(ctx context.Context, req *domain.UpdateUser, passwordHash string) (string, error) {
+func (r *UserRepository) Update(ctx context.Context, req *domain.UpdateUser, passwordHash string) (_ string, err error) {
+	ctx, span := tracing.Start(ctx, "UserRepository.Update")
+	defer tracing.End(span, &err)
+
 	query := `
 		UPDATE users
 		SET
@@ -129,7 +144,7 @@ func (r *UserRepository) Update(ctx context.Context, req *domain.UpdateUser, pas
 		WHERE id = $5
 	`
 
-	result, err := r.DB.ExecContext(ctx, query,
+	result, err := conn(ctx, r.DB).ExecContext(ctx, query,
 		req.FirstName,
 		req.LastName,
 		req.Email,
@@ -137,80 +152,358 @@ func (r *UserRepository) Update(ctx context.Context, req *domain.UpdateUser, pas
 		req.ID,
 	)
 	if err != nil {
-		r.logger.Error().Err(err).Str("user_id", req.ID.String()).Msg("Error updating user")
-		return "", err
+		r.logger.Error().Ctx(ctx).Err(err).Str("user_id", req.ID.String()).Msg("Error updating user")
+		return "", translateError(err, "user")
 	}
 
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected == 0 {
-		r.logger.Warn().Str("user_id", req.ID.String()).Msg("User not found for update")
-		return "", errors.New("user not found")
+		r.logger.Warn().Ctx(ctx).Str("user_id", req.ID.String()).Msg("User not found for update")
+		return "", errs.NotFound("user not found")
 	}
 
-	r.logger.Info().Str("user_id", req.ID.String()).Msg("User updated successfully")
+	r.logger.Info().Ctx(ctx).Str("user_id", req.ID.String()).Msg("User updated successfully")
 	return req.ID.String(), nil
 }
 
 // Delete removes a user
-func (r *UserRepository) Delete(ctx context.Context, id uuid.UUID) error {
+func (r *UserRepository) Delete(ctx context.Context, id uuid.UUID) (err error) {
+	ctx, span := tracing.Start(ctx, "UserRepository.Delete")
+	defer tracing.End(span, &err)
+
 	query := `DELETE FROM users WHERE id = $1`
 
-	result, err := r.DB.ExecContext(ctx, query, id)
+	result, err := conn(ctx, r.DB).ExecContext(ctx, query, id)
 	if err != nil {
-		r.logger.Error().Err(err).Str("user_id", id.String()).Msg("Error deleting user")
-		return err
+		r.logger.Error().Ctx(ctx).Err(err).Str("user_id", id.String()).Msg("Error deleting user")
+		return translateError(err, "user")
 	}
 
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected == 0 {
-		r.logger.Warn().Str("user_id", id.String()).Msg("User not found for deletion")
-		return errors.New("user not found")
+		r.logger.Warn().Ctx(ctx).Str("user_id", id.String()).Msg("User not found for deletion")
+		return errs.NotFound("user not found")
 	}
 
-	r.logger.Info().Str("user_id", id.String()).Msg("User deleted successfully")
+	r.logger.Info().Ctx(ctx).Str("user_id", id.String()).Msg("User deleted successfully")
 	return nil
 }
 
 // GetByEmail retrieves a single user by email
-func (r *UserRepository) GetByEmail(ctx context.Context, email string) (domain.User, error) {
-	var (
-		user      domain.User
-		dbEmail   sql.NullString
-		password  sql.NullString
-		createdAt sql.NullTime
-		updatedAt sql.NullTime
+func (r *UserRepository) GetByEmail(ctx context.Context, email string) (_ domain.User, err error) {
+	ctx, span := tracing.Start(ctx, "UserRepository.GetByEmail")
+	defer tracing.End(span, &err)
+
+	query := `SELECT ` + userColumns + ` FROM users WHERE email = $1`
+
+	user, err := scanUser(conn(ctx, r.DB).QueryRowContext(ctx, query, email))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			r.logger.Warn().Ctx(ctx).Str("email", email).Msg("User not found")
+			return domain.User{}, errs.NotFound("user not found")
+		}
+		r.logger.Error().Ctx(ctx).Err(err).Str("email", email).Msg("Error scanning user by email")
+		return domain.User{}, translateError(err, "user")
+	}
+
+	return user, nil
+}
+
+// GetCredentials retrieves a user by email together with the password
+// hash. It always reads the primary; callers must not cache the result.
+func (r *UserRepository) GetCredentials(ctx context.Context, email string) (_ domain.User, err error) {
+	ctx, span := tracing.Start(ctx, "UserRepository.GetCredentials")
+	defer tracing.End(span, &err)
+
+	query := `SELECT ` + userColumns + ` FROM users WHERE email = $1`
+
+	user, err := scanUser(conn(ctx, r.DB).QueryRowContext(ctx, query, email))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.User{}, errs.NotFound("user not found")
+		}
+		r.logger.Error().Ctx(ctx).Err(err).Msg("Error scanning user credentials")
+		return domain.User{}, translateError(err, "user")
+	}
+
+	return user, nil
+}
+
+// SetStatus records an account status transition
+func (r *UserRepository) SetStatus(ctx context.Context, change domain.StatusChange) (err error) {
+	ctx, span := tracing.Start(ctx, "UserRepository.SetStatus")
+	defer tracing.End(span, &err)
+
+	query := `
+		UPDATE users
+		SET
+			status = $1,
+			status_reason = NULLIF($2, ''),
+			status_changed_by = $3,
+			status_changed_at = NOW(),
+			suspended_until = $4,
+			updated_at = NOW()
+		WHERE id = $5
+	`
+
+	result, err := conn(ctx, r.DB).ExecContext(ctx, query,
+		change.Status,
+		change.Reason,
+		change.ChangedBy,
+		change.SuspendedUntil,
+		change.UserID,
 	)
+	if err != nil {
+		r.logger.Error().Ctx(ctx).Err(err).Str("user_id", change.UserID.String()).Msg("Error setting account status")
+		return translateError(err, "user")
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		r.logger.Warn().Ctx(ctx).Str("user_id", change.UserID.String()).Msg("User not found for status change")
+		return errs.NotFound("user not found")
+	}
+
+	r.logger.Info().Ctx(ctx).
+		Str("user_id", change.UserID.String()).
+		Str("status", string(change.Status)).
+		Str("changed_by", change.ChangedBy.String()).
+		Msg("Account status changed")
+	return nil
+}
+
+// MergePreferences applies a partial update to the preferences document and
+// returns the result. The merge happens in the UPDATE itself, so concurrent
+// updates of different keys do not overwrite each other.
+func (r *UserRepository) MergePreferences(ctx context.Context, id uuid.UUID, patch domain.UpdatePreferencesRequest) (_ domain.Preferences, err error) {
+	ctx, span := tracing.Start(ctx, "UserRepository.MergePreferences")
+	defer tracing.End(span, &err)
+
+	payload, err := json.Marshal(patch)
+	if err != nil {
+		return domain.Preferences{}, err
+	}
 
+	// notifications is merged one level deeper, so turning off one channel
+	// keeps the others
 	query := `
-		SELECT id, first_name, last_name, email, password, email_verified, created_at, updated_at
-		FROM users
-		WHERE email = $1
+		UPDATE users SET
+			preferences = (preferences || ($1::jsonb - 'notifications'))
+				|| jsonb_build_object('notifications',
+					COALESCE(preferences->'notifications', '{}'::jsonb) || COALESCE($1::jsonb->'notifications', '{}'::jsonb)),
+			updated_at = NOW()
+		WHERE id = $2
+		RETURNING preferences`
+
+	var merged []byte
+	err = conn(ctx, r.DB).QueryRowContext(ctx, query, payload, id).Scan(&merged)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Preferences{}, errs.NotFound("user not found")
+	}
+	if err != nil {
+		r.logger.Error().Ctx(ctx).Err(err).Str("user_id", id.String()).Msg("Error updating preferences")
+		return domain.Preferences{}, translateError(err, "user")
+	}
+
+	return decodePreferences(merged)
+}
+
+// Search finds users by full-text prefix match and trigram similarity over
+// first name, last name and email. It returns one page of ranked results and the total count.
+// Results may lag recent changes, as it reads from a replica when one is healthy.
+func (r *UserRepository) Search(ctx context.Context, text string, limit, offset int) (_ []domain.UserSearchHit, _ int, err error) {
+	ctx, span := tracing.Start(ctx, "UserRepository.Search")
+	defer tracing.End(span, &err)
+
+	query := searchFrom + `
+		SELECT ` + userColumns + `,
+			ts_rank(u.search_vector, q.tsq) + GREATEST(
+				word_similarity(q.raw, u.first_name),
+				word_similarity(q.raw, u.last_name),
+				similarity(q.raw, COALESCE(u.email, ''))
+			) AS rank,
+			ts_headline('simple', u.first_name, q.tsq, $5),
+			ts_headline('simple', u.last_name, q.tsq, $5),
+			ts_headline('simple', COALESCE(u.email, ''), q.tsq, $5)
+		FROM users u, q
+		WHERE ` + searchMatch + `
+		ORDER BY rank DESC, u.created_at DESC
+		LIMIT $3 OFFSET $4
 	`
 
-	if err := r.DB.QueryRowContext(ctx, query, email).Scan(
+	db := r.replicas.Read(ctx)
+	tsq := toPrefixTSQuery(text)
+	rows, err := db.QueryContext(ctx, query, tsq, text, limit, offset, headlineOptions)
+	if err != nil {
+		r.logger.Error().Ctx(ctx).Err(err).Str("query", text).Msg("Error searching users")
+		return nil, 0, translateError(err, "user")
+	}
+	defer rows.Close()
+
+	var list []domain.UserSearchHit
+	for rows.Next() {
+		var hit domain.UserSearchHit
+		hit.User, err = scanUser(rows,
+			&hit.Rank,
+			&hit.Highlight.FirstName,
+			&hit.Highlight.LastName,
+			&hit.Highlight.Email,
+		)
+		if err != nil {
+			r.logger.Error().Ctx(ctx).Err(err).Msg("Error scanning user search result")
+			return nil, 0, translateError(err, "user")
+		}
+		var email string
+		if hit.User.Email != nil {
+			email = *hit.User.Email
+		}
+		hit.Highlight = domain.UserHighlight{
+			FirstName: highlight(hit.Highlight.FirstName, hit.User.FirstName),
+			LastName:  highlight(hit.Highlight.LastName, hit.User.LastName),
+			Email:     highlight(hit.Highlight.Email, email),
+		}
+		list = append(list, hit)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, translateError(err, "user")
+	}
+
+	if total, ok := pageTotal(offset, limit, len(list)); ok {
+		return list, total, nil
+	}
+
+	var total int
+	countQuery := searchFrom + `SELECT COUNT(*) FROM users u, q WHERE ` + searchMatch
+	if err := db.QueryRowContext(ctx, countQuery, tsq, text).Scan(&total); err != nil {
+		r.logger.Error().Ctx(ctx).Err(err).Str("query", text).Msg("Error counting user search results")
+		return nil, 0, translateError(err, "user")
+	}
+	return list, total, nil
+}
+
+// searchFrom binds the search text: $1 is the prefix tsquery, $2 the raw text
+const searchFrom = `
+	WITH q AS (
+		SELECT to_tsquery('simple', $1) AS tsq, $2::text AS raw
+	)
+`
+
+const searchMatch = `
+	u.search_vector @@ q.tsq
+		OR q.raw <% u.first_name
+		OR q.raw <% u.last_name
+		OR u.email % q.raw
+`
+
+// ts_headline marks matches with private-use characters rather than tags,
+// as its output is not escaped; highlight turns them into <mark> after
+// escaping the text
+const (
+	markStart = "\uE000"
+	markStop  = "\uE001"
+
+	headlineOptions = `StartSel="` + markStart + `", StopSel="` + markStop + `", HighlightAll=true`
+)
+
+var markTags = strings.NewReplacer(markStart, "<mark>", markStop, "</mark>")
+
+// highlight HTML-escapes a headline of raw and wraps its matches in <mark>.
+// Text that contains a marker itself is returned escaped, without highlights.
+func highlight(headline, raw string) string {
+	if strings.ContainsAny(raw, markStart+markStop) {
+		return html.EscapeString(raw)
+	}
+	return markTags.Replace(html.EscapeString(headline))
+}
+
+// pageTotal works out the total from a page that is neither full nor empty;
+// otherwise the total has to be counted
+func pageTotal(offset, limit, n int) (int, bool) {
+	if n > 0 && n < limit {
+		return offset + n, true
+	}
+	if n == 0 && offset == 0 {
+		return 0, true
+	}
+	return 0, false
+}
+
+// toPrefixTSQuery turns free text into a tsquery where every word is matched as a prefix
+func toPrefixTSQuery(text string) string {
+	terms := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '@' && r != '.' && r != '_' && r != '-'
+	})
+
+	parts := make([]string, 0, len(terms))
+	for _, t := range terms {
+		parts = append(parts, "'"+strings.ToLower(t)+"':*")
+	}
+	return strings.Join(parts, " & ")
+}
+
+const userColumns = `
+	id, first_name, last_name, email, password, email_verified, role,
+	status, status_reason, status_changed_by, status_changed_at, suspended_until,
+	preferences, created_at, updated_at
+`
+
+// scanUser scans the userColumns of a row, followed by any extra destinations
+func scanUser(row rowScanner, extra ...any) (domain.User, error) {
+	var (
+		user            domain.User
+		email           sql.NullString
+		password        sql.NullString
+		statusReason    sql.NullString
+		statusChangedBy uuid.NullUUID
+		statusChangedAt sql.NullTime
+		suspendedUntil  sql.NullTime
+		preferences     []byte
+		createdAt       sql.NullTime
+		updatedAt       sql.NullTime
+	)
+
+	dest := []any{
 		&user.ID,
 		&user.FirstName,
 		&user.LastName,
-		&dbEmail,
+		&email,
 		&password,
 		&user.EmailVerified,
+		&user.Role,
+		&user.Status,
+		&statusReason,
+		&statusChangedBy,
+		&statusChangedAt,
+		&suspendedUntil,
+		&preferences,
 		&createdAt,
 		&updatedAt,
-	); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			r.logger.Warn().Str("email", email).Msg("User not found")
-			return domain.User{}, errors.New("user not found")
-		}
-		r.logger.Error().Err(err).Str("email", email).Msg("Error scanning user by email")
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
 		return domain.User{}, err
 	}
 
-	if dbEmail.Valid {
-		user.Email = &dbEmail.String
+	if email.Valid {
+		user.Email = &email.String
 	}
 	if password.Valid {
 		user.Password = &password.String
 	}
+	user.StatusReason = statusReason.String
+	if statusChangedBy.Valid {
+		user.StatusChangedBy = &statusChangedBy.UUID
+	}
+	if statusChangedAt.Valid {
+		user.StatusChangedAt = &statusChangedAt.Time
+	}
+	if suspendedUntil.Valid {
+		user.SuspendedUntil = &suspendedUntil.Time
+	}
+	prefs, err := decodePreferences(preferences)
+	if err != nil {
+		return domain.User{}, err
+	}
+	user.Preferences = prefs
 	if createdAt.Valid {
 		user.CreatedAt = createdAt.Time
 	}
@@ -220,3 +513,15 @@ func (r *UserRepository) GetByEmail(ctx context.Context, email string) (domain.U
 
 	return user, nil
 }
+
+// decodePreferences reads a stored preferences document; keys missing from it
+// keep their default values
+func decodePreferences(raw []byte) (domain.Preferences, error) {
+	prefs := domain.DefaultPreferences()
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &prefs); err != nil {
+			return domain.Preferences{}, err
+		}
+	}
+	return prefs, nil
+}
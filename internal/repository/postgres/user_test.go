@@ -0,0 +1,74 @@
+package postgres
+
+import "testing"
+
+func TestToPrefixTSQuery(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"aziz", "'aziz':*"},
+		{"Aziz  Karimov", "'aziz':* & 'karimov':*"},
+		{"aziz@example.com", "'aziz@example.com':*"},
+		{"o'brien", "'o':* & 'brien':*"},
+		{"a|b & !c", "'a':* & 'b':* & 'c':*"},
+		{"  ", ""},
+	}
+	for _, tt := range tests {
+		if got := toPrefixTSQuery(tt.text); got != tt.want {
+			t.Errorf("toPrefixTSQuery(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		name     string
+		headline string
+		raw      string
+		want     string
+	}{
+		{"match", markStart + "Aziz" + markStop, "Aziz", "<mark>Aziz</mark>"},
+		{"no match", "Karimov", "Karimov", "Karimov"},
+		{
+			"markup in the name is escaped",
+			"<img src=x onerror=alert(1)> " + markStart + "Aziz" + markStop,
+			"<img src=x onerror=alert(1)> Aziz",
+			"&lt;img src=x onerror=alert(1)&gt; <mark>Aziz</mark>",
+		},
+		{"entities", markStart + "Tom" + markStop + " & \"Jerry\"", `Tom & "Jerry"`, "<mark>Tom</mark> &amp; &#34;Jerry&#34;"},
+		{
+			"markers in the name disable highlighting",
+			markStart + "x" + markStop + markStop + "<b>",
+			"x" + markStop + "<b>",
+			"x" + markStop + "&lt;b&gt;",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := highlight(tt.headline, tt.raw); got != tt.want {
+				t.Errorf("highlight = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPageTotal(t *testing.T) {
+	tests := []struct {
+		offset, limit, n int
+		total            int
+		known            bool
+	}{
+		{0, 20, 0, 0, true},
+		{0, 20, 5, 5, true},
+		{40, 20, 3, 43, true},
+		{0, 20, 20, 0, false},  // a full page may have more after it
+		{100, 20, 0, 0, false}, // past the end: the count is still wanted
+	}
+	for _, tt := range tests {
+		total, known := pageTotal(tt.offset, tt.limit, tt.n)
+		if total != tt.total || known != tt.known {
+			t.Errorf("pageTotal(%d, %d, %d) = %d, %t; want %d, %t", tt.offset, tt.limit, tt.n, total, known, tt.total, tt.known)
+		}
+	}
+}
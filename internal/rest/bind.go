@@ -0,0 +1,35 @@
+package rest
+
+import (
+	"github.com/labstack/echo/v4"
+
+	errs "github.com/infosec554/clean-archtectura/domain"
+	"github.com/infosec554/clean-archtectura/pkg/i18n"
+	"github.com/infosec554/clean-archtectura/pkg/validator"
+)
+
+// bindAndValidate binds the request into req and validates it
+func bindAndValidate(c echo.Context, req any) error {
+	if err := c.Bind(req); err != nil {
+		return errs.BadRequest("invalid payload")
+	}
+	return validate(c, req)
+}
+
+// validate runs the registered echo.Validator and localizes its field errors
+func validate(c echo.Context, req any) error {
+	if err := c.Validate(req); err != nil {
+		return validator.Translate(err, lang(c))
+	}
+	return nil
+}
+
+// lang returns the language negotiated by middleware.Language
+func lang(c echo.Context) i18n.Lang {
+	return i18n.FromContext(c.Request().Context())
+}
+
+// t translates a response message into the request language
+func t(c echo.Context, id string) string {
+	return i18n.T(lang(c), id)
+}
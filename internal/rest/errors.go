@@ -0,0 +1,121 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+
+	"github.com/infosec554/clean-archtectura/domain"
+	"github.com/infosec554/clean-archtectura/domain/response"
+	"github.com/infosec554/clean-archtectura/pkg/i18n"
+)
+
+var kindStatus = []struct {
+	kind   error
+	status int
+}{
+	{domain.ErrBadParamInput, http.StatusBadRequest},
+	{domain.ErrUnauthorized, http.StatusUnauthorized},
+	{domain.ErrForbidden, http.StatusForbidden},
+	{domain.ErrNotFound, http.StatusNotFound},
+	{domain.ErrConflict, http.StatusConflict},
+	{domain.ErrGone, http.StatusGone},
+	{domain.ErrValidation, http.StatusUnprocessableEntity},
+	{domain.ErrTooManyRequests, http.StatusTooManyRequests},
+	{domain.ErrInternalServerError, http.StatusInternalServerError},
+}
+
+// ErrorHandler renders every error returned by handlers and middleware
+// as an RFC 7807 application/problem+json document
+func ErrorHandler(logger zerolog.Logger) echo.HTTPErrorHandler {
+	logger = logger.With().Str("component", "error_handler").Logger()
+
+	return func(err error, c echo.Context) {
+		if c.Response().Committed {
+			return
+		}
+
+		problem := localize(toProblem(err), lang(c))
+		problem.Instance = c.Request().URL.Path
+
+		if problem.Status >= http.StatusInternalServerError {
+			logger.Error().Ctx(c.Request().Context()).Err(err).
+				Str("path", c.Request().URL.Path).
+				Msg("Request failed")
+		}
+
+		c.Response().Header().Set(echo.HeaderContentType, response.ContentTypeProblem)
+		if c.Request().Method == http.MethodHead {
+			err = c.NoContent(problem.Status)
+		} else {
+			err = c.JSON(problem.Status, problem)
+		}
+		if err != nil {
+			logger.Error().Ctx(c.Request().Context()).Err(err).Msg("Failed to write error response")
+		}
+	}
+}
+
+func toProblem(err error) response.Problem {
+	var he *echo.HTTPError
+	if errors.As(err, &he) {
+		p := newProblem(he.Code)
+		if msg, ok := he.Message.(string); ok && he.Code < http.StatusInternalServerError {
+			p.Detail = msg
+		}
+		return p
+	}
+
+	if e, ok := domain.AsError(err); ok {
+		p := newProblem(statusOf(e.Kind))
+		if p.Status < http.StatusInternalServerError {
+			p.Detail = e.Message
+			p.Errors = e.Fields
+		}
+		return p
+	}
+
+	status := statusOf(err)
+	p := newProblem(status)
+	if status < http.StatusInternalServerError {
+		p.Detail = err.Error()
+	}
+	return p
+}
+
+// localize translates the title, the detail and the field messages. Fields
+// are copied: they may belong to a shared error value.
+func localize(p response.Problem, l i18n.Lang) response.Problem {
+	if title, ok := i18n.Lookup(l, i18n.MsgProblemTitle(p.Status)); ok {
+		p.Title = title
+	}
+	p.Detail = i18n.Localize(l, p.Detail)
+	if len(p.Errors) > 0 {
+		fields := make([]domain.FieldError, len(p.Errors))
+		for i, f := range p.Errors {
+			f.Message = i18n.Localize(l, f.Message)
+			fields[i] = f
+		}
+		p.Errors = fields
+	}
+	return p
+}
+
+func statusOf(err error) int {
+	for _, ks := range kindStatus {
+		if errors.Is(err, ks.kind) {
+			return ks.status
+		}
+	}
+	return http.StatusInternalServerError
+}
+
+func newProblem(status int) response.Problem {
+	return response.Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+	}
+}
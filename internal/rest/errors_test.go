@@ -0,0 +1,69 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+
+	"github.com/infosec554/clean-archtectura/domain"
+	"github.com/infosec554/clean-archtectura/domain/response"
+	"github.com/infosec554/clean-archtectura/pkg/i18n"
+)
+
+func TestErrorHandlerLocalizes(t *testing.T) {
+	shared := domain.Validation("invalid verification code", domain.FieldError{
+		Field: "code", Rule: "match", Message: "code is invalid",
+	})
+
+	tests := []struct {
+		name   string
+		lang   i18n.Lang
+		err    error
+		status int
+		title  string
+		detail string
+		field  string
+	}{
+		{"russian", i18n.Ru, domain.NotFound("user not found"), http.StatusNotFound, "Не найдено", "Пользователь не найден", ""},
+		{"uzbek fields", i18n.Uz, shared, http.StatusUnprocessableEntity, "", "Tasdiqlash kodi noto'g'ri", "Kod noto'g'ri"},
+		{"english", i18n.En, shared, http.StatusUnprocessableEntity, "Unprocessable Entity", "invalid verification code", "code is invalid"},
+		{"unknown text", i18n.Ru, domain.BadRequest("custom"), http.StatusBadRequest, "", "custom", ""},
+		{"internal", i18n.Ru, domain.Internal(errors.New("connection refused")), http.StatusInternalServerError, "", "", ""},
+	}
+	handler := ErrorHandler(zerolog.Nop())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req = req.WithContext(i18n.WithLang(req.Context(), tt.lang))
+			rec := httptest.NewRecorder()
+			handler(tt.err, echo.New().NewContext(req, rec))
+
+			var p response.Problem
+			if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+				t.Fatal(err)
+			}
+			if p.Status != tt.status || rec.Code != tt.status {
+				t.Errorf("status = %d/%d, want %d", rec.Code, p.Status, tt.status)
+			}
+			if tt.title != "" && p.Title != tt.title {
+				t.Errorf("title = %q, want %q", p.Title, tt.title)
+			}
+			if p.Detail != tt.detail {
+				t.Errorf("detail = %q, want %q", p.Detail, tt.detail)
+			}
+			if tt.field != "" && (len(p.Errors) != 1 || p.Errors[0].Message != tt.field) {
+				t.Errorf("errors = %+v, want %q", p.Errors, tt.field)
+			}
+		})
+	}
+
+	// The shared error keeps its English text for the next request
+	if e, _ := domain.AsError(shared); e.Fields[0].Message != "code is invalid" {
+		t.Errorf("shared error modified: %q", e.Fields[0].Message)
+	}
+}
@@ -0,0 +1,113 @@
+package rest
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+
+	errs "github.com/infosec554/clean-archtectura/domain"
+	"github.com/infosec554/clean-archtectura/domain/response"
+	domain "github.com/infosec554/clean-archtectura/domain/users"
+	"github.com/infosec554/clean-archtectura/internal/rest/middleware"
+	"github.com/infosec554/clean-archtectura/pkg/i18n"
+)
+
+type ExportService interface {
+	Request(ctx context.Context, userID uuid.UUID) (domain.DataExportResponse, error)
+	List(ctx context.Context, userID uuid.UUID) ([]domain.DataExportResponse, error)
+	Download(ctx context.Context, id uuid.UUID, token string) (io.ReadCloser, string, error)
+}
+
+type ExportHandler struct {
+	service ExportService
+	logger  zerolog.Logger
+}
+
+func NewExportHandler(public *echo.Group, private *echo.Group, svc ExportService, logger zerolog.Logger) {
+	h := &ExportHandler{
+		service: svc,
+		logger:  logger.With().Str("handler", "export").Logger(),
+	}
+
+	// Public routes (authorized by the link token)
+	public.GET("/exports/:id/download", h.Download)
+
+	// Private routes
+	private.POST("/users/me/export", h.Request)
+	private.GET("/users/me/exports", h.List)
+}
+
+// @Summary      Request personal data export
+// @Description  Starts an asynchronous export of everything stored about the current user. A download link is emailed when it is ready.
+// @Tags         Users
+// @Produce      json
+// @Security     BearerAuth
+// @Success      202 {object} response.Response{data=domain.DataExportResponse} "Export started"
+// @Failure      401 {object} response.Problem "Unauthorized"
+// @Failure      500 {object} response.Problem "Internal server error"
+// @Router       /users/me/export [post]
+func (h *ExportHandler) Request(c echo.Context) error {
+	export, err := h.service.Request(c.Request().Context(), middleware.GetUserID(c))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusAccepted, response.Response{
+		StatusCode:  202,
+		Description: t(c, i18n.MsgExportStarted),
+		Data:        export,
+	})
+}
+
+// @Summary      List personal data exports
+// @Description  Returns the current user's data export jobs
+// @Tags         Users
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200 {object} response.Response{data=[]domain.DataExportResponse} "Exports retrieved"
+// @Failure      401 {object} response.Problem "Unauthorized"
+// @Failure      500 {object} response.Problem "Internal server error"
+// @Router       /users/me/exports [get]
+func (h *ExportHandler) List(c echo.Context) error {
+	list, err := h.service.List(c.Request().Context(), middleware.GetUserID(c))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response.Response{
+		StatusCode:  200,
+		Description: t(c, i18n.MsgExportsRetrieved),
+		Data:        list,
+	})
+}
+
+// @Summary      Download personal data export
+// @Description  Downloads the ZIP archive using the time-limited link sent by email
+// @Tags         Users
+// @Produce      application/zip
+// @Param        id path string true "Export ID"
+// @Param        token query string true "Download token"
+// @Success      200 {file} file "ZIP archive"
+// @Failure      400 {object} response.Problem "Invalid export ID"
+// @Failure      404 {object} response.Problem "Export not found"
+// @Failure      410 {object} response.Problem "Download link expired"
+// @Router       /exports/{id}/download [get]
+func (h *ExportHandler) Download(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return errs.BadRequest("invalid export id")
+	}
+
+	file, name, err := h.service.Download(c.Request().Context(), id, c.QueryParam("token"))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="`+name+`"`)
+	return c.Stream(http.StatusOK, "application/zip", file)
+}
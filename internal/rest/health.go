@@ -0,0 +1,53 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/infosec554/clean-archtectura/pkg/health"
+)
+
+type HealthRegistry interface {
+	Run(ctx context.Context) health.Report
+}
+
+type HealthHandler struct {
+	registry HealthRegistry
+}
+
+func NewHealthHandler(public *echo.Group, registry HealthRegistry) {
+	h := &HealthHandler{registry: registry}
+
+	public.GET("/health", h.Live)
+	public.GET("/health/live", h.Live)
+	public.GET("/health/ready", h.Ready)
+}
+
+// @Summary      Liveness probe
+// @Description  Reports that the process is up; dependencies are not checked
+// @Tags         Health
+// @Produce      json
+// @Success      200 {object} health.Report
+// @Router       /health/live [get]
+func (h *HealthHandler) Live(c echo.Context) error {
+	return c.JSON(http.StatusOK, health.Report{Status: health.StatusOK})
+}
+
+// @Summary      Readiness probe
+// @Description  Runs the registered dependency checks and returns a per-check breakdown with latencies. Returns 503 if a critical check fails or the service is shutting down.
+// @Tags         Health
+// @Produce      json
+// @Success      200 {object} health.Report
+// @Failure      503 {object} health.Report
+// @Router       /health/ready [get]
+func (h *HealthHandler) Ready(c echo.Context) error {
+	report := h.registry.Run(c.Request().Context())
+
+	status := http.StatusOK
+	if !report.Ready() {
+		status = http.StatusServiceUnavailable
+	}
+	return c.JSON(status, report)
+}
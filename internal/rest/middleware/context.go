@@ -3,6 +3,8 @@ package middleware
 import (
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
+
+	domain "github.com/infosec554/clean-archtectura/domain/users"
 )
 
 func GetUserID(c echo.Context) uuid.UUID {
@@ -11,6 +13,21 @@ func GetUserID(c echo.Context) uuid.UUID {
 	return parsed
 }
 
+// GetActorID returns the user who actually made the request. For impersonation
+// tokens this is the admin behind the session, otherwise it equals GetUserID.
+func GetActorID(c echo.Context) uuid.UUID {
+	if id := getString(c.Get("actor_id")); id != "" {
+		parsed, _ := uuid.Parse(id)
+		return parsed
+	}
+	return GetUserID(c)
+}
+
+// IsImpersonated reports whether the request was made with an impersonation token
+func IsImpersonated(c echo.Context) bool {
+	return getString(c.Get("actor_id")) != ""
+}
+
 func GetEmail(c echo.Context) string {
 	return getString(c.Get("email"))
 }
@@ -22,3 +39,7 @@ func GetFirstName(c echo.Context) string {
 func GetLastName(c echo.Context) string {
 	return getString(c.Get("last_name"))
 }
+
+func GetRole(c echo.Context) domain.Role {
+	return domain.Role(getString(c.Get("role")))
+}
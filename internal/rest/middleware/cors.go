@@ -2,23 +2,156 @@ package middleware
 
 import (
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/labstack/echo/v4"
+
+	"github.com/infosec554/clean-archtectura/config"
 )
 
-func CORS() echo.MiddlewareFunc {
+// CORS applies the configured cross-origin policy. Only allowlisted origins are
+// reflected, and preflight requests are answered only for routes that exist
+// for the requested method; anything else falls through to normal routing.
+func CORS(e *echo.Echo, cfg config.CORSConfig) echo.MiddlewareFunc {
+	policy := newCORSPolicy(cfg)
+	routes := routeSet{e: e}
+
+	allowMethods := strings.Join(cfg.AllowMethods, ", ")
+	allowHeaders := strings.Join(cfg.AllowHeaders, ", ")
+	exposeHeaders := strings.Join(cfg.ExposeHeaders, ", ")
+	maxAge := strconv.Itoa(int(cfg.MaxAge.Seconds()))
+
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			c.Response().Header().Set("Access-Control-Allow-Origin", "*")
-			c.Response().Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
-			c.Response().Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization, X-Requested-With")
-			c.Response().Header().Set("Access-Control-Allow-Credentials", "true")
+			req := c.Request()
+			h := c.Response().Header()
+
+			origin := req.Header.Get(echo.HeaderOrigin)
+			preflight := req.Method == http.MethodOptions && req.Header.Get(echo.HeaderAccessControlRequestMethod) != ""
+
+			// The answer depends on Origin, so caches must not share it across origins
+			h.Add(echo.HeaderVary, echo.HeaderOrigin)
+			if preflight {
+				h.Add(echo.HeaderVary, echo.HeaderAccessControlRequestMethod)
+				h.Add(echo.HeaderVary, echo.HeaderAccessControlRequestHeaders)
+			}
+
+			allowOrigin, ok := policy.allow(origin)
+			if origin == "" || !ok {
+				return next(c)
+			}
 
-			if c.Request().Method == http.MethodOptions {
+			if preflight {
+				method := req.Header.Get(echo.HeaderAccessControlRequestMethod)
+				if !routes.has(req, method) {
+					return next(c)
+				}
+
+				h.Set(echo.HeaderAccessControlAllowOrigin, allowOrigin)
+				h.Set(echo.HeaderAccessControlAllowMethods, allowMethods)
+				h.Set(echo.HeaderAccessControlAllowHeaders, allowHeaders)
+				h.Set(echo.HeaderAccessControlMaxAge, maxAge)
+				if policy.credentials {
+					h.Set(echo.HeaderAccessControlAllowCredentials, "true")
+				}
 				return c.NoContent(http.StatusNoContent)
 			}
 
+			h.Set(echo.HeaderAccessControlAllowOrigin, allowOrigin)
+			if exposeHeaders != "" {
+				h.Set(echo.HeaderAccessControlExposeHeaders, exposeHeaders)
+			}
+			if policy.credentials {
+				h.Set(echo.HeaderAccessControlAllowCredentials, "true")
+			}
 			return next(c)
 		}
 	}
 }
+
+type corsPolicy struct {
+	any         bool
+	credentials bool
+	exact       map[string]bool
+	wildcards   []wildcardOrigin
+}
+
+// wildcardOrigin matches https://*.example.com: any subdomain, same scheme and port
+type wildcardOrigin struct {
+	scheme string
+	suffix string // ".example.com" or ".example.com:8443"
+}
+
+func newCORSPolicy(cfg config.CORSConfig) corsPolicy {
+	p := corsPolicy{credentials: cfg.AllowCredentials, exact: make(map[string]bool)}
+
+	for _, o := range cfg.AllowOrigins {
+		o = strings.ToLower(strings.TrimRight(o, "/"))
+		switch {
+		case o == "*":
+			p.any = true
+		case strings.Contains(o, "://*."):
+			scheme, host, _ := strings.Cut(o, "://*")
+			p.wildcards = append(p.wildcards, wildcardOrigin{scheme: scheme, suffix: host})
+		default:
+			p.exact[o] = true
+		}
+	}
+	// config.Validate refuses this combination; never answer with both
+	if p.any {
+		p.credentials = false
+	}
+	return p
+}
+
+// allow returns the Access-Control-Allow-Origin value for origin. Allowing
+// every origin answers "*", which browsers never combine with credentials:
+// reflecting the origin instead would hand any site the user's session.
+func (p corsPolicy) allow(origin string) (string, bool) {
+	if origin == "" {
+		return "", false
+	}
+	if p.any {
+		return "*", true
+	}
+
+	normalized := strings.ToLower(origin)
+	if p.exact[normalized] {
+		return origin, true
+	}
+
+	u, err := url.Parse(normalized)
+	if err != nil || u.Host == "" {
+		return "", false
+	}
+	for _, w := range p.wildcards {
+		if u.Scheme == w.scheme && strings.HasSuffix(u.Host, w.suffix) && len(u.Host) > len(w.suffix) {
+			return origin, true
+		}
+	}
+	return "", false
+}
+
+// routeSet answers whether a registered route serves a method and path.
+// It is built on first use, after all routes have been registered.
+type routeSet struct {
+	e      *echo.Echo
+	once   sync.Once
+	routes map[string]bool
+}
+
+func (r *routeSet) has(req *http.Request, method string) bool {
+	r.once.Do(func() {
+		r.routes = make(map[string]bool)
+		for _, route := range r.e.Routes() {
+			r.routes[route.Method+" "+route.Path] = true
+		}
+	})
+
+	c := r.e.NewContext(req, nil)
+	r.e.Router().Find(method, req.URL.Path, c)
+	return r.routes[method+" "+c.Path()]
+}
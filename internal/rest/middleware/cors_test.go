@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/infosec554/clean-archtectura/config"
+)
+
+func TestCORSPolicyAllow(t *testing.T) {
+	p := newCORSPolicy(config.CORSConfig{
+		AllowOrigins:     []string{"https://app.example.com/", "https://*.admin.example.com", "http://localhost:3000"},
+		AllowCredentials: true,
+	})
+
+	tests := []struct {
+		origin string
+		want   string
+	}{
+		{"https://app.example.com", "https://app.example.com"},
+		{"https://APP.example.com", "https://APP.example.com"},
+		{"http://localhost:3000", "http://localhost:3000"},
+		{"https://a.admin.example.com", "https://a.admin.example.com"},
+		{"https://a.b.admin.example.com", "https://a.b.admin.example.com"},
+		{"", ""},
+		{"http://app.example.com", ""},
+		{"https://app.example.com.evil.com", ""},
+		{"https://admin.example.com", ""},
+		{"https://.admin.example.com", ""},
+		{"https://eviladmin.example.com", ""},
+		{"http://a.admin.example.com", ""},
+		{"https://a.admin.example.com:8443", ""},
+		{"http://localhost:3001", ""},
+		{"null", ""},
+	}
+	for _, tt := range tests {
+		got, ok := p.allow(tt.origin)
+		if got != tt.want || ok != (tt.want != "") {
+			t.Errorf("allow(%q) = %q, %t; want %q", tt.origin, got, ok, tt.want)
+		}
+	}
+}
+
+func TestCORSAnyOrigin(t *testing.T) {
+	e := echo.New()
+	// Validate rejects this configuration; the middleware still must not
+	// reflect origins with credentials
+	e.Use(CORS(e, config.CORSConfig{
+		AllowOrigins:     []string{"*"},
+		AllowMethods:     []string{http.MethodGet},
+		AllowCredentials: true,
+		MaxAge:           time.Minute,
+	}))
+	e.GET("/users", func(c echo.Context) error { return c.NoContent(http.StatusNoContent) })
+
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Header.Set(echo.HeaderOrigin, "https://evil.example")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	h := rec.Header()
+	if got := h.Get(echo.HeaderAccessControlAllowOrigin); got != "*" {
+		t.Errorf("Allow-Origin = %q, want *", got)
+	}
+	if got := h.Get(echo.HeaderAccessControlAllowCredentials); got != "" {
+		t.Errorf("Allow-Credentials = %q with a wildcard origin", got)
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	e := echo.New()
+	e.Use(CORS(e, config.CORSConfig{
+		AllowOrigins:     []string{"https://app.example.com"},
+		AllowMethods:     []string{http.MethodGet, http.MethodDelete},
+		AllowHeaders:     []string{"Authorization"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}))
+	e.DELETE("/users/:id", func(c echo.Context) error { return c.NoContent(http.StatusNoContent) })
+
+	tests := []struct {
+		name   string
+		origin string
+		method string
+		path   string
+		cors   bool
+	}{
+		{"allowed", "https://app.example.com", http.MethodDelete, "/users/1", true},
+		{"unknown origin", "https://evil.example", http.MethodDelete, "/users/1", false},
+		{"no such route", "https://app.example.com", http.MethodPut, "/users/1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodOptions, tt.path, nil)
+			req.Header.Set(echo.HeaderOrigin, tt.origin)
+			req.Header.Set(echo.HeaderAccessControlRequestMethod, tt.method)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			h := rec.Header()
+			if got := h.Get(echo.HeaderAccessControlAllowOrigin) != ""; got != tt.cors {
+				t.Fatalf("CORS headers sent = %t, want %t", got, tt.cors)
+			}
+			if !tt.cors {
+				return
+			}
+			if rec.Code != http.StatusNoContent || h.Get(echo.HeaderAccessControlAllowCredentials) != "true" || h.Get(echo.HeaderAccessControlMaxAge) != "600" {
+				t.Errorf("preflight = %d %v", rec.Code, h)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"net"
+
+	"github.com/labstack/echo/v4"
+)
+
+// IPExtractor decides what c.RealIP returns. Without trusted proxies it is the
+// peer address, as anyone can send X-Forwarded-For. Behind proxies it is the
+// X-Forwarded-For entry to the left of the trusted ones; private networks are
+// not trusted unless listed.
+func IPExtractor(trustedProxies []string) echo.IPExtractor {
+	if len(trustedProxies) == 0 {
+		return echo.ExtractIPDirect()
+	}
+
+	opts := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+	for _, cidr := range trustedProxies {
+		if _, ipNet, err := net.ParseCIDR(cidr); err == nil {
+			opts = append(opts, echo.TrustIPRange(ipNet))
+		}
+	}
+	return echo.ExtractIPFromXFFHeader(opts...)
+}
@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+
+	errs "github.com/infosec554/clean-archtectura/domain"
+	"github.com/infosec554/clean-archtectura/pkg/ratelimit"
+)
+
+func TestIPExtractor(t *testing.T) {
+	tests := []struct {
+		name    string
+		trusted []string
+		peer    string
+		xff     string
+		want    string
+	}{
+		{"direct ignores forwarding headers", nil, "203.0.113.7:1234", "1.2.3.4", "203.0.113.7"},
+		{"private peer is not trusted by default", nil, "10.0.0.5:1234", "1.2.3.4", "10.0.0.5"},
+		{"trusted proxy", []string{"10.0.0.0/8"}, "10.0.0.5:1234", "198.51.100.9", "198.51.100.9"},
+		{"spoofed entry left of the client", []string{"10.0.0.0/8"}, "10.0.0.5:1234", "1.2.3.4, 198.51.100.9", "198.51.100.9"},
+		{"chain of trusted proxies", []string{"10.0.0.0/8"}, "10.0.0.5:1234", "198.51.100.9, 10.1.1.1", "198.51.100.9"},
+		{"untrusted peer", []string{"10.0.0.0/8"}, "203.0.113.7:1234", "1.2.3.4", "203.0.113.7"},
+		{"unlisted private peer", []string{"10.0.0.0/8"}, "192.168.1.1:1234", "1.2.3.4", "192.168.1.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			e.IPExtractor = IPExtractor(tt.trusted)
+			e.GET("/", func(c echo.Context) error { return c.String(http.StatusOK, c.RealIP()) })
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.peer
+			req.Header.Set(echo.HeaderXForwardedFor, tt.xff)
+			req.Header.Set(echo.HeaderXRealIP, "5.6.7.8")
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if got := rec.Body.String(); got != tt.want {
+				t.Errorf("RealIP = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimitIgnoresSpoofedIPs(t *testing.T) {
+	policy := ratelimit.Policy{Name: "auth", Limit: 2, Window: time.Minute, KeyBy: ratelimit.KeyByIP}
+	limiter := NewRateLimiter(ratelimit.NewMemoryStore(), map[string]ratelimit.Policy{"auth": policy}, zerolog.Nop())
+
+	e := echo.New()
+	e.IPExtractor = IPExtractor(nil)
+	e.HTTPErrorHandler = func(err error, c echo.Context) {
+		if errors.Is(err, errs.ErrTooManyRequests) {
+			_ = c.NoContent(http.StatusTooManyRequests)
+			return
+		}
+		e.DefaultHTTPErrorHandler(err, c)
+	}
+	e.GET("/", func(c echo.Context) error { return c.NoContent(http.StatusNoContent) }, limiter.Policy("auth"))
+
+	// A new X-Forwarded-For per request must not buy a new bucket
+	var codes []int
+	for _, xff := range []string{"1.1.1.1", "2.2.2.2", "3.3.3.3"} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "203.0.113.7:1234"
+		req.Header.Set(echo.HeaderXForwardedFor, xff)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		codes = append(codes, rec.Code)
+	}
+	if codes[2] != http.StatusTooManyRequests {
+		t.Errorf("status codes = %v, want the third request limited", codes)
+	}
+}
@@ -1,59 +1,71 @@
 package middleware
 
 import (
-	"net/http"
+	"context"
+	"errors"
 	"strings"
 
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"github.com/rs/zerolog"
 
-	response "github.com/infosec554/clean-archtectura/domain/response"
+	errs "github.com/infosec554/clean-archtectura/domain"
+	domain "github.com/infosec554/clean-archtectura/domain/users"
+	"github.com/infosec554/clean-archtectura/pkg/logging"
 	"github.com/infosec554/clean-archtectura/pkg/token"
 )
 
+// AccountStateProvider looks up the current status and role of an account,
+// so that tokens of accounts blocked or demoted after issuance lose their
+// access
+type AccountStateProvider interface {
+	GetAccountState(ctx context.Context, id uuid.UUID) (domain.AccountState, error)
+}
+
+// AuditRecorder persists the audit trail of impersonated requests
+type AuditRecorder interface {
+	Create(ctx context.Context, entry domain.AuditEntry) error
+}
+
 type middleware struct {
 	jwtManager *token.JWTManager
+	accounts   AccountStateProvider
+	audit      AuditRecorder
 	logger     zerolog.Logger
 }
 
-func NewMiddleware(secret string, logger zerolog.Logger) *middleware {
+func NewMiddleware(secret string, accounts AccountStateProvider, audit AuditRecorder, logger zerolog.Logger) *middleware {
 	return &middleware{
 		jwtManager: token.NewJWTManager(secret),
+		accounts:   accounts,
+		audit:      audit,
 		logger:     logger,
 	}
 }
 
-// JWTAuth — Bearer tokenni tekshirib, claims'ni context'ga yozadi
+// JWTAuth checks the Bearer token and stores its claims in the context
 func (m *middleware) JWTAuth() echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			authHeader := c.Request().Header.Get("Authorization")
 			if authHeader == "" {
-				return c.JSON(http.StatusUnauthorized, response.Response{
-					StatusCode:  401,
-					Description: "Authorization header required",
-				})
+				return errs.Unauthorized("authorization header required")
 			}
 
 			parts := strings.SplitN(authHeader, " ", 2)
 			if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
-				return c.JSON(http.StatusUnauthorized, response.Response{
-					StatusCode:  401,
-					Description: "Invalid authorization header format. Use: Bearer <token>",
-				})
+				return errs.Unauthorized("invalid authorization header format, use: Bearer <token>")
 			}
 
 			tokenStr := strings.TrimSpace(parts[1])
 			valid, claims, err := m.jwtManager.Verify(tokenStr)
 			if err != nil || !valid {
-				return c.JSON(http.StatusUnauthorized, response.Response{
-					StatusCode:  401,
-					Description: "Invalid or expired token",
-				})
+				return errs.Unauthorized("invalid or expired token")
 			}
 
 			if userID, ok := claims["user_id"].(string); ok {
 				c.Set("user_id", userID)
+				logging.SetUserID(c.Request().Context(), userID)
 			}
 			if email, ok := claims["email"].(string); ok {
 				c.Set("email", email)
@@ -64,7 +76,109 @@ func (m *middleware) JWTAuth() echo.MiddlewareFunc {
 			if lastName, ok := claims["last_name"].(string); ok {
 				c.Set("last_name", lastName)
 			}
+			if act, ok := claims["act"].(map[string]any); ok {
+				if actorID, ok := act["sub"].(string); ok {
+					c.Set("actor_id", actorID)
+				}
+			}
+
+			// The role claim is what the account had when the token was
+			// issued; permissions follow the stored role instead
+			if m.accounts != nil {
+				ids := []uuid.UUID{GetUserID(c)}
+				if IsImpersonated(c) {
+					ids = append(ids, GetActorID(c))
+				}
+				for i, id := range ids {
+					state, err := m.accounts.GetAccountState(c.Request().Context(), id)
+					if err != nil {
+						if errors.Is(err, errs.ErrNotFound) {
+							return errs.Unauthorized("invalid or expired token")
+						}
+						m.logger.Warn().Ctx(c.Request().Context()).Err(err).Str("user_id", id.String()).Msg("Account state lookup failed")
+						return err
+					}
+					if err := state.Status.Err(); err != nil {
+						return err
+					}
+					if i == 0 {
+						c.Set("role", string(state.Role))
+					}
+					// An actor demoted after issuance loses the sessions it opened
+					if i == 1 && !state.Role.Has(domain.PermImpersonate) {
+						return errs.Forbidden("impersonation is no longer allowed")
+					}
+				}
+			}
+
+			if IsImpersonated(c) {
+				// Render errors here so the audit entry records the final status code
+				if err := next(c); err != nil {
+					c.Error(err)
+				}
+				m.auditImpersonated(c)
+				return nil
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// auditImpersonated records a request made with an impersonation token
+func (m *middleware) auditImpersonated(c echo.Context) {
+	status := c.Response().Status
+
+	subjectID := GetUserID(c)
+	entry := domain.AuditEntry{
+		ActorID:    GetActorID(c),
+		SubjectID:  &subjectID,
+		Action:     domain.AuditActionImpersonatedRequest,
+		Method:     c.Request().Method,
+		Path:       c.Request().URL.Path,
+		StatusCode: status,
+		IP:         c.RealIP(),
+		UserAgent:  c.Request().UserAgent(),
+	}
 
+	m.logger.Info().Ctx(c.Request().Context()).
+		Str("actor_id", entry.ActorID.String()).
+		Str("user_id", subjectID.String()).
+		Str("method", entry.Method).
+		Str("path", entry.Path).
+		Int("status", status).
+		Msg("Impersonated request")
+
+	if m.audit == nil {
+		return
+	}
+	if err := m.audit.Create(context.WithoutCancel(c.Request().Context()), entry); err != nil {
+		m.logger.Error().Ctx(c.Request().Context()).Err(err).Str("actor_id", entry.ActorID.String()).Msg("Failed to write impersonation audit entry")
+	}
+}
+
+// RequirePermission allows the request only if the caller's current role
+// grants the permission. It must run after JWTAuth.
+func RequirePermission(p domain.Permission) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !GetRole(c).Has(p) {
+				return errs.Forbidden("you do not have permission to perform this action")
+			}
+			return next(c)
+		}
+	}
+}
+
+// DenyImpersonated rejects requests made with an impersonation token. Support
+// staff act as the user, but must not be able to take the account over.
+// It must run after JWTAuth.
+func DenyImpersonated() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if IsImpersonated(c) {
+				return domain.ErrImpersonatedAction
+			}
 			return next(c)
 		}
 	}
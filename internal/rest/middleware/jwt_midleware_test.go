@@ -0,0 +1,205 @@
+package middleware_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+
+	errs "github.com/infosec554/clean-archtectura/domain"
+	domain "github.com/infosec554/clean-archtectura/domain/users"
+	"github.com/infosec554/clean-archtectura/internal/rest"
+	"github.com/infosec554/clean-archtectura/internal/rest/middleware"
+	"github.com/infosec554/clean-archtectura/pkg/token"
+)
+
+const testSecret = "test-secret"
+
+type fakeAccounts map[uuid.UUID]domain.AccountState
+
+func (f fakeAccounts) GetAccountState(_ context.Context, id uuid.UUID) (domain.AccountState, error) {
+	state, ok := f[id]
+	if !ok {
+		return domain.AccountState{}, errs.NotFound("user not found")
+	}
+	return state, nil
+}
+
+// serve runs a request with the bearer token through JWTAuth and
+// RequirePermission(PermSearchUsers) and returns the status code
+func serve(t *testing.T, accounts fakeAccounts, bearer string) int {
+	t.Helper()
+	e := echo.New()
+	e.HTTPErrorHandler = rest.ErrorHandler(zerolog.Nop())
+	m := middleware.NewMiddleware(testSecret, accounts, nil, zerolog.Nop())
+	e.GET("/admin", func(c echo.Context) error {
+		return c.NoContent(http.StatusNoContent)
+	}, m.JWTAuth(), middleware.RequirePermission(domain.PermSearchUsers))
+
+	req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func accessToken(t *testing.T, user domain.User) string {
+	t.Helper()
+	access, _, err := token.NewJWTManager(testSecret).Generate(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return access
+}
+
+func TestRequirePermissionUsesStoredRole(t *testing.T) {
+	id := uuid.New()
+	admin := accessToken(t, domain.User{ID: id, Role: domain.RoleAdmin})
+	user := accessToken(t, domain.User{ID: id, Role: domain.RoleUser})
+
+	tests := []struct {
+		name  string
+		token string
+		state domain.AccountState
+		want  int
+	}{
+		{"admin", admin, domain.AccountState{Status: domain.AccountStatusActive, Role: domain.RoleAdmin}, http.StatusNoContent},
+		{"demoted after issuance", admin, domain.AccountState{Status: domain.AccountStatusActive, Role: domain.RoleUser}, http.StatusForbidden},
+		{"promoted after issuance", user, domain.AccountState{Status: domain.AccountStatusActive, Role: domain.RoleAdmin}, http.StatusNoContent},
+		{"banned admin", admin, domain.AccountState{Status: domain.AccountStatusBanned, Role: domain.RoleAdmin}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := serve(t, fakeAccounts{id: tt.state}, tt.token); got != tt.want {
+				t.Errorf("status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJWTAuthRejects(t *testing.T) {
+	id := uuid.New()
+	accounts := fakeAccounts{id: {Status: domain.AccountStatusActive, Role: domain.RoleAdmin}}
+
+	other, _, _ := token.NewJWTManager("other-secret").Generate(domain.User{ID: id, Role: domain.RoleAdmin})
+	deleted := accessToken(t, domain.User{ID: uuid.New(), Role: domain.RoleAdmin})
+
+	tests := []struct {
+		name   string
+		bearer string
+	}{
+		{"no token", ""},
+		{"bad signature", other},
+		{"deleted account", deleted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := serve(t, accounts, tt.bearer); got != http.StatusUnauthorized {
+				t.Errorf("status = %d, want 401", got)
+			}
+		})
+	}
+}
+
+func TestImpersonationChecksBothAccounts(t *testing.T) {
+	adminID, targetID := uuid.New(), uuid.New()
+	bearer, _, err := token.NewJWTManager(testSecret).GenerateImpersonation(domain.User{ID: targetID, Role: domain.RoleUser}, adminID, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The session has the target's permissions, not the admin's
+	accounts := fakeAccounts{
+		adminID:  {Status: domain.AccountStatusActive, Role: domain.RoleAdmin},
+		targetID: {Status: domain.AccountStatusActive, Role: domain.RoleUser},
+	}
+	if got := serve(t, accounts, bearer); got != http.StatusForbidden {
+		t.Errorf("impersonated admin route: status = %d, want 403", got)
+	}
+
+	// A banned admin cannot keep using a session opened before the ban
+	accounts[targetID] = domain.AccountState{Status: domain.AccountStatusActive, Role: domain.RoleAdmin}
+	accounts[adminID] = domain.AccountState{Status: domain.AccountStatusBanned, Role: domain.RoleAdmin}
+	if got := serve(t, accounts, bearer); got != http.StatusForbidden {
+		t.Errorf("banned actor: status = %d, want 403", got)
+	}
+
+	// Nor can an admin demoted since; an admin still in role keeps the session
+	accounts[adminID] = domain.AccountState{Status: domain.AccountStatusActive, Role: domain.RoleUser}
+	if got := serve(t, accounts, bearer); got != http.StatusForbidden {
+		t.Errorf("demoted actor: status = %d, want 403", got)
+	}
+	accounts[adminID] = domain.AccountState{Status: domain.AccountStatusActive, Role: domain.RoleAdmin}
+	if got := serve(t, accounts, bearer); got != http.StatusNoContent {
+		t.Errorf("admin actor: status = %d, want 204", got)
+	}
+}
+
+func TestAccountLookupFailure(t *testing.T) {
+	id := uuid.New()
+	e := echo.New()
+	e.HTTPErrorHandler = rest.ErrorHandler(zerolog.Nop())
+	m := middleware.NewMiddleware(testSecret, failingAccounts{}, nil, zerolog.Nop())
+	e.GET("/", func(c echo.Context) error { return c.NoContent(http.StatusNoContent) }, m.JWTAuth())
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+accessToken(t, domain.User{ID: id}))
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", rec.Code)
+	}
+}
+
+type failingAccounts struct{}
+
+func (failingAccounts) GetAccountState(context.Context, uuid.UUID) (domain.AccountState, error) {
+	return domain.AccountState{}, errors.New("redis down")
+}
+
+func TestDenyImpersonated(t *testing.T) {
+	adminID, userID := uuid.New(), uuid.New()
+	accounts := fakeAccounts{
+		adminID: {Status: domain.AccountStatusActive, Role: domain.RoleAdmin},
+		userID:  {Status: domain.AccountStatusActive, Role: domain.RoleUser},
+	}
+	impersonation, _, err := token.NewJWTManager(testSecret).GenerateImpersonation(domain.User{ID: userID}, adminID, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := echo.New()
+	e.HTTPErrorHandler = rest.ErrorHandler(zerolog.Nop())
+	m := middleware.NewMiddleware(testSecret, accounts, nil, zerolog.Nop())
+	e.PUT("/password", func(c echo.Context) error {
+		return c.NoContent(http.StatusNoContent)
+	}, m.JWTAuth(), middleware.DenyImpersonated())
+
+	tests := []struct {
+		name   string
+		bearer string
+		want   int
+	}{
+		{"own session", accessToken(t, domain.User{ID: userID}), http.StatusNoContent},
+		{"impersonated", impersonation, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, "/password", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.bearer)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
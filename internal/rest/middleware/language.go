@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"github.com/labstack/echo/v4"
+
+	"github.com/infosec554/clean-archtectura/pkg/i18n"
+)
+
+// Language negotiates the response language from Accept-Language and stores
+// it in the request context, where handlers, services and the error handler read it
+func Language() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			lang := i18n.Negotiate(c.Request().Header.Get("Accept-Language"))
+
+			req := c.Request()
+			c.SetRequest(req.WithContext(i18n.WithLang(req.Context(), lang)))
+
+			h := c.Response().Header()
+			h.Set("Content-Language", string(lang))
+			h.Add(echo.HeaderVary, "Accept-Language")
+
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+
+	"github.com/infosec554/clean-archtectura/internal/rest"
+	"github.com/infosec554/clean-archtectura/internal/rest/middleware"
+	"github.com/infosec554/clean-archtectura/pkg/metrics"
+)
+
+// observed returns the request counts recorded by Metrics, keyed by
+// "method route status"
+func observed(t *testing.T) map[string]uint64 {
+	t.Helper()
+	families, err := metrics.Registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]uint64{}
+	for _, family := range families {
+		if family.GetName() != "app_http_request_duration_seconds" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			key := labels["method"] + " " + labels["route"] + " " + labels["status"]
+			got[key] = m.GetHistogram().GetSampleCount()
+		}
+	}
+	return got
+}
+
+func TestMetricsLabels(t *testing.T) {
+	metrics.HTTPRequestDuration.Reset()
+	t.Cleanup(metrics.HTTPRequestDuration.Reset)
+
+	e := echo.New()
+	e.HTTPErrorHandler = rest.ErrorHandler(zerolog.Nop())
+	e.Use(middleware.Metrics())
+	e.GET("/users/:id", func(c echo.Context) error {
+		return c.NoContent(http.StatusNoContent)
+	})
+
+	for _, path := range []string{"/users/1", "/users/2", "/no/such/route", "/another/missing/route"} {
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	got := observed(t)
+	want := map[string]uint64{
+		"GET /users/:id 204": 2,
+		"GET unmatched 404":  2,
+	}
+	for key, n := range want {
+		if got[key] != n {
+			t.Errorf("%s observed %d times, want %d", key, got[key], n)
+		}
+	}
+	for key := range got {
+		if strings.Contains(key, "/users/1") || strings.Contains(key, "/no/such") {
+			t.Errorf("raw path used as a label: %s", key)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("series = %v, want %v", got, want)
+	}
+}
+
+func TestStaticToken(t *testing.T) {
+	e := echo.New()
+	e.HTTPErrorHandler = rest.ErrorHandler(zerolog.Nop())
+	e.GET("/metrics", func(c echo.Context) error {
+		return c.NoContent(http.StatusNoContent)
+	}, middleware.StaticToken("scrape-token"))
+
+	tests := []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{"valid token", "Bearer scrape-token", http.StatusNoContent},
+		{"missing header", "", http.StatusUnauthorized},
+		{"wrong token", "Bearer other-token", http.StatusUnauthorized},
+		{"token prefix", "Bearer scrape", http.StatusUnauthorized},
+		{"wrong scheme", "Basic scrape-token", http.StatusUnauthorized},
+		{"bare token", "scrape-token", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tt.header != "" {
+				req.Header.Set(echo.HeaderAuthorization, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
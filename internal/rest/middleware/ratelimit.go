@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+
+	errs "github.com/infosec554/clean-archtectura/domain"
+	"github.com/infosec554/clean-archtectura/pkg/ratelimit"
+)
+
+const headerAPIKey = "X-API-Key"
+
+// RateLimiter hands out middleware for the configured policies
+type RateLimiter struct {
+	store    ratelimit.Store
+	policies map[string]ratelimit.Policy
+	logger   zerolog.Logger
+}
+
+// NewRateLimiter builds a limiter for the given policies.
+// A nil *RateLimiter lets everything through, which is how rate limiting is disabled.
+func NewRateLimiter(store ratelimit.Store, policies map[string]ratelimit.Policy, logger zerolog.Logger) *RateLimiter {
+	return &RateLimiter{
+		store:    store,
+		policies: policies,
+		logger:   logger.With().Str("component", "rate_limiter").Logger(),
+	}
+}
+
+// Policy enforces the named policy. Unknown policies and a nil limiter are no-ops,
+// and if the store fails the request is let through rather than rejected.
+func (l *RateLimiter) Policy(name string) echo.MiddlewareFunc {
+	if l == nil {
+		return passThrough
+	}
+	p, ok := l.policies[name]
+	if !ok {
+		l.logger.Warn().Str("policy", name).Msg("Rate limit policy is not configured")
+		return passThrough
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx := c.Request().Context()
+
+			res, err := l.store.Allow(ctx, p.Name+":"+rateLimitKey(c, p.KeyBy), p)
+			if err != nil {
+				l.logger.Warn().Ctx(ctx).Err(err).Str("policy", p.Name).Msg("Rate limiter unavailable, allowing request")
+				return next(c)
+			}
+
+			h := c.Response().Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			h.Set("RateLimit-Reset", seconds(res.ResetAfter))
+			h.Set("RateLimit-Policy", strconv.Itoa(p.Limit)+";w="+seconds(p.Window))
+
+			if !res.Allowed {
+				h.Set(echo.HeaderRetryAfter, seconds(res.RetryAfter))
+				return errs.TooManyRequests("rate limit exceeded, retry later")
+			}
+			return next(c)
+		}
+	}
+}
+
+// rateLimitKey identifies the caller; without a user or API key it falls back to the IP
+func rateLimitKey(c echo.Context, by ratelimit.KeyBy) string {
+	switch by {
+	case ratelimit.KeyByUser:
+		if id := GetUserID(c); id != uuid.Nil {
+			return "user:" + id.String()
+		}
+	case ratelimit.KeyByAPIKey:
+		if key := c.Request().Header.Get(headerAPIKey); key != "" {
+			sum := sha256.Sum256([]byte(key))
+			return "key:" + hex.EncodeToString(sum[:16])
+		}
+	}
+	return "ip:" + c.RealIP()
+}
+
+// seconds rounds up, so clients never retry too early
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+func passThrough(next echo.HandlerFunc) echo.HandlerFunc {
+	return next
+}
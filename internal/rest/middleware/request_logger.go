@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+
+	"github.com/infosec554/clean-archtectura/pkg/logging"
+)
+
+const maxRequestIDLength = 128
+
+// RequestLogger assigns every request an X-Request-ID (reusing a sane incoming one),
+// attaches a request-scoped logger to the context and writes one access log line
+// per request. Errors are rendered here so the logged status is final.
+func RequestLogger(logger zerolog.Logger) echo.MiddlewareFunc {
+	accessLogger := logger.With().Str("component", "access_log").Logger()
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			req := c.Request()
+
+			id := req.Header.Get(echo.HeaderXRequestID)
+			if !validRequestID(id) {
+				id = uuid.NewString()
+			}
+			c.Response().Header().Set(echo.HeaderXRequestID, id)
+			c.Set("request_id", id)
+
+			route := c.Path()
+			if route == "" {
+				route = "unmatched"
+			}
+
+			ctx := logging.NewContext(req.Context(), &logging.Fields{
+				RequestID: id,
+				Method:    req.Method,
+				Route:     route,
+				IP:        c.RealIP(),
+			})
+			reqLogger := logger.With().Ctx(ctx).Logger()
+			c.SetRequest(req.WithContext(reqLogger.WithContext(ctx)))
+
+			if err := next(c); err != nil {
+				c.Error(err)
+			}
+
+			res := c.Response()
+			status := res.Status
+
+			var event *zerolog.Event
+			switch {
+			case status >= 500:
+				event = accessLogger.Error()
+			case status >= 400:
+				event = accessLogger.Warn()
+			default:
+				event = accessLogger.Info()
+			}
+
+			event.Ctx(c.Request().Context()).
+				Str("path", req.URL.Path).
+				Int("status", status).
+				Float64("latency_ms", float64(time.Since(start).Microseconds())/1000).
+				Int64("bytes_in", req.ContentLength).
+				Int64("bytes_out", res.Size).
+				Str("user_agent", req.UserAgent()).
+				Msg("request")
+			return nil
+		}
+	}
+}
+
+// validRequestID rejects IDs that are empty, oversized or could forge log lines
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		if !unicode.IsPrint(r) || unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
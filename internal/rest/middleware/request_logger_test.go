@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+)
+
+func TestValidRequestID(t *testing.T) {
+	tests := []struct {
+		id    string
+		valid bool
+	}{
+		{"9b2f6c1e-8d4a-4f3e-9a55-2c7d1e0b6f10", true},
+		{"trace-abc_123.xyz", true},
+		{strings.Repeat("a", maxRequestIDLength), true},
+		{"", false},
+		{strings.Repeat("a", maxRequestIDLength+1), false},
+		{"abc\u00a0def", false},
+		{"abc\ndef", false},
+		{"abc\r\n{\"level\":\"error\"}", false},
+		{"abc\x00", false},
+		{"abc def", false},
+		{"abc\tdef", false},
+	}
+	for _, tt := range tests {
+		if got := validRequestID(tt.id); got != tt.valid {
+			t.Errorf("validRequestID(%q) = %t, want %t", tt.id, got, tt.valid)
+		}
+	}
+}
+
+func TestRequestLoggerRequestID(t *testing.T) {
+	e := echo.New()
+	e.Use(RequestLogger(zerolog.Nop()))
+	e.GET("/", func(c echo.Context) error {
+		return c.String(http.StatusOK, c.Get("request_id").(string))
+	})
+
+	tests := []struct {
+		name     string
+		incoming string
+		reused   bool
+	}{
+		{"sane id is reused", "client-id-1", true},
+		{"missing id is generated", "", false},
+		{"forged id is replaced", "x\ny", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.incoming != "" {
+				req.Header.Set(echo.HeaderXRequestID, tt.incoming)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			got := rec.Header().Get(echo.HeaderXRequestID)
+			if got != rec.Body.String() {
+				t.Errorf("header %q differs from the context value %q", got, rec.Body.String())
+			}
+			if (got == tt.incoming) != tt.reused || !validRequestID(got) {
+				t.Errorf("request id = %q for incoming %q", got, tt.incoming)
+			}
+		})
+	}
+}
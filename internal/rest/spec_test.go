@@ -0,0 +1,292 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"gopkg.in/yaml.v3"
+
+	"github.com/infosec554/clean-archtectura/docs"
+)
+
+// spec is the Swagger 2.0 document served by the API. The checker below
+// validates real request and response bodies against it, so a handler that
+// returns an undocumented field or status fails the tests.
+type spec struct {
+	BasePath    string                              `yaml:"basePath"`
+	Paths       map[string]map[string]specOperation `yaml:"paths"`
+	Definitions map[string]*schema                  `yaml:"definitions"`
+}
+
+type specOperation struct {
+	Parameters []struct {
+		In       string  `yaml:"in"`
+		Name     string  `yaml:"name"`
+		Required bool    `yaml:"required"`
+		Schema   *schema `yaml:"schema"`
+	} `yaml:"parameters"`
+	Responses map[string]struct {
+		Schema *schema `yaml:"schema"`
+	} `yaml:"responses"`
+}
+
+type schema struct {
+	Ref                  string             `yaml:"$ref"`
+	Type                 string             `yaml:"type"`
+	Enum                 []any              `yaml:"enum"`
+	Required             []string           `yaml:"required"`
+	Properties           map[string]*schema `yaml:"properties"`
+	AdditionalProperties *schema            `yaml:"additionalProperties"`
+	Items                *schema            `yaml:"items"`
+	AllOf                []*schema          `yaml:"allOf"`
+	MinLength            *int               `yaml:"minLength"`
+	MaxLength            *int               `yaml:"maxLength"`
+}
+
+func loadSpec(t *testing.T) *spec {
+	t.Helper()
+	var s spec
+	if err := yaml.Unmarshal(docs.Spec, &s); err != nil {
+		t.Fatalf("parse docs/swagger.yaml: %v", err)
+	}
+	return &s
+}
+
+// operation finds the documented operation for a concrete request path.
+// Literal segments win over parameters, as in the router.
+func (s *spec) operation(method, path string) (string, specOperation, bool) {
+	path, _, _ = strings.Cut(strings.TrimPrefix(path, s.BasePath), "?")
+
+	best, params := "", -1
+	for template := range s.Paths {
+		n := strings.Count(template, "{")
+		if matchPath(template, path) && (params < 0 || n < params) {
+			best, params = template, n
+		}
+	}
+	op, ok := s.Paths[best][strings.ToLower(method)]
+	return best, op, ok
+}
+
+// matchPath reports whether path fits a template such as /users/{id}
+func matchPath(template, path string) bool {
+	want := strings.Split(strings.Trim(template, "/"), "/")
+	got := strings.Split(strings.Trim(path, "/"), "/")
+	if len(want) != len(got) {
+		return false
+	}
+	for i := range want {
+		if !strings.HasPrefix(want[i], "{") && want[i] != got[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// echoPath converts an Echo route such as /users/:id to /users/{id}
+func echoPath(path string) string {
+	parts := strings.Split(path, "/")
+	for i, p := range parts {
+		if strings.HasPrefix(p, ":") {
+			parts[i] = "{" + p[1:] + "}"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// specCase is one request sent through the router
+type specCase struct {
+	name   string
+	method string
+	path   string
+	body   any
+	status int
+}
+
+// check serves the request, then validates the request body against the
+// documented body parameter and the response against the documented status
+func (s *spec) check(t *testing.T, e *echo.Echo, tc specCase) {
+	t.Helper()
+
+	template, op, ok := s.operation(tc.method, tc.path)
+	if !ok {
+		t.Fatalf("%s %s is not documented", tc.method, tc.path)
+	}
+
+	var reqBody []byte
+	if tc.body != nil {
+		reqBody, _ = json.Marshal(tc.body)
+		var bodySchema *schema
+		for _, p := range op.Parameters {
+			if p.In == "body" {
+				bodySchema = p.Schema
+			}
+		}
+		if bodySchema == nil {
+			t.Errorf("%s %s: request has a body but the spec declares none", tc.method, template)
+		} else {
+			for _, err := range s.validate(bodySchema, decode(t, reqBody), "request") {
+				t.Errorf("%s %s: %s", tc.method, template, err)
+			}
+		}
+	}
+
+	req := httptest.NewRequest(tc.method, tc.path, bytes.NewReader(reqBody))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	if rec.Code != tc.status {
+		t.Fatalf("%s %s: status %d, want %d: %s", tc.method, tc.path, rec.Code, tc.status, rec.Body)
+	}
+	resp, ok := op.Responses[strconv.Itoa(rec.Code)]
+	if !ok {
+		t.Fatalf("%s %s: status %d is not documented", tc.method, template, rec.Code)
+	}
+	if resp.Schema == nil || rec.Code == http.StatusNoContent {
+		return
+	}
+	for _, err := range s.validate(resp.Schema, decode(t, rec.Body.Bytes()), "response") {
+		t.Errorf("%s %s %d: %s", tc.method, template, rec.Code, err)
+	}
+}
+
+func decode(t *testing.T, body []byte) any {
+	t.Helper()
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		t.Fatalf("invalid JSON %q: %v", body, err)
+	}
+	return v
+}
+
+func (s *spec) resolve(sc *schema) *schema {
+	for sc != nil && sc.Ref != "" {
+		sc = s.Definitions[strings.TrimPrefix(sc.Ref, "#/definitions/")]
+	}
+	return sc
+}
+
+// mergeObjects flattens an allOf of objects, as swag emits for
+// response.Response{data=T}. It returns nil when a part is not an object.
+func (s *spec) mergeObjects(sc *schema) *schema {
+	merged := &schema{Type: "object", Properties: map[string]*schema{}}
+	for _, part := range sc.AllOf {
+		part = s.resolve(part)
+		if part == nil || part.Type != "object" {
+			return nil
+		}
+		for name, prop := range part.Properties {
+			merged.Properties[name] = prop
+		}
+		merged.Required = append(merged.Required, part.Required...)
+	}
+	return merged
+}
+
+// validate returns every mismatch between value and the schema. Properties
+// not in the schema are errors: they are the typical sign of drift.
+func (s *spec) validate(sc *schema, value any, at string) []string {
+	sc = s.resolve(sc)
+	if sc == nil {
+		return []string{at + ": unresolved schema"}
+	}
+
+	if len(sc.AllOf) > 0 {
+		if merged := s.mergeObjects(sc); merged != nil {
+			sc = merged
+		} else {
+			// allOf of a named type plus local constraints such as an enum
+			var errs []string
+			for _, part := range sc.AllOf {
+				errs = append(errs, s.validate(part, value, at)...)
+			}
+			local := *sc
+			local.AllOf = nil
+			return append(errs, s.validate(&local, value, at)...)
+		}
+	}
+
+	if len(sc.Enum) > 0 && !slices.Contains(sc.Enum, value) {
+		return []string{fmt.Sprintf("%s: %v is not one of %v", at, value, sc.Enum)}
+	}
+
+	switch sc.Type {
+	case "":
+		// {} accepts anything, e.g. response.Response.data
+		return nil
+
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return []string{fmt.Sprintf("%s: want object, got %T", at, value)}
+		}
+		var errs []string
+		for _, name := range sc.Required {
+			if _, ok := obj[name]; !ok {
+				errs = append(errs, fmt.Sprintf("%s: missing required %q", at, name))
+			}
+		}
+		for name, v := range obj {
+			prop, ok := sc.Properties[name]
+			if !ok {
+				prop = sc.AdditionalProperties
+			}
+			if prop == nil {
+				errs = append(errs, fmt.Sprintf("%s: undocumented property %q", at, name))
+				continue
+			}
+			errs = append(errs, s.validate(prop, v, at+"."+name)...)
+		}
+		return errs
+
+	case "array":
+		list, ok := value.([]any)
+		if !ok {
+			return []string{fmt.Sprintf("%s: want array, got %T", at, value)}
+		}
+		var errs []string
+		for i, v := range list {
+			errs = append(errs, s.validate(sc.Items, v, fmt.Sprintf("%s[%d]", at, i))...)
+		}
+		return errs
+
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return []string{fmt.Sprintf("%s: want string, got %T", at, value)}
+		}
+		n := len([]rune(str))
+		if sc.MinLength != nil && n < *sc.MinLength || sc.MaxLength != nil && n > *sc.MaxLength {
+			return []string{fmt.Sprintf("%s: length %d outside the documented bounds", at, n)}
+		}
+		return nil
+
+	case "integer", "number":
+		num, ok := value.(float64)
+		if !ok {
+			return []string{fmt.Sprintf("%s: want %s, got %T", at, sc.Type, value)}
+		}
+		if sc.Type == "integer" && num != math.Trunc(num) {
+			return []string{fmt.Sprintf("%s: want integer, got %v", at, num)}
+		}
+		return nil
+
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return []string{fmt.Sprintf("%s: want boolean, got %T", at, value)}
+		}
+		return nil
+	}
+
+	return []string{fmt.Sprintf("%s: unsupported schema type %q", at, sc.Type)}
+}
@@ -3,16 +3,18 @@ package rest
 import (
 	"context"
 	"net/http"
-	"strings"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"github.com/rs/zerolog"
 
 	"github.com/infosec554/clean-archtectura/config"
+	errs "github.com/infosec554/clean-archtectura/domain"
 	"github.com/infosec554/clean-archtectura/domain/response"
 	domain "github.com/infosec554/clean-archtectura/domain/users"
+	"github.com/infosec554/clean-archtectura/internal/rest/middleware"
 	"github.com/infosec554/clean-archtectura/pkg/cache"
+	"github.com/infosec554/clean-archtectura/pkg/i18n"
 )
 
 type UserService interface {
@@ -24,6 +26,13 @@ type UserService interface {
 	Update(ctx context.Context, req *domain.UpdateUser) (string, error)
 	UpdatePassword(ctx context.Context, id uuid.UUID, req *domain.UpdatePasswordRequest) error
 	Delete(ctx context.Context, id uuid.UUID) error
+	Suspend(ctx context.Context, actorID, userID uuid.UUID, req *domain.SuspendRequest) error
+	Unsuspend(ctx context.Context, actorID, userID uuid.UUID, req *domain.UnsuspendRequest) error
+	Ban(ctx context.Context, actorID, userID uuid.UUID, req *domain.BanRequest) error
+	Search(ctx context.Context, req *domain.SearchUsersRequest) (domain.UserSearchList, error)
+	Impersonate(ctx context.Context, actorID, targetID uuid.UUID, req *domain.ImpersonateRequest) (domain.ImpersonationResponse, error)
+	GetPreferences(ctx context.Context, userID uuid.UUID) (domain.Preferences, error)
+	UpdatePreferences(ctx context.Context, userID uuid.UUID, req *domain.UpdatePreferencesRequest) (domain.Preferences, error)
 }
 
 type UserHandler struct {
@@ -49,10 +58,20 @@ func NewUserHandler(public *echo.Group, private *echo.Group, svc UserService, cf
 
 	// Private routes
 	private.POST("/logout", h.Logout)
+	private.GET("/users/me/preferences", h.GetPreferences)
+	private.PATCH("/users/me/preferences", h.UpdatePreferences)
 	private.GET("/users/:id", h.GetByID)
-	private.PUT("/users/:id", h.Update)
-	private.PUT("/users/:id/password", h.UpdatePassword)
-	private.DELETE("/users/:id", h.Delete)
+	private.PUT("/users/:id", h.Update, middleware.DenyImpersonated())
+	private.PUT("/users/:id/password", h.UpdatePassword, middleware.DenyImpersonated())
+	private.DELETE("/users/:id", h.Delete, middleware.DenyImpersonated())
+
+	// Admin routes
+	manageStatus := middleware.RequirePermission(domain.PermManageUserStatus)
+	private.POST("/admin/users/:id/suspend", h.Suspend, manageStatus)
+	private.POST("/admin/users/:id/unsuspend", h.Unsuspend, manageStatus)
+	private.POST("/admin/users/:id/ban", h.Ban, manageStatus)
+	private.GET("/admin/users/search", h.Search, middleware.RequirePermission(domain.PermSearchUsers))
+	private.POST("/admin/users/:id/impersonate", h.Impersonate, middleware.RequirePermission(domain.PermImpersonate))
 }
 
 // @Summary      Get user by ID
@@ -61,35 +80,25 @@ func NewUserHandler(public *echo.Group, private *echo.Group, svc UserService, cf
 // @Produce      json
 // @Param        id path string true "User ID"
 // @Security     BearerAuth
-// @Success      200 {object} response.Response "User retrieved"
-// @Failure      400 {object} response.Response "Invalid user ID"
-// @Failure      404 {object} response.Response "User not found"
-// @Failure      500 {object} response.Response "Internal server error"
+// @Success      200 {object} response.Response{data=domain.UserResponse} "User retrieved"
+// @Failure      400 {object} response.Problem "Invalid user ID"
+// @Failure      404 {object} response.Problem "User not found"
+// @Failure      500 {object} response.Problem "Internal server error"
 // @Router       /users/{id} [get]
 func (h *UserHandler) GetByID(c echo.Context) error {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, response.Response{
-			StatusCode:  400,
-			Description: "Invalid user ID",
-		})
+		return errs.BadRequest("invalid user id")
 	}
 
 	user, err := h.service.GetByID(c.Request().Context(), id)
 	if err != nil {
-		code := http.StatusInternalServerError
-		if strings.Contains(strings.ToLower(err.Error()), "not found") {
-			code = http.StatusNotFound
-		}
-		return c.JSON(code, response.Response{
-			StatusCode:  code,
-			Description: err.Error(),
-		})
+		return err
 	}
 
 	return c.JSON(http.StatusOK, response.Response{
 		StatusCode:  200,
-		Description: "User retrieved",
+		Description: t(c, i18n.MsgUserRetrieved),
 		Data:        user,
 	})
 }
@@ -103,45 +112,34 @@ func (h *UserHandler) GetByID(c echo.Context) error {
 // @Param        user body domain.UpdateUser true "Updated user info"
 // @Security     BearerAuth
 // @Success      200 {object} response.Response "User updated successfully"
-// @Failure      400 {object} response.Response "Invalid request"
-// @Failure      404 {object} response.Response "User not found"
-// @Failure      422 {object} response.Response "Validation failed"
-// @Failure      500 {object} response.Response "Internal server error"
+// @Failure      400 {object} response.Problem "Invalid request"
+// @Failure      403 {object} response.Problem "Not allowed while impersonating"
+// @Failure      404 {object} response.Problem "User not found"
+// @Failure      422 {object} response.Problem "Validation failed"
+// @Failure      500 {object} response.Problem "Internal server error"
 // @Router       /users/{id} [put]
 func (h *UserHandler) Update(c echo.Context) error {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, response.Response{
-			StatusCode:  400,
-			Description: "Invalid user ID",
-		})
+		return errs.BadRequest("invalid user id")
 	}
 
 	var req domain.UpdateUser
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, response.Response{
-			StatusCode:  400,
-			Description: "Invalid payload",
-			Data:        err.Error(),
-		})
+		return errs.BadRequest("invalid payload")
 	}
 	req.ID = id
+	if err := validate(c, &req); err != nil {
+		return err
+	}
 
 	if _, err := h.service.Update(c.Request().Context(), &req); err != nil {
-		code := http.StatusInternalServerError
-		if strings.Contains(strings.ToLower(err.Error()), "not found") {
-			code = http.StatusNotFound
-		}
-		return c.JSON(code, response.Response{
-			StatusCode:  code,
-			Description: "Failed to update user",
-			Data:        err.Error(),
-		})
+		return err
 	}
 
 	return c.JSON(http.StatusOK, response.Response{
 		StatusCode:  200,
-		Description: "User updated successfully",
+		Description: t(c, i18n.MsgUserUpdated),
 		Data: map[string]string{
 			"id": req.ID.String(),
 		},
@@ -155,34 +153,24 @@ func (h *UserHandler) Update(c echo.Context) error {
 // @Param        id path string true "User ID"
 // @Security     BearerAuth
 // @Success      200 {object} response.Response "User deleted successfully"
-// @Failure      400 {object} response.Response "Invalid user ID"
-// @Failure      404 {object} response.Response "User not found"
-// @Failure      500 {object} response.Response "Internal server error"
+// @Failure      400 {object} response.Problem "Invalid user ID"
+// @Failure      403 {object} response.Problem "Not allowed while impersonating"
+// @Failure      404 {object} response.Problem "User not found"
+// @Failure      500 {object} response.Problem "Internal server error"
 // @Router       /users/{id} [delete]
 func (h *UserHandler) Delete(c echo.Context) error {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, response.Response{
-			StatusCode:  400,
-			Description: "Invalid user ID",
-		})
+		return errs.BadRequest("invalid user id")
 	}
 
 	if err := h.service.Delete(c.Request().Context(), id); err != nil {
-		code := http.StatusInternalServerError
-		if strings.Contains(strings.ToLower(err.Error()), "not found") {
-			code = http.StatusNotFound
-		}
-		return c.JSON(code, response.Response{
-			StatusCode:  code,
-			Description: "Failed to delete user",
-			Data:        err.Error(),
-		})
+		return err
 	}
 
 	return c.JSON(http.StatusOK, response.Response{
 		StatusCode:  200,
-		Description: "User deleted successfully",
+		Description: t(c, i18n.MsgUserDeleted),
 	})
 }
 
@@ -193,27 +181,24 @@ func (h *UserHandler) Delete(c echo.Context) error {
 // @Produce      json
 // @Param        user body domain.CreateUser true "Registration info"
 // @Success      201 {object} response.Response "User registered"
+// @Failure      400 {object} response.Problem "Invalid payload"
+// @Failure      409 {object} response.Problem "Email already registered"
+// @Failure      422 {object} response.Problem "Validation failed"
 // @Router       /register [post]
 func (h *UserHandler) Register(c echo.Context) error {
 	var req domain.CreateUser
-	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, response.Response{
-			StatusCode:  400,
-			Description: "Invalid payload",
-		})
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
 	}
 
 	id, err := h.service.Register(c.Request().Context(), &req)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, response.Response{
-			StatusCode:  500,
-			Description: err.Error(),
-		})
+		return err
 	}
 
 	return c.JSON(http.StatusCreated, response.Response{
 		StatusCode:  201,
-		Description: "User registered successfully",
+		Description: t(c, i18n.MsgUserRegistered),
 		Data:        map[string]string{"id": id},
 	})
 }
@@ -225,27 +210,24 @@ func (h *UserHandler) Register(c echo.Context) error {
 // @Produce      json
 // @Param        login body domain.LoginRequest true "Login credentials"
 // @Success      200 {object} response.Response{data=domain.LoginResponse} "Login success"
+// @Failure      400 {object} response.Problem "Invalid payload"
+// @Failure      401 {object} response.Problem "Invalid credentials"
+// @Failure      403 {object} response.Problem "Account suspended, banned or locked"
 // @Router       /login [post]
 func (h *UserHandler) Login(c echo.Context) error {
 	var req domain.LoginRequest
-	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, response.Response{
-			StatusCode:  400,
-			Description: "Invalid payload",
-		})
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
 	}
 
 	resp, err := h.service.Login(c.Request().Context(), &req)
 	if err != nil {
-		return c.JSON(http.StatusUnauthorized, response.Response{
-			StatusCode:  401,
-			Description: "Invalid credentials",
-		})
+		return err
 	}
 
 	return c.JSON(http.StatusOK, response.Response{
 		StatusCode:  200,
-		Description: "Login successful",
+		Description: t(c, i18n.MsgLoginSuccessful),
 		Data:        resp,
 	})
 }
@@ -257,28 +239,22 @@ func (h *UserHandler) Login(c echo.Context) error {
 // @Produce      json
 // @Param        body body domain.VerifyEmailRequest true "Email and code"
 // @Success      200 {object} response.Response "Email verified"
-// @Failure      400 {object} response.Response "Invalid payload"
-// @Failure      422 {object} response.Response "Invalid or expired code"
+// @Failure      400 {object} response.Problem "Invalid payload"
+// @Failure      422 {object} response.Problem "Invalid or expired code"
 // @Router       /verify-email [post]
 func (h *UserHandler) VerifyEmail(c echo.Context) error {
 	var req domain.VerifyEmailRequest
-	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, response.Response{
-			StatusCode:  400,
-			Description: "Invalid payload",
-		})
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
 	}
 
 	if err := h.service.VerifyEmail(c.Request().Context(), &req); err != nil {
-		return c.JSON(http.StatusUnprocessableEntity, response.Response{
-			StatusCode:  422,
-			Description: err.Error(),
-		})
+		return err
 	}
 
 	return c.JSON(http.StatusOK, response.Response{
 		StatusCode:  200,
-		Description: "Email verified successfully",
+		Description: t(c, i18n.MsgEmailVerified),
 	})
 }
 
@@ -289,32 +265,22 @@ func (h *UserHandler) VerifyEmail(c echo.Context) error {
 // @Produce      json
 // @Param        body body domain.ResendCodeRequest true "Email"
 // @Success      200 {object} response.Response "Code sent"
-// @Failure      400 {object} response.Response "Invalid payload"
-// @Failure      404 {object} response.Response "User not found"
+// @Failure      400 {object} response.Problem "Invalid payload"
+// @Failure      404 {object} response.Problem "User not found"
 // @Router       /resend-code [post]
 func (h *UserHandler) ResendCode(c echo.Context) error {
 	var req domain.ResendCodeRequest
-	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, response.Response{
-			StatusCode:  400,
-			Description: "Invalid payload",
-		})
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
 	}
 
 	if err := h.service.SendVerificationCode(c.Request().Context(), &req); err != nil {
-		code := http.StatusInternalServerError
-		if strings.Contains(err.Error(), "not found") {
-			code = http.StatusNotFound
-		}
-		return c.JSON(code, response.Response{
-			StatusCode:  code,
-			Description: err.Error(),
-		})
+		return err
 	}
 
 	return c.JSON(http.StatusOK, response.Response{
 		StatusCode:  200,
-		Description: "Verification code sent to " + req.Email,
+		Description: i18n.Tf(lang(c), i18n.MsgVerificationCodeSent, i18n.Params{"email": req.Email}),
 	})
 }
 
@@ -328,7 +294,7 @@ func (h *UserHandler) ResendCode(c echo.Context) error {
 func (h *UserHandler) Logout(c echo.Context) error {
 	return c.JSON(http.StatusOK, response.Response{
 		StatusCode:  200,
-		Description: "Logged out successfully",
+		Description: t(c, i18n.MsgLoggedOut),
 	})
 }
 
@@ -341,33 +307,250 @@ func (h *UserHandler) Logout(c echo.Context) error {
 // @Param        update body domain.UpdatePasswordRequest true "Password update info"
 // @Security     BearerAuth
 // @Success      200 {object} response.Response "Password updated"
+// @Failure      403 {object} response.Problem "Not allowed while impersonating"
 // @Router       /users/{id}/password [put]
 func (h *UserHandler) UpdatePassword(c echo.Context) error {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, response.Response{
-			StatusCode:  400,
-			Description: "Invalid user ID",
-		})
+		return errs.BadRequest("invalid user id")
 	}
 
 	var req domain.UpdatePasswordRequest
-	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, response.Response{
-			StatusCode:  400,
-			Description: "Invalid payload",
-		})
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
 	}
 
 	if err := h.service.UpdatePassword(c.Request().Context(), id, &req); err != nil {
-		return c.JSON(http.StatusInternalServerError, response.Response{
-			StatusCode:  500,
-			Description: err.Error(),
-		})
+		return err
 	}
 
 	return c.JSON(http.StatusOK, response.Response{
 		StatusCode:  200,
-		Description: "Password updated successfully",
+		Description: t(c, i18n.MsgPasswordUpdated),
+	})
+}
+
+// @Summary      Get preferences
+// @Description  Returns the current user's locale, timezone and notification settings with defaults applied
+// @Tags         Users
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200 {object} response.Response{data=domain.Preferences} "Preferences retrieved"
+// @Failure      404 {object} response.Problem "User not found"
+// @Failure      500 {object} response.Problem "Internal server error"
+// @Router       /users/me/preferences [get]
+func (h *UserHandler) GetPreferences(c echo.Context) error {
+	prefs, err := h.service.GetPreferences(c.Request().Context(), middleware.GetUserID(c))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response.Response{
+		StatusCode:  200,
+		Description: t(c, i18n.MsgPreferencesRetrieved),
+		Data:        prefs,
+	})
+}
+
+// @Summary      Update preferences
+// @Description  Partially updates the current user's preferences. Omitted fields keep their value.
+// @Tags         Users
+// @Accept       json
+// @Produce      json
+// @Param        body body domain.UpdatePreferencesRequest true "Changed preferences"
+// @Security     BearerAuth
+// @Success      200 {object} response.Response{data=domain.Preferences} "Preferences updated"
+// @Failure      400 {object} response.Problem "Invalid payload"
+// @Failure      422 {object} response.Problem "Invalid locale or timezone"
+// @Router       /users/me/preferences [patch]
+func (h *UserHandler) UpdatePreferences(c echo.Context) error {
+	var req domain.UpdatePreferencesRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	prefs, err := h.service.UpdatePreferences(c.Request().Context(), middleware.GetUserID(c), &req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response.Response{
+		StatusCode:  200,
+		Description: t(c, i18n.MsgPreferencesUpdated),
+		Data:        prefs,
+	})
+}
+
+// @Summary      Suspend user
+// @Description  Temporarily blocks an account. Existing tokens of the account stop working.
+// @Tags         Admin
+// @Accept       json
+// @Produce      json
+// @Param        id path string true "User ID"
+// @Param        body body domain.SuspendRequest true "Reason and optional end date"
+// @Security     BearerAuth
+// @Success      200 {object} response.Response "User suspended"
+// @Failure      400 {object} response.Problem "Invalid request"
+// @Failure      403 {object} response.Problem "Forbidden"
+// @Failure      404 {object} response.Problem "User not found"
+// @Failure      409 {object} response.Problem "Invalid status transition"
+// @Router       /admin/users/{id}/suspend [post]
+func (h *UserHandler) Suspend(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return errs.BadRequest("invalid user id")
+	}
+
+	var req domain.SuspendRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	if err := h.service.Suspend(c.Request().Context(), middleware.GetUserID(c), id, &req); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response.Response{
+		StatusCode:  200,
+		Description: t(c, i18n.MsgUserSuspended),
+	})
+}
+
+// @Summary      Unsuspend user
+// @Description  Reactivates a suspended or locked account
+// @Tags         Admin
+// @Accept       json
+// @Produce      json
+// @Param        id path string true "User ID"
+// @Param        body body domain.UnsuspendRequest false "Optional note"
+// @Security     BearerAuth
+// @Success      200 {object} response.Response "User unsuspended"
+// @Failure      400 {object} response.Problem "Invalid request"
+// @Failure      403 {object} response.Problem "Forbidden"
+// @Failure      404 {object} response.Problem "User not found"
+// @Failure      409 {object} response.Problem "Invalid status transition"
+// @Router       /admin/users/{id}/unsuspend [post]
+func (h *UserHandler) Unsuspend(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return errs.BadRequest("invalid user id")
+	}
+
+	var req domain.UnsuspendRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	if err := h.service.Unsuspend(c.Request().Context(), middleware.GetUserID(c), id, &req); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response.Response{
+		StatusCode:  200,
+		Description: t(c, i18n.MsgUserUnsuspended),
+	})
+}
+
+// @Summary      Ban user
+// @Description  Permanently blocks an account. Existing tokens of the account stop working.
+// @Tags         Admin
+// @Accept       json
+// @Produce      json
+// @Param        id path string true "User ID"
+// @Param        body body domain.BanRequest true "Reason"
+// @Security     BearerAuth
+// @Success      200 {object} response.Response "User banned"
+// @Failure      400 {object} response.Problem "Invalid request"
+// @Failure      403 {object} response.Problem "Forbidden"
+// @Failure      404 {object} response.Problem "User not found"
+// @Failure      409 {object} response.Problem "Invalid status transition"
+// @Router       /admin/users/{id}/ban [post]
+func (h *UserHandler) Ban(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return errs.BadRequest("invalid user id")
+	}
+
+	var req domain.BanRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	if err := h.service.Ban(c.Request().Context(), middleware.GetUserID(c), id, &req); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response.Response{
+		StatusCode:  200,
+		Description: t(c, i18n.MsgUserBanned),
+	})
+}
+
+// @Summary      Search users
+// @Description  Full-text and fuzzy search over first name, last name and email. Matches are ranked; highlights are escaped HTML with matches wrapped in <mark>.
+// @Tags         Admin
+// @Produce      json
+// @Param        q query string true "Search text"
+// @Param        page query int false "Page number" default(1)
+// @Param        page_size query int false "Page size" default(20)
+// @Security     BearerAuth
+// @Success      200 {object} response.Response{data=domain.UserSearchList} "Search results"
+// @Failure      400 {object} response.Problem "Invalid query"
+// @Failure      403 {object} response.Problem "Forbidden"
+// @Failure      500 {object} response.Problem "Internal server error"
+// @Router       /admin/users/search [get]
+func (h *UserHandler) Search(c echo.Context) error {
+	var req domain.SearchUsersRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	result, err := h.service.Search(c.Request().Context(), &req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response.Response{
+		StatusCode:  200,
+		Description: t(c, i18n.MsgUsersFound),
+		Data:        result,
+	})
+}
+
+// @Summary      Impersonate user
+// @Description  Issues a short-lived access token acting as the user. Every request made with it is audited.
+// @Tags         Admin
+// @Accept       json
+// @Produce      json
+// @Param        id path string true "User ID"
+// @Param        body body domain.ImpersonateRequest true "Reason"
+// @Security     BearerAuth
+// @Success      200 {object} response.Response{data=domain.ImpersonationResponse} "Impersonation token issued"
+// @Failure      400 {object} response.Problem "Invalid request"
+// @Failure      403 {object} response.Problem "Forbidden"
+// @Failure      404 {object} response.Problem "User not found"
+// @Router       /admin/users/{id}/impersonate [post]
+func (h *UserHandler) Impersonate(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return errs.BadRequest("invalid user id")
+	}
+
+	var req domain.ImpersonateRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	req.IP = c.RealIP()
+	req.UserAgent = c.Request().UserAgent()
+
+	resp, err := h.service.Impersonate(c.Request().Context(), middleware.GetUserID(c), id, &req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response.Response{
+		StatusCode:  200,
+		Description: t(c, i18n.MsgImpersonationIssued),
+		Data:        resp,
 	})
 }
@@ -0,0 +1,263 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+
+	"github.com/infosec554/clean-archtectura/config"
+	errs "github.com/infosec554/clean-archtectura/domain"
+	domain "github.com/infosec554/clean-archtectura/domain/users"
+	"github.com/infosec554/clean-archtectura/pkg/validator"
+)
+
+var (
+	testUserID    = uuid.MustParse("7f1c2a64-2d7e-4e8a-9a43-1f5b7c3d9e01")
+	missingUserID = uuid.MustParse("00000000-0000-0000-0000-000000000404")
+)
+
+// fakeUserService returns fully populated values, so every documented field
+// shows up in the responses being checked
+type fakeUserService struct{}
+
+func (fakeUserService) user() domain.UserResponse {
+	changed := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	return domain.UserResponse{
+		ID:              testUserID,
+		FirstName:       "Aziz",
+		LastName:        "Karimov",
+		Email:           "aziz@example.com",
+		EmailVerified:   true,
+		Role:            domain.RoleUser,
+		Status:          domain.AccountStatusSuspended,
+		StatusReason:    "spam",
+		StatusChangedAt: &changed,
+		SuspendedUntil:  &changed,
+		CreatedAt:       changed,
+		UpdatedAt:       changed,
+	}
+}
+
+func (fakeUserService) found(id uuid.UUID) error {
+	if id == missingUserID {
+		return errs.NotFound("user not found")
+	}
+	return nil
+}
+
+func (f fakeUserService) Register(context.Context, *domain.CreateUser) (string, error) {
+	return testUserID.String(), nil
+}
+
+func (f fakeUserService) Login(_ context.Context, req *domain.LoginRequest) (domain.LoginResponse, error) {
+	if req.Password != "Secret123!" {
+		return domain.LoginResponse{}, errs.Unauthorized("invalid email or password")
+	}
+	return domain.LoginResponse{User: f.user(), AccessToken: "access", RefreshToken: "refresh"}, nil
+}
+
+func (f fakeUserService) SendVerificationCode(context.Context, *domain.ResendCodeRequest) error {
+	return nil
+}
+
+func (f fakeUserService) VerifyEmail(context.Context, *domain.VerifyEmailRequest) error {
+	return nil
+}
+
+func (f fakeUserService) GetByID(_ context.Context, id uuid.UUID) (domain.UserResponse, error) {
+	return f.user(), f.found(id)
+}
+
+func (f fakeUserService) Update(_ context.Context, req *domain.UpdateUser) (string, error) {
+	return req.ID.String(), f.found(req.ID)
+}
+
+func (f fakeUserService) UpdatePassword(_ context.Context, id uuid.UUID, _ *domain.UpdatePasswordRequest) error {
+	return f.found(id)
+}
+
+func (f fakeUserService) Delete(_ context.Context, id uuid.UUID) error {
+	return f.found(id)
+}
+
+func (f fakeUserService) Suspend(_ context.Context, _, id uuid.UUID, _ *domain.SuspendRequest) error {
+	return f.found(id)
+}
+
+func (f fakeUserService) Unsuspend(_ context.Context, _, id uuid.UUID, _ *domain.UnsuspendRequest) error {
+	return f.found(id)
+}
+
+func (f fakeUserService) Ban(_ context.Context, _, id uuid.UUID, _ *domain.BanRequest) error {
+	return f.found(id)
+}
+
+func (f fakeUserService) Search(context.Context, *domain.SearchUsersRequest) (domain.UserSearchList, error) {
+	return domain.UserSearchList{
+		List: []domain.UserSearchResult{{
+			User:      f.user(),
+			Rank:      0.75,
+			Highlight: domain.UserHighlight{FirstName: "<mark>Aziz</mark>", LastName: "Karimov", Email: "aziz@example.com"},
+		}},
+		Meta: domain.Meta{Total: 1, Page: 1, PageSize: 20, PageCount: 1},
+	}, nil
+}
+
+func (f fakeUserService) Impersonate(_ context.Context, _, id uuid.UUID, _ *domain.ImpersonateRequest) (domain.ImpersonationResponse, error) {
+	return domain.ImpersonationResponse{User: f.user(), AccessToken: "access", ExpiresAt: time.Now().UTC()}, f.found(id)
+}
+
+func (f fakeUserService) GetPreferences(context.Context, uuid.UUID) (domain.Preferences, error) {
+	return domain.DefaultPreferences(), nil
+}
+
+func (f fakeUserService) UpdatePreferences(_ context.Context, _ uuid.UUID, req *domain.UpdatePreferencesRequest) (domain.Preferences, error) {
+	prefs := domain.DefaultPreferences()
+	if req.Locale != nil {
+		prefs.Locale = *req.Locale
+	}
+	return prefs, nil
+}
+
+// newUserRouter mounts UserHandler the way main does, with an admin session
+// standing in for JWTAuth
+func newUserRouter() *echo.Echo {
+	return newUserRouterAs(uuid.Nil)
+}
+
+// newUserRouterAs is newUserRouter with the session opened by actorID through
+// impersonation, unless actorID is uuid.Nil
+func newUserRouterAs(actorID uuid.UUID) *echo.Echo {
+	e := echo.New()
+	e.Validator = validator.New()
+	e.HTTPErrorHandler = ErrorHandler(zerolog.Nop())
+
+	api := e.Group("/api/v1")
+	private := api.Group("", func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set("user_id", testUserID.String())
+			c.Set("role", string(domain.RoleAdmin))
+			if actorID != uuid.Nil {
+				c.Set("actor_id", actorID.String())
+			}
+			return next(c)
+		}
+	})
+	NewUserHandler(api.Group(""), private, fakeUserService{}, config.Config{}, nil, zerolog.Nop())
+	return e
+}
+
+func TestUserRoutesDocumented(t *testing.T) {
+	s := loadSpec(t)
+	for _, r := range newUserRouter().Routes() {
+		if r.Method == echo.RouteNotFound {
+			continue
+		}
+		if _, _, ok := s.operation(r.Method, echoPath(r.Path)); !ok {
+			t.Errorf("%s %s is not documented in docs/swagger.yaml", r.Method, r.Path)
+		}
+	}
+}
+
+// TestSpecDescribesServedRoutes is the converse of TestUserRoutesDocumented:
+// every documented operation must be served, so the spec cannot drift back to
+// describing another API
+func TestSpecDescribesServedRoutes(t *testing.T) {
+	s := loadSpec(t)
+	e := newUserRouter()
+	api := e.Group("/api/v1")
+	NewExportHandler(api, api, nil, zerolog.Nop())
+	NewHealthHandler(api, nil)
+
+	served := map[string]bool{}
+	for _, r := range e.Routes() {
+		served[r.Method+" "+echoPath(r.Path)] = true
+	}
+	for template, ops := range s.Paths {
+		for method := range ops {
+			route := strings.ToUpper(method) + " " + s.BasePath + template
+			if !served[route] {
+				t.Errorf("%s is documented but not served", route)
+			}
+		}
+	}
+}
+
+func TestUserHandlerMatchesSpec(t *testing.T) {
+	s := loadSpec(t)
+	e := newUserRouter()
+
+	user := "/api/v1/users/" + testUserID.String()
+	missing := "/api/v1/users/" + missingUserID.String()
+	admin := "/api/v1/admin/users/" + testUserID.String()
+	ru := domain.LocaleRu
+
+	cases := []specCase{
+		{name: "register", method: http.MethodPost, path: "/api/v1/register", status: http.StatusCreated,
+			body: domain.CreateUser{FirstName: "Aziz", LastName: "Karimov", Email: "aziz@example.com", Password: "Secret123!"}},
+		{name: "register invalid", method: http.MethodPost, path: "/api/v1/register", status: http.StatusUnprocessableEntity,
+			body: domain.CreateUser{FirstName: "Aziz", LastName: "Karimov", Email: "not-an-email"}},
+		{name: "login", method: http.MethodPost, path: "/api/v1/login", status: http.StatusOK,
+			body: domain.LoginRequest{Email: "aziz@example.com", Password: "Secret123!"}},
+		{name: "login wrong password", method: http.MethodPost, path: "/api/v1/login", status: http.StatusUnauthorized,
+			body: domain.LoginRequest{Email: "aziz@example.com", Password: "wrong"}},
+		{name: "verify email", method: http.MethodPost, path: "/api/v1/verify-email", status: http.StatusOK,
+			body: domain.VerifyEmailRequest{Email: "aziz@example.com", Code: "123456"}},
+		{name: "resend code", method: http.MethodPost, path: "/api/v1/resend-code", status: http.StatusOK,
+			body: domain.ResendCodeRequest{Email: "aziz@example.com"}},
+		{name: "logout", method: http.MethodPost, path: "/api/v1/logout", status: http.StatusOK},
+		{name: "get user", method: http.MethodGet, path: user, status: http.StatusOK},
+		{name: "get missing user", method: http.MethodGet, path: missing, status: http.StatusNotFound},
+		{name: "get invalid id", method: http.MethodGet, path: "/api/v1/users/42", status: http.StatusBadRequest},
+		{name: "update user", method: http.MethodPut, path: user, status: http.StatusOK,
+			body: domain.UpdateUser{FirstName: "Aziza"}},
+		{name: "update password", method: http.MethodPut, path: user + "/password", status: http.StatusOK,
+			body: domain.UpdatePasswordRequest{OldPassword: "Secret123!", NewPassword: "Secret456!"}},
+		{name: "delete user", method: http.MethodDelete, path: user, status: http.StatusOK},
+		{name: "delete missing user", method: http.MethodDelete, path: missing, status: http.StatusNotFound},
+		{name: "get preferences", method: http.MethodGet, path: "/api/v1/users/me/preferences", status: http.StatusOK},
+		{name: "update preferences", method: http.MethodPatch, path: "/api/v1/users/me/preferences", status: http.StatusOK,
+			body: domain.UpdatePreferencesRequest{Locale: &ru}},
+		{name: "suspend", method: http.MethodPost, path: admin + "/suspend", status: http.StatusOK,
+			body: domain.SuspendRequest{Reason: "spam"}},
+		{name: "unsuspend", method: http.MethodPost, path: admin + "/unsuspend", status: http.StatusOK,
+			body: domain.UnsuspendRequest{Reason: "appeal accepted"}},
+		{name: "ban", method: http.MethodPost, path: admin + "/ban", status: http.StatusOK,
+			body: domain.BanRequest{Reason: "fraud"}},
+		{name: "search", method: http.MethodGet, path: "/api/v1/admin/users/search?q=aziz", status: http.StatusOK},
+		{name: "impersonate", method: http.MethodPost, path: admin + "/impersonate", status: http.StatusOK,
+			body: domain.ImpersonateRequest{Reason: "support ticket 42"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s.check(t, e, tc)
+		})
+	}
+}
+
+func TestImpersonatedAccountChanges(t *testing.T) {
+	s := loadSpec(t)
+	e := newUserRouterAs(uuid.New())
+	user := "/api/v1/users/" + testUserID.String()
+
+	cases := []specCase{
+		{name: "update user", method: http.MethodPut, path: user, status: http.StatusForbidden,
+			body: domain.UpdateUser{FirstName: "Aziza"}},
+		{name: "update password", method: http.MethodPut, path: user + "/password", status: http.StatusForbidden,
+			body: domain.UpdatePasswordRequest{OldPassword: "Secret123!", NewPassword: "Secret456!"}},
+		{name: "delete user", method: http.MethodDelete, path: user, status: http.StatusForbidden},
+		{name: "get user", method: http.MethodGet, path: user, status: http.StatusOK},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s.check(t, e, tc)
+		})
+	}
+}
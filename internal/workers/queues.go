@@ -0,0 +1,37 @@
+package workers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseQueues parses a comma-separated list of queue=concurrency pairs,
+// e.g. "email=5,default=2"
+func ParseQueues(spec string) (map[string]int, error) {
+	queues := make(map[string]int)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		name, value, ok := strings.Cut(item, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("queue %q: expected name=concurrency", item)
+		}
+		concurrency, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || concurrency <= 0 {
+			return nil, fmt.Errorf("queue %q: concurrency must be a positive integer", item)
+		}
+		if _, dup := queues[name]; dup {
+			return nil, fmt.Errorf("queue %q: listed more than once", name)
+		}
+		queues[name] = concurrency
+	}
+	if len(queues) == 0 {
+		return nil, fmt.Errorf("no queues configured")
+	}
+	return queues, nil
+}
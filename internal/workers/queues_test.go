@@ -0,0 +1,36 @@
+package workers
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestParseQueues(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    map[string]int
+		wantErr bool
+	}{
+		{"default=2,email=5", map[string]int{"default": 2, "email": 5}, false},
+		{" default = 2 , email=5, ", map[string]int{"default": 2, "email": 5}, false},
+		{"default=1", map[string]int{"default": 1}, false},
+		{"", nil, true},
+		{" , ", nil, true},
+		{"default", nil, true},
+		{"=2", nil, true},
+		{"default=0", nil, true},
+		{"default=-1", nil, true},
+		{"default=two", nil, true},
+		{"default=2,default=3", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseQueues(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseQueues(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if !maps.Equal(got, tt.want) {
+			t.Errorf("ParseQueues(%q) = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+
+	outbox "github.com/infosec554/clean-archtectura/domain/outbox"
+	"github.com/infosec554/clean-archtectura/pkg/metrics"
+	"github.com/infosec554/clean-archtectura/pkg/tracing"
+)
+
+// purgeInterval is how often delivered events past the retention are deleted
+const purgeInterval = time.Hour
+
+// OutboxStore is the transactional outbox. Every method runs on the
+// transaction in the context.
+type OutboxStore interface {
+	Pending(ctx context.Context, limit int) ([]outbox.Event, error)
+	MarkDelivered(ctx context.Context, id uuid.UUID) error
+	MarkFailed(ctx context.Context, id uuid.UUID, delay time.Duration, errMsg string) error
+	MarkDead(ctx context.Context, id uuid.UUID, errMsg string) error
+	Purge(ctx context.Context, retention time.Duration) (int64, error)
+}
+
+// UnitOfWork runs fn in a transaction, or in a savepoint when ctx already
+// carries one
+type UnitOfWork interface {
+	WithTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// Notifier calls fn for every notification on channel until ctx is done or
+// it fails
+type Notifier interface {
+	Listen(ctx context.Context, channel string, fn func(payload string)) error
+}
+
+type subscriber func(ctx context.Context, id uuid.UUID, payload []byte) error
+
+// Relay delivers outbox events to their subscribers.
+//
+// An event is delivered in a transaction that also marks it delivered, so
+// what subscribers write to the database, such as the jobs they enqueue,
+// commits exactly once. Anything else they do may be repeated: the
+// transaction can fail after a subscriber ran, and the event is then
+// delivered again. Subscribers must be idempotent; the event ID identifies a
+// delivery across attempts.
+//
+// A failed delivery is retried with backoff up to maxAttempts times, then
+// the event is marked dead. Events of a topic without subscribers fail the
+// same way, so one a newer release publishes is retried by the relays that
+// know it while an unknown one does not circulate forever.
+type Relay struct {
+	store       OutboxStore
+	uow         UnitOfWork
+	notifier    Notifier
+	channel     string
+	subscribers map[string][]subscriber
+	poll        time.Duration
+	batch       int
+	retention   time.Duration
+	maxAttempts int
+	logger      zerolog.Logger
+}
+
+// NewRelay creates a relay that reads up to batch events at a time. It polls
+// the outbox every poll, sooner when notified on channel, keeps delivered
+// events for retention and gives up on an event after maxAttempts failures.
+func NewRelay(store OutboxStore, uow UnitOfWork, notifier Notifier, channel string, poll time.Duration, batch int, retention time.Duration, maxAttempts int, logger zerolog.Logger) *Relay {
+	return &Relay{
+		store:       store,
+		uow:         uow,
+		notifier:    notifier,
+		channel:     channel,
+		subscribers: make(map[string][]subscriber),
+		poll:        poll,
+		batch:       batch,
+		retention:   retention,
+		maxAttempts: maxAttempts,
+		logger:      logger.With().Str("component", "outbox_relay").Logger(),
+	}
+}
+
+// Subscribe registers fn for events of topic. A payload that does not decode
+// fails the delivery like any other error. Register every subscriber before
+// Run.
+func Subscribe[T any](r *Relay, topic outbox.Topic[T], fn func(ctx context.Context, id uuid.UUID, payload T) error) {
+	r.subscribers[topic.Name] = append(r.subscribers[topic.Name], func(ctx context.Context, id uuid.UUID, payload []byte) error {
+		v, err := topic.Decode(payload)
+		if err != nil {
+			return fmt.Errorf("decode payload: %w", err)
+		}
+		return fn(ctx, id, v)
+	})
+}
+
+// Run delivers events until ctx is done
+func (r *Relay) Run(ctx context.Context) error {
+	wake := make(chan struct{}, 1)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		r.listen(ctx, wake)
+	}()
+	defer wg.Wait()
+
+	ticker := time.NewTicker(r.poll)
+	defer ticker.Stop()
+	purge := time.NewTicker(purgeInterval)
+	defer purge.Stop()
+
+	r.logger.Info().Msg("Outbox relay started")
+	for {
+		n, err := r.relayBatch(ctx)
+		if err != nil && ctx.Err() == nil {
+			r.logger.Error().Err(err).Msg("Failed to relay outbox events")
+		}
+
+		// A full batch means more events may be due
+		if err == nil && n == r.batch && ctx.Err() == nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		case <-wake:
+		case <-purge.C:
+			r.purge(ctx)
+		}
+	}
+}
+
+// listen wakes the relay on notifications, reconnecting after failures;
+// polling covers the gaps
+func (r *Relay) listen(ctx context.Context, wake chan<- struct{}) {
+	for {
+		err := r.notifier.Listen(ctx, r.channel, func(string) {
+			select {
+			case wake <- struct{}{}:
+			default:
+			}
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		r.logger.Warn().Err(err).Dur("retry_in", r.poll).Msg("Outbox notifications lost, polling only")
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(r.poll):
+		}
+	}
+}
+
+// relayBatch delivers one batch of due events in a transaction and reports
+// how many it read
+func (r *Relay) relayBatch(ctx context.Context) (int, error) {
+	var n int
+	err := r.uow.WithTx(ctx, func(ctx context.Context) error {
+		events, err := r.store.Pending(ctx, r.batch)
+		if err != nil {
+			return err
+		}
+		n = len(events)
+
+		for _, event := range events {
+			if err := r.deliver(ctx, event); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return n, err
+}
+
+// deliver runs the subscribers of event in a savepoint, so a failing one
+// undoes the writes of all of them, and records the outcome. Only an error
+// recording the outcome is returned.
+func (r *Relay) deliver(ctx context.Context, event outbox.Event) error {
+	logger := r.logger.With().
+		Str("event_id", event.ID.String()).
+		Str("topic", event.Topic).
+		Int("attempts", event.Attempts).
+		Logger()
+	ctx = logger.WithContext(ctx)
+
+	ctx, span := tracing.Start(ctx, "Outbox "+event.Topic,
+		attribute.String("outbox.event_id", event.ID.String()),
+		attribute.Int("outbox.attempts", event.Attempts),
+	)
+	defer span.End()
+
+	err := r.uow.WithTx(ctx, func(ctx context.Context) error {
+		return r.dispatch(ctx, event)
+	})
+	tracing.RecordError(span, err)
+
+	if err == nil {
+		metrics.OutboxDeliveries.WithLabelValues(event.Topic, metrics.ResultSuccess).Inc()
+		return r.store.MarkDelivered(ctx, event.ID)
+	}
+
+	if event.Attempts+1 >= r.maxAttempts {
+		metrics.OutboxDeliveries.WithLabelValues(event.Topic, metrics.ResultDead).Inc()
+		logger.Error().Err(err).Msg("Outbox delivery failed permanently")
+		return r.store.MarkDead(ctx, event.ID, err.Error())
+	}
+
+	metrics.OutboxDeliveries.WithLabelValues(event.Topic, metrics.ResultRetry).Inc()
+	delay := backoff(event.Attempts + 1)
+	logger.Warn().Err(err).Dur("retry_in", delay).Msg("Outbox delivery failed, will retry")
+	return r.store.MarkFailed(ctx, event.ID, delay, err.Error())
+}
+
+// dispatch calls every subscriber of the event, turning a panic into an error
+func (r *Relay) dispatch(ctx context.Context, event outbox.Event) (err error) {
+	// A relay of an older release may not know a newer topic; another one will
+	subs, ok := r.subscribers[event.Topic]
+	if !ok {
+		return fmt.Errorf("no subscribers for topic %q", event.Topic)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("subscriber panicked: %v", p)
+		}
+	}()
+	for _, sub := range subs {
+		if err := sub(ctx, event.ID, event.Payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Relay) purge(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, bookkeepingTimeout)
+	defer cancel()
+
+	n, err := r.store.Purge(ctx, r.retention)
+	if err != nil {
+		r.logger.Error().Err(err).Msg("Failed to purge delivered outbox events")
+		return
+	}
+	if n > 0 {
+		r.logger.Info().Int64("count", n).Msg("Purged delivered outbox events")
+	}
+}
@@ -0,0 +1,262 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+
+	outbox "github.com/infosec554/clean-archtectura/domain/outbox"
+)
+
+// fakeOutbox serves its undelivered events and records their outcomes
+type fakeOutbox struct {
+	mu        sync.Mutex
+	events    []outbox.Event
+	outcomes  map[uuid.UUID][]string
+	pending   int
+	delays    []time.Duration
+	deliverFn func(id uuid.UUID) error
+}
+
+func newFakeOutbox(events ...outbox.Event) *fakeOutbox {
+	return &fakeOutbox{events: events, outcomes: make(map[uuid.UUID][]string)}
+}
+
+func (o *fakeOutbox) Pending(_ context.Context, limit int) ([]outbox.Event, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.pending++
+	var due []outbox.Event
+	for _, e := range o.events {
+		if last := o.last(e.ID); last != "delivered" && last != "dead" && len(due) < limit {
+			due = append(due, e)
+		}
+	}
+	return due, nil
+}
+
+func (o *fakeOutbox) MarkDelivered(_ context.Context, id uuid.UUID) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.deliverFn != nil {
+		if err := o.deliverFn(id); err != nil {
+			return err
+		}
+	}
+	o.outcomes[id] = append(o.outcomes[id], "delivered")
+	return nil
+}
+
+func (o *fakeOutbox) MarkFailed(_ context.Context, id uuid.UUID, delay time.Duration, _ string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.outcomes[id] = append(o.outcomes[id], "failed")
+	o.delays = append(o.delays, delay)
+	return nil
+}
+
+func (o *fakeOutbox) MarkDead(_ context.Context, id uuid.UUID, errMsg string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if errMsg == "" {
+		return errors.New("dead event without its error")
+	}
+	o.outcomes[id] = append(o.outcomes[id], "dead")
+	return nil
+}
+
+func (o *fakeOutbox) Purge(context.Context, time.Duration) (int64, error) {
+	return 0, nil
+}
+
+func (o *fakeOutbox) last(id uuid.UUID) string {
+	if list := o.outcomes[id]; len(list) > 0 {
+		return list[len(list)-1]
+	}
+	return ""
+}
+
+func (o *fakeOutbox) lastOutcome(id uuid.UUID) string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.last(id)
+}
+
+func (o *fakeOutbox) pendingCalls() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.pending
+}
+
+// directTx runs fn without a transaction
+type directTx struct{}
+
+func (directTx) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+// fakeNotifier delivers what is sent on notify until ctx is done
+type fakeNotifier struct {
+	notify chan string
+}
+
+func (n fakeNotifier) Listen(ctx context.Context, _ string, fn func(payload string)) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case p := <-n.notify:
+			fn(p)
+		}
+	}
+}
+
+type greeting struct {
+	Name string `json:"name"`
+}
+
+var greeted = outbox.Topic[greeting]{Name: "test.greeted"}
+
+func testEvent(t *testing.T, topic string, payload string, attempts int) outbox.Event {
+	t.Helper()
+	return outbox.Event{ID: uuid.New(), Topic: topic, Payload: []byte(payload), Attempts: attempts}
+}
+
+func newTestRelay(store OutboxStore, notifier Notifier, poll time.Duration) *Relay {
+	return NewRelay(store, directTx{}, notifier, "outbox", poll, 10, time.Hour, 3, zerolog.Nop())
+}
+
+func TestDeliver(t *testing.T) {
+	tests := []struct {
+		name     string
+		topic    string
+		payload  string
+		attempts int
+		want     string
+	}{
+		{"success", greeted.Name, `{"name":"aziz"}`, 0, "delivered"},
+		{"subscriber error", greeted.Name, `{"name":"fail"}`, 0, "failed"},
+		{"subscriber panic", greeted.Name, `{"name":"panic"}`, 1, "failed"},
+		{"undecodable payload", greeted.Name, `not json`, 0, "failed"},
+		{"no subscribers", "test.unknown", `{}`, 0, "failed"},
+		{"last attempt", greeted.Name, `{"name":"fail"}`, 2, "dead"},
+		{"no subscribers on the last attempt", "test.unknown", `{}`, 2, "dead"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newFakeOutbox()
+			r := newTestRelay(store, nil, time.Hour)
+			var got []string
+			Subscribe(r, greeted, func(_ context.Context, _ uuid.UUID, g greeting) error {
+				switch g.Name {
+				case "fail":
+					return errors.New("smtp unavailable")
+				case "panic":
+					panic("boom")
+				}
+				got = append(got, g.Name)
+				return nil
+			})
+
+			event := testEvent(t, tt.topic, tt.payload, tt.attempts)
+			if err := r.deliver(context.Background(), event); err != nil {
+				t.Fatal(err)
+			}
+			if outcome := store.lastOutcome(event.ID); outcome != tt.want {
+				t.Fatalf("outcome = %q, want %q", outcome, tt.want)
+			}
+			if tt.want == "delivered" && (len(got) != 1 || got[0] != "aziz") {
+				t.Fatalf("subscriber got %q, want the decoded payload", got)
+			}
+			if tt.want == "failed" {
+				if d := store.delays[0]; d < retryBaseDelay/2 || d > retryMaxDelay {
+					t.Fatalf("retry delay = %v", d)
+				}
+			}
+		})
+	}
+}
+
+func TestSubscribeFansOut(t *testing.T) {
+	r := newTestRelay(newFakeOutbox(), nil, time.Hour)
+	var calls []string
+	for _, name := range []string{"first", "second"} {
+		Subscribe(r, greeted, func(_ context.Context, id uuid.UUID, g greeting) error {
+			calls = append(calls, name+":"+g.Name)
+			return nil
+		})
+	}
+
+	event := testEvent(t, greeted.Name, `{"name":"aziz"}`, 0)
+	if err := r.dispatch(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 2 || calls[0] != "first:aziz" || calls[1] != "second:aziz" {
+		t.Fatalf("calls = %q, want both subscribers in order", calls)
+	}
+}
+
+func TestRelayBatchStopsOnBookkeepingError(t *testing.T) {
+	first, second := testEvent(t, greeted.Name, `{}`, 0), testEvent(t, greeted.Name, `{}`, 0)
+	store := newFakeOutbox(first, second)
+	store.deliverFn = func(id uuid.UUID) error {
+		if id == first.ID {
+			return errors.New("connection reset")
+		}
+		return nil
+	}
+	r := newTestRelay(store, nil, time.Hour)
+	Subscribe(r, greeted, func(context.Context, uuid.UUID, greeting) error { return nil })
+
+	// The batch's transaction is rolled back as a whole
+	if _, err := r.relayBatch(context.Background()); err == nil {
+		t.Fatal("relayBatch hid the bookkeeping error")
+	}
+	if outcome := store.lastOutcome(second.ID); outcome != "" {
+		t.Fatalf("second event = %q, want it left for the retry", outcome)
+	}
+}
+
+func TestRunWakesOnNotify(t *testing.T) {
+	store := newFakeOutbox()
+	notifier := fakeNotifier{notify: make(chan string)}
+	// Polling alone would not find the event during the test
+	r := newTestRelay(store, notifier, time.Hour)
+	delivered := make(chan uuid.UUID, 1)
+	Subscribe(r, greeted, func(_ context.Context, id uuid.UUID, _ greeting) error {
+		delivered <- id
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- r.Run(ctx) }()
+
+	for store.pendingCalls() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	event := testEvent(t, greeted.Name, `{"name":"aziz"}`, 0)
+	store.mu.Lock()
+	store.events = append(store.events, event)
+	store.mu.Unlock()
+	notifier.notify <- ""
+
+	select {
+	case id := <-delivered:
+		if id != event.ID {
+			t.Fatalf("delivered %s, want %s", id, event.ID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("notification did not wake the relay")
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,220 @@
+// Package workers runs background jobs from the Postgres job queue and
+// relays the transactional outbox.
+//
+// Delivery is at least once: a job whose worker dies before finishing is
+// claimed again once its lease runs out, so handlers must be idempotent.
+package workers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+
+	jobs "github.com/infosec554/clean-archtectura/domain/jobs"
+	"github.com/infosec554/clean-archtectura/pkg/metrics"
+	"github.com/infosec554/clean-archtectura/pkg/tracing"
+)
+
+// Backoff between attempts of a failing job
+const (
+	retryBaseDelay = 10 * time.Second
+	retryMaxDelay  = time.Hour
+)
+
+// bookkeepingTimeout bounds the queue updates after a job has run; they
+// must happen even when the job used up its own time
+const bookkeepingTimeout = 10 * time.Second
+
+// Store is the job queue
+type Store interface {
+	Claim(ctx context.Context, queue string, limit int, lease time.Duration) ([]jobs.Job, error)
+	// Complete, Retry and Bury return jobs.ErrLeaseLost when the attempt
+	// no longer holds the job
+	Complete(ctx context.Context, id uuid.UUID, attempt int) error
+	Retry(ctx context.Context, id uuid.UUID, attempt int, delay time.Duration, errMsg string) error
+	Bury(ctx context.Context, id uuid.UUID, attempt int, errMsg string) error
+}
+
+type handler func(ctx context.Context, payload []byte) error
+
+// Worker claims jobs from its queues and runs their handlers, with at most
+// the queue's concurrency running at once
+type Worker struct {
+	store    Store
+	queues   map[string]int
+	handlers map[string]handler
+	poll     time.Duration
+	lease    time.Duration
+	logger   zerolog.Logger
+}
+
+// New creates a worker for queues, which maps queue names to concurrency.
+// A job gets lease to finish before it is considered abandoned.
+func New(store Store, queues map[string]int, poll, lease time.Duration, logger zerolog.Logger) *Worker {
+	return &Worker{
+		store:    store,
+		queues:   queues,
+		handlers: make(map[string]handler),
+		poll:     poll,
+		lease:    lease,
+		logger:   logger.With().Str("component", "worker").Logger(),
+	}
+}
+
+// Handle registers fn for jobs of kind. A payload that does not decode is
+// a permanent failure. Register every handler before Run.
+func Handle[T any](w *Worker, kind jobs.Kind[T], fn func(ctx context.Context, payload T) error) {
+	w.handlers[kind.Name] = func(ctx context.Context, payload []byte) error {
+		v, err := kind.Decode(payload)
+		if err != nil {
+			return jobs.Permanent(fmt.Errorf("decode payload: %w", err))
+		}
+		return fn(ctx, v)
+	}
+}
+
+// Run processes jobs until ctx is done, then waits for the running ones
+func (w *Worker) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for queue, concurrency := range w.queues {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.runQueue(ctx, queue, concurrency)
+		}()
+	}
+	w.logger.Info().Any("queues", w.queues).Msg("Worker started")
+
+	wg.Wait()
+	return nil
+}
+
+func (w *Worker) runQueue(ctx context.Context, queue string, concurrency int) {
+	slots := make(chan struct{}, concurrency)
+	var running sync.WaitGroup
+	defer running.Wait()
+
+	ticker := time.NewTicker(w.poll)
+	defer ticker.Stop()
+
+	for {
+		claimed, free := 0, concurrency-len(slots)
+		if free > 0 {
+			list, err := w.store.Claim(ctx, queue, free, w.lease)
+			if err != nil && ctx.Err() == nil {
+				w.logger.Error().Err(err).Str("queue", queue).Msg("Failed to claim jobs")
+			}
+			claimed = len(list)
+
+			for _, job := range list {
+				slots <- struct{}{}
+				running.Add(1)
+				go func() {
+					defer func() {
+						<-slots
+						running.Done()
+					}()
+					// A job that started finishes even when shutdown begins
+					w.process(context.WithoutCancel(ctx), job)
+				}()
+			}
+		}
+
+		// A full batch means more jobs may be due; otherwise wait for the next poll
+		if claimed > 0 && claimed == free && ctx.Err() == nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Worker) process(ctx context.Context, job jobs.Job) {
+	logger := w.logger.With().
+		Str("job_id", job.ID.String()).
+		Str("queue", job.Queue).
+		Str("kind", job.Kind).
+		Int("attempt", job.Attempt).
+		Logger()
+	ctx = logger.WithContext(ctx)
+
+	ctx, span := tracing.Start(ctx, "Job "+job.Kind,
+		attribute.String("job.id", job.ID.String()),
+		attribute.String("job.queue", job.Queue),
+		attribute.Int("job.attempt", job.Attempt),
+	)
+	defer span.End()
+
+	start := time.Now()
+	err := w.run(ctx, job)
+	metrics.JobDuration.WithLabelValues(job.Queue, job.Kind).Observe(time.Since(start).Seconds())
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), bookkeepingTimeout)
+	defer cancel()
+
+	var result string
+	var storeErr error
+	switch {
+	case err == nil:
+		result = metrics.ResultSuccess
+		storeErr = w.store.Complete(ctx, job.ID, job.Attempt)
+	case jobs.IsPermanent(err) || job.Attempt >= job.MaxAttempts:
+		result = metrics.ResultDead
+		logger.Error().Err(err).Msg("Job failed permanently")
+		storeErr = w.store.Bury(ctx, job.ID, job.Attempt, err.Error())
+	default:
+		result = metrics.ResultRetry
+		delay := backoff(job.Attempt)
+		logger.Warn().Err(err).Dur("retry_in", delay).Msg("Job failed, will retry")
+		storeErr = w.store.Retry(ctx, job.ID, job.Attempt, delay, err.Error())
+	}
+	tracing.RecordError(span, err)
+	metrics.JobsProcessed.WithLabelValues(job.Queue, job.Kind, result).Inc()
+
+	switch {
+	case errors.Is(storeErr, jobs.ErrLeaseLost):
+		// The job ran past its lease and belongs to another attempt now
+		logger.Warn().Str("result", result).Msg("Job lease lost, result discarded")
+	case storeErr != nil:
+		// The job is claimed again when its lease runs out
+		logger.Error().Err(storeErr).Str("result", result).Msg("Failed to record job result")
+	}
+}
+
+// run calls the job's handler within the lease, turning a panic into an error
+func (w *Worker) run(ctx context.Context, job jobs.Job) (err error) {
+	// A worker of an older release may not know a newer kind; another one will
+	h, ok := w.handlers[job.Kind]
+	if !ok {
+		return fmt.Errorf("no handler for job kind %q", job.Kind)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, w.lease)
+	defer cancel()
+
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("job panicked: %v", p)
+		}
+	}()
+	return h(ctx, job.Payload)
+}
+
+// backoff doubles the delay per attempt with jitter, up to retryMaxDelay
+func backoff(attempt int) time.Duration {
+	delay := retryMaxDelay
+	if attempt < 10 {
+		delay = min(retryBaseDelay<<(max(attempt, 1)-1), retryMaxDelay)
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
@@ -0,0 +1,215 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+
+	jobs "github.com/infosec554/clean-archtectura/domain/jobs"
+)
+
+// fakeStore hands out the batches in claims and records what happened to
+// each job
+type fakeStore struct {
+	mu        sync.Mutex
+	claims    [][]jobs.Job
+	results   []result
+	leaseLost bool
+	recorded  chan struct{}
+}
+
+type result struct {
+	action  string
+	id      uuid.UUID
+	attempt int
+	delay   time.Duration
+	errMsg  string
+}
+
+func newFakeStore(claims ...[]jobs.Job) *fakeStore {
+	return &fakeStore{claims: claims, recorded: make(chan struct{}, 100)}
+}
+
+func (s *fakeStore) Claim(_ context.Context, _ string, limit int, _ time.Duration) ([]jobs.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.claims) == 0 {
+		return nil, nil
+	}
+	batch := s.claims[0]
+	if len(batch) > limit {
+		batch, s.claims[0] = batch[:limit], batch[limit:]
+	} else {
+		s.claims = s.claims[1:]
+	}
+	return batch, nil
+}
+
+func (s *fakeStore) record(r result) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results = append(s.results, r)
+	s.recorded <- struct{}{}
+	if s.leaseLost {
+		return jobs.ErrLeaseLost
+	}
+	return nil
+}
+
+func (s *fakeStore) Complete(_ context.Context, id uuid.UUID, attempt int) error {
+	return s.record(result{action: "complete", id: id, attempt: attempt})
+}
+
+func (s *fakeStore) Retry(_ context.Context, id uuid.UUID, attempt int, delay time.Duration, errMsg string) error {
+	return s.record(result{action: "retry", id: id, attempt: attempt, delay: delay, errMsg: errMsg})
+}
+
+func (s *fakeStore) Bury(_ context.Context, id uuid.UUID, attempt int, errMsg string) error {
+	return s.record(result{action: "bury", id: id, attempt: attempt, errMsg: errMsg})
+}
+
+type testPayload struct {
+	Outcome string `json:"outcome"`
+}
+
+var testKind = jobs.Kind[testPayload]{Name: "test.job", MaxAttempts: 3}
+
+func newTestWorker(store Store) *Worker {
+	w := New(store, map[string]int{jobs.DefaultQueue: 2}, 10*time.Millisecond, time.Second, zerolog.Nop())
+	Handle(w, testKind, func(ctx context.Context, p testPayload) error {
+		switch p.Outcome {
+		case "fail":
+			return errors.New("smtp unavailable")
+		case "permanent":
+			return jobs.Permanent(errors.New("address rejected"))
+		case "panic":
+			panic("boom")
+		}
+		return nil
+	})
+	return w
+}
+
+func testJob(kind, payload string, attempt int) jobs.Job {
+	return jobs.Job{
+		ID:          uuid.New(),
+		Queue:       jobs.DefaultQueue,
+		Kind:        kind,
+		Payload:     []byte(payload),
+		Status:      jobs.StatusRunning,
+		Attempt:     attempt,
+		MaxAttempts: testKind.MaxAttempts,
+	}
+}
+
+func TestProcess(t *testing.T) {
+	tests := []struct {
+		name    string
+		kind    string
+		payload string
+		attempt int
+		want    string
+	}{
+		{"success", testKind.Name, `{"outcome":"ok"}`, 1, "complete"},
+		{"failure is retried", testKind.Name, `{"outcome":"fail"}`, 1, "retry"},
+		{"failure on the last attempt is buried", testKind.Name, `{"outcome":"fail"}`, 3, "bury"},
+		{"permanent failure is buried at once", testKind.Name, `{"outcome":"permanent"}`, 1, "bury"},
+		{"panic is retried", testKind.Name, `{"outcome":"panic"}`, 1, "retry"},
+		{"undecodable payload is buried", testKind.Name, `not json`, 1, "bury"},
+		{"unknown kind is left for another worker", "other.job", `{}`, 1, "retry"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newFakeStore()
+			job := testJob(tt.kind, tt.payload, tt.attempt)
+
+			newTestWorker(store).process(context.Background(), job)
+
+			if len(store.results) != 1 {
+				t.Fatalf("results = %+v, want one", store.results)
+			}
+			got := store.results[0]
+			if got.action != tt.want || got.id != job.ID || got.attempt != tt.attempt {
+				t.Fatalf("result = %+v, want %s of attempt %d", got, tt.want, tt.attempt)
+			}
+			if got.action != "complete" && got.errMsg == "" {
+				t.Fatal("failure recorded without its error")
+			}
+			if got.action == "retry" && (got.delay < retryBaseDelay/2 || got.delay > retryBaseDelay) {
+				t.Fatalf("retry delay = %v, want the first backoff step", got.delay)
+			}
+		})
+	}
+}
+
+func TestProcessLeaseLost(t *testing.T) {
+	store := newFakeStore()
+	store.leaseLost = true
+
+	// The result is dropped without retrying the bookkeeping
+	newTestWorker(store).process(context.Background(), testJob(testKind.Name, `{}`, 1))
+	if len(store.results) != 1 || store.results[0].action != "complete" {
+		t.Fatalf("results = %+v, want one complete", store.results)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempt  int
+		min, max time.Duration
+	}{
+		{0, 5 * time.Second, 10 * time.Second},
+		{1, 5 * time.Second, 10 * time.Second},
+		{2, 10 * time.Second, 20 * time.Second},
+		{4, 40 * time.Second, 80 * time.Second},
+		{9, 1280 * time.Second, 2560 * time.Second},
+		{10, retryMaxDelay / 2, retryMaxDelay},
+		{50, retryMaxDelay / 2, retryMaxDelay},
+	}
+	for _, tt := range tests {
+		for range 100 {
+			if d := backoff(tt.attempt); d < tt.min || d > tt.max {
+				t.Fatalf("backoff(%d) = %v, want within [%v, %v]", tt.attempt, d, tt.min, tt.max)
+			}
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	var batch []jobs.Job
+	for i := range 5 {
+		batch = append(batch, testJob(testKind.Name, fmt.Sprintf(`{"outcome":"ok","n":%d}`, i), 1))
+	}
+	store := newFakeStore(batch)
+	w := newTestWorker(store)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- w.Run(ctx) }()
+
+	for range batch {
+		select {
+		case <-store.recorded:
+		case <-time.After(5 * time.Second):
+			t.Fatal("jobs were not processed")
+		}
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	for _, r := range store.results {
+		if r.action != "complete" {
+			t.Fatalf("result = %+v, want complete", r)
+		}
+	}
+}
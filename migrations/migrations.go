@@ -0,0 +1,49 @@
+// Package migrations embeds the SQL migrations so the binary can apply them
+// regardless of its working directory.
+package migrations
+
+import (
+	"embed"
+	"io/fs"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+//go:embed postgres/*.sql
+var files embed.FS
+
+// Postgres holds the NNNN_name.up.sql / NNNN_name.down.sql pairs for PostgreSQL
+var Postgres, _ = fs.Sub(files, "postgres")
+
+// Latest returns the highest version among the *.up.sql files in fsys
+func Latest(fsys fs.FS) (uint, error) {
+	versions, err := Versions(fsys)
+	if err != nil || len(versions) == 0 {
+		return 0, err
+	}
+	return versions[len(versions)-1], nil
+}
+
+// Versions returns the versions of the *.up.sql files in fsys in ascending order
+func Versions(fsys fs.FS) ([]uint, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []uint
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".up.sql") {
+			continue
+		}
+		prefix, _, _ := strings.Cut(e.Name(), "_")
+		v, err := strconv.ParseUint(prefix, 10, 64)
+		if err != nil {
+			continue
+		}
+		versions = append(versions, uint(v))
+	}
+	slices.Sort(versions)
+	return versions, nil
+}
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_data_exports_status_expires_at;
+DROP INDEX IF EXISTS idx_data_exports_user_id;
+DROP TABLE IF EXISTS data_exports;
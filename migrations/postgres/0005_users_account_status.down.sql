@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS idx_users_status;
+
+ALTER TABLE users
+    DROP CONSTRAINT IF EXISTS users_status_check;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS suspended_until,
+    DROP COLUMN IF EXISTS status_changed_at,
+    DROP COLUMN IF EXISTS status_changed_by,
+    DROP COLUMN IF EXISTS status_reason,
+    DROP COLUMN IF EXISTS status;
@@ -0,0 +1,11 @@
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS status VARCHAR NOT NULL DEFAULT 'active',
+    ADD COLUMN IF NOT EXISTS status_reason TEXT,
+    ADD COLUMN IF NOT EXISTS status_changed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS status_changed_at TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS suspended_until TIMESTAMP;
+
+ALTER TABLE users
+    ADD CONSTRAINT users_status_check CHECK (status IN ('active', 'suspended', 'banned', 'locked'));
+
+CREATE INDEX IF NOT EXISTS idx_users_status ON users(status);
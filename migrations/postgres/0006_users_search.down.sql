@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_users_email_trgm;
+DROP INDEX IF EXISTS idx_users_last_name_trgm;
+DROP INDEX IF EXISTS idx_users_first_name_trgm;
+DROP INDEX IF EXISTS idx_users_search_vector;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS search_vector;
+
+DROP EXTENSION IF EXISTS pg_trgm;
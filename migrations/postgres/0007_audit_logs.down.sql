@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_audit_logs_subject_id;
+DROP INDEX IF EXISTS idx_audit_logs_actor_id;
+DROP TABLE IF EXISTS audit_logs;
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS audit_logs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    actor_id UUID NOT NULL,
+    subject_id UUID,
+    action VARCHAR NOT NULL,
+    reason TEXT,
+    method VARCHAR,
+    path VARCHAR,
+    status_code INT,
+    ip VARCHAR,
+    user_agent VARCHAR,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_logs_actor_id ON audit_logs(actor_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_subject_id ON audit_logs(subject_id, created_at);
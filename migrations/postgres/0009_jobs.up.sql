@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS jobs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    queue VARCHAR NOT NULL,
+    kind VARCHAR NOT NULL,
+    payload JSONB NOT NULL DEFAULT '{}',
+    status VARCHAR NOT NULL DEFAULT 'available' CHECK (status IN ('available', 'running', 'dead')),
+    attempt INT NOT NULL DEFAULT 0,
+    max_attempts INT NOT NULL,
+    unique_key VARCHAR,
+    run_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    locked_until TIMESTAMP,
+    last_error TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Workers claim the due jobs of a queue in run_at order
+CREATE INDEX IF NOT EXISTS idx_jobs_due ON jobs(queue, run_at) WHERE status = 'available';
+-- Running jobs whose lease expired are claimed again
+CREATE INDEX IF NOT EXISTS idx_jobs_lease ON jobs(queue, locked_until) WHERE status = 'running';
+-- One pending job per unique key; dead jobs do not block a new one
+CREATE UNIQUE INDEX IF NOT EXISTS idx_jobs_unique_key ON jobs(unique_key) WHERE unique_key IS NOT NULL AND status <> 'dead';
@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS outbox (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    topic VARCHAR NOT NULL,
+    payload JSONB NOT NULL DEFAULT '{}',
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    available_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    delivered_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- The relay reads undelivered entries in the order they were written
+CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox(created_at) WHERE delivered_at IS NULL;
+-- Delivered entries are purged once they are older than the retention
+CREATE INDEX IF NOT EXISTS idx_outbox_delivered ON outbox(delivered_at) WHERE delivered_at IS NOT NULL;
+
+-- Wakes the relay when a transaction that wrote entries commits; the
+-- notification carries no payload, the relay reads the table
+CREATE OR REPLACE FUNCTION outbox_notify() RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM pg_notify('outbox', '');
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS outbox_notify ON outbox;
+CREATE TRIGGER outbox_notify AFTER INSERT ON outbox
+    FOR EACH STATEMENT EXECUTE FUNCTION outbox_notify();
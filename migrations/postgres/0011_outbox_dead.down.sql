@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_outbox_pending;
+CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox(created_at) WHERE delivered_at IS NULL;
+
+ALTER TABLE outbox DROP COLUMN IF EXISTS dead_at;
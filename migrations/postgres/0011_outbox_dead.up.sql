@@ -0,0 +1,6 @@
+-- Events whose delivery failed OUTBOX_MAX_ATTEMPTS times are set aside
+-- for inspection instead of being retried forever
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS dead_at TIMESTAMP;
+
+DROP INDEX IF EXISTS idx_outbox_pending;
+CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox(created_at) WHERE delivered_at IS NULL AND dead_at IS NULL;
@@ -0,0 +1,14 @@
+-- Settle duplicates left by concurrent requests: only the newest active
+-- export of a user is kept
+UPDATE data_exports e
+SET status = 'failed', error = 'superseded by a newer export', updated_at = NOW()
+WHERE status IN ('pending', 'processing')
+  AND EXISTS (
+      SELECT 1 FROM data_exports n
+      WHERE n.user_id = e.user_id
+        AND n.status IN ('pending', 'processing')
+        AND (n.created_at, n.id) > (e.created_at, e.id)
+  );
+
+-- A user has at most one export in progress
+CREATE UNIQUE INDEX IF NOT EXISTS idx_data_exports_active ON data_exports(user_id) WHERE status IN ('pending', 'processing');
@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/infosec554/clean-archtectura/config"
+	"github.com/infosec554/clean-archtectura/pkg/health"
+)
+
+// ErrNotFound is returned for keys that do not exist or have expired
+var ErrNotFound = errors.New("cache: key not found")
+
+// NoExpiry is the TTL of a key that never expires
+const NoExpiry time.Duration = -1
+
+// ICache is a string key-value store with expiry. A zero ttl means the key
+// does not expire. Both backends have Redis semantics, so code tested against
+// the in-memory one behaves the same in production.
+type ICache interface {
+	Get(ctx context.Context, key string) (string, error)
+	Set(ctx context.Context, key string, value any, ttl time.Duration) error
+	// SetNX sets the key only if it does not exist and reports whether it did
+	SetNX(ctx context.Context, key string, value any, ttl time.Duration) (bool, error)
+	Delete(ctx context.Context, keys ...string) error
+	// Incr atomically adds one to an integer value, starting from zero; the
+	// expiry of an existing key is kept
+	Incr(ctx context.Context, key string) (int64, error)
+	// Expire sets a new ttl and reports whether the key exists
+	Expire(ctx context.Context, key string, ttl time.Duration) (bool, error)
+	// TTL returns the remaining time to live, or NoExpiry
+	TTL(ctx context.Context, key string) (time.Duration, error)
+	Close() error
+	HealthChecks() []health.Check
+}
+
+// New connects the backend selected by CACHE_BACKEND
+func New(ctx context.Context, cfg config.Config) (ICache, error) {
+	switch cfg.CacheBackend {
+	case "memory":
+		return NewMemory(), nil
+	case "redis":
+		return NewRedis(ctx, cfg)
+	}
+	return nil, fmt.Errorf("cache: unknown backend %q", cfg.CacheBackend)
+}
+
+// GetJSON decodes the JSON value stored at key
+func GetJSON[T any](ctx context.Context, c ICache, key string) (T, error) {
+	var v T
+	raw, err := c.Get(ctx, key)
+	if err != nil {
+		return v, err
+	}
+	if err := json.Unmarshal([]byte(raw), &v); err != nil {
+		return v, fmt.Errorf("cache: decode %s: %w", key, err)
+	}
+	return v, nil
+}
+
+// SetJSON stores value at key as JSON
+func SetJSON(ctx context.Context, c ICache, key string, value any, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("cache: encode %s: %w", key, err)
+	}
+	return c.Set(ctx, key, data, ttl)
+}
@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// LRU is a size-bounded in-process cache whose entries also expire after a
+// fixed ttl. It is safe for concurrent use.
+type LRU[K comparable, V any] struct {
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	order *list.List // front is the most recently used
+	items map[K]*list.Element
+	now   func() time.Time
+}
+
+type lruItem[K comparable, V any] struct {
+	key     K
+	value   V
+	expires time.Time
+}
+
+func NewLRU[K comparable, V any](size int, ttl time.Duration) *LRU[K, V] {
+	return &LRU[K, V]{
+		size:  size,
+		ttl:   ttl,
+		order: list.New(),
+		items: make(map[K]*list.Element, size),
+		now:   time.Now,
+	}
+}
+
+func (l *LRU[K, V]) Get(key K) (V, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var zero V
+	el, ok := l.items[key]
+	if !ok {
+		return zero, false
+	}
+	item := el.Value.(*lruItem[K, V])
+	if !item.expires.After(l.now()) {
+		l.remove(el)
+		return zero, false
+	}
+	l.order.MoveToFront(el)
+	return item.value, true
+}
+
+// Set stores value, evicting the least recently used entry when full
+func (l *LRU[K, V]) Set(key K, value V) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	expires := l.now().Add(l.ttl)
+	if el, ok := l.items[key]; ok {
+		item := el.Value.(*lruItem[K, V])
+		item.value, item.expires = value, expires
+		l.order.MoveToFront(el)
+		return
+	}
+
+	l.items[key] = l.order.PushFront(&lruItem[K, V]{key: key, value: value, expires: expires})
+	if l.order.Len() > l.size {
+		l.remove(l.order.Back())
+	}
+}
+
+func (l *LRU[K, V]) Delete(keys ...K) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, key := range keys {
+		if el, ok := l.items[key]; ok {
+			l.remove(el)
+		}
+	}
+}
+
+func (l *LRU[K, V]) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.order.Len()
+}
+
+func (l *LRU[K, V]) remove(el *list.Element) {
+	l.order.Remove(el)
+	delete(l.items, el.Value.(*lruItem[K, V]).key)
+}
@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRUEviction(t *testing.T) {
+	l := NewLRU[string, int](2, time.Minute)
+	l.Set("a", 1)
+	l.Set("b", 2)
+	if _, ok := l.Get("a"); !ok {
+		t.Fatal("a missing before eviction")
+	}
+
+	// b is now the least recently used
+	l.Set("c", 3)
+	if _, ok := l.Get("b"); ok {
+		t.Fatal("b survived eviction")
+	}
+	for key, want := range map[string]int{"a": 1, "c": 3} {
+		if v, ok := l.Get(key); !ok || v != want {
+			t.Fatalf("Get(%s) = %d, %v, want %d", key, v, ok, want)
+		}
+	}
+
+	l.Set("a", 10)
+	if v, _ := l.Get("a"); v != 10 {
+		t.Fatalf("Get(a) after update = %d, want 10", v)
+	}
+	if n := l.Len(); n != 2 {
+		t.Fatalf("Len = %d, want 2", n)
+	}
+
+	l.Delete("a", "missing")
+	if _, ok := l.Get("a"); ok {
+		t.Fatal("a survived Delete")
+	}
+	if n := l.Len(); n != 1 {
+		t.Fatalf("Len after Delete = %d, want 1", n)
+	}
+}
+
+func TestLRUExpiry(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := NewLRU[string, int](10, time.Minute)
+	l.now = func() time.Time { return now }
+
+	l.Set("a", 1)
+	now = now.Add(30 * time.Second)
+	l.Set("b", 2)
+
+	now = now.Add(30 * time.Second)
+	if _, ok := l.Get("a"); ok {
+		t.Fatal("a served after its ttl")
+	}
+	if _, ok := l.Get("b"); !ok {
+		t.Fatal("b expired early")
+	}
+	if n := l.Len(); n != 1 {
+		t.Fatalf("Len = %d, want the expired entry dropped", n)
+	}
+
+	// Set restarts the ttl
+	l.Set("b", 3)
+	now = now.Add(59 * time.Second)
+	if v, ok := l.Get("b"); !ok || v != 3 {
+		t.Fatalf("Get(b) = %d, %v, want 3", v, ok)
+	}
+}
@@ -0,0 +1,210 @@
+package cache
+
+import (
+	"context"
+	"encoding"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/infosec554/clean-archtectura/pkg/health"
+)
+
+// sweepEvery is how many writes pass between scans for expired keys;
+// reads drop an expired key on their own
+const sweepEvery = 1024
+
+type entry struct {
+	value   string
+	expires time.Time // zero means no expiry
+}
+
+func (e entry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !e.expires.After(now)
+}
+
+// memoryCache keeps values in process memory. It is meant for local
+// development and tests; values are not shared between replicas.
+type memoryCache struct {
+	mu      sync.Mutex
+	entries map[string]entry
+	writes  int
+	now     func() time.Time
+}
+
+func NewMemory() ICache {
+	return &memoryCache{entries: make(map[string]entry), now: time.Now}
+}
+
+func (c *memoryCache) Get(_ context.Context, key string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.lookup(key)
+	if !ok {
+		return "", ErrNotFound
+	}
+	return e.value, nil
+}
+
+func (c *memoryCache) Set(_ context.Context, key string, value any, ttl time.Duration) error {
+	str, err := format(value)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.store(key, entry{value: str, expires: c.deadline(ttl)})
+	return nil
+}
+
+func (c *memoryCache) SetNX(_ context.Context, key string, value any, ttl time.Duration) (bool, error) {
+	str, err := format(value)
+	if err != nil {
+		return false, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.lookup(key); ok {
+		return false, nil
+	}
+	c.store(key, entry{value: str, expires: c.deadline(ttl)})
+	return true, nil
+}
+
+func (c *memoryCache) Delete(_ context.Context, keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range keys {
+		delete(c.entries, key)
+	}
+	return nil
+}
+
+func (c *memoryCache) Incr(_ context.Context, key string) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.lookup(key)
+	var n int64
+	if ok {
+		var err error
+		if n, err = strconv.ParseInt(e.value, 10, 64); err != nil {
+			return 0, fmt.Errorf("cache: value of %s is not an integer", key)
+		}
+	}
+	n++
+	e.value = strconv.FormatInt(n, 10)
+	c.store(key, e)
+	return n, nil
+}
+
+func (c *memoryCache) Expire(_ context.Context, key string, ttl time.Duration) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.lookup(key)
+	if !ok {
+		return false, nil
+	}
+	e.expires = c.deadline(ttl)
+	c.entries[key] = e
+	return true, nil
+}
+
+func (c *memoryCache) TTL(_ context.Context, key string) (time.Duration, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.lookup(key)
+	if !ok {
+		return 0, ErrNotFound
+	}
+	if e.expires.IsZero() {
+		return NoExpiry, nil
+	}
+	return e.expires.Sub(c.now()), nil
+}
+
+// HealthChecks reports nothing: there is no external dependency
+func (c *memoryCache) HealthChecks() []health.Check {
+	return nil
+}
+
+func (c *memoryCache) Close() error {
+	return nil
+}
+
+// lookup returns a live entry, dropping it if it has expired
+func (c *memoryCache) lookup(key string) (entry, bool) {
+	e, ok := c.entries[key]
+	if ok && e.expired(c.now()) {
+		delete(c.entries, key)
+		return entry{}, false
+	}
+	return e, ok
+}
+
+func (c *memoryCache) store(key string, e entry) {
+	c.entries[key] = e
+
+	c.writes++
+	if c.writes%sweepEvery == 0 {
+		now := c.now()
+		for k, e := range c.entries {
+			if e.expired(now) {
+				delete(c.entries, k)
+			}
+		}
+	}
+}
+
+func (c *memoryCache) deadline(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return c.now().Add(ttl)
+}
+
+// format converts a value the way go-redis writes command arguments
+func format(value any) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case int32:
+		return strconv.FormatInt(int64(v), 10), nil
+	case uint:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		if v {
+			return "1", nil
+		}
+		return "0", nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case time.Duration:
+		return strconv.FormatInt(v.Nanoseconds(), 10), nil
+	case encoding.BinaryMarshaler:
+		b, err := v.MarshalBinary()
+		return string(b), err
+	}
+	return "", fmt.Errorf("cache: unsupported value type %T", value)
+}
@@ -0,0 +1,166 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// newTestMemory returns a memory cache whose clock only moves when the
+// returned function is called
+func newTestMemory() (*memoryCache, func(time.Duration)) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewMemory().(*memoryCache)
+	c.now = func() time.Time { return now }
+	return c, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestMemoryGetSet(t *testing.T) {
+	ctx := context.Background()
+	c, advance := newTestMemory()
+
+	if _, err := c.Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get(missing) error = %v, want ErrNotFound", err)
+	}
+	if _, err := c.TTL(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("TTL(missing) error = %v, want ErrNotFound", err)
+	}
+
+	if err := c.Set(ctx, "k", 42, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set(ctx, "forever", true, 0); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := c.Get(ctx, "k"); err != nil || v != "42" {
+		t.Fatalf("Get(k) = %q, %v, want 42", v, err)
+	}
+	if ttl, _ := c.TTL(ctx, "forever"); ttl != NoExpiry {
+		t.Fatalf("TTL(forever) = %v, want NoExpiry", ttl)
+	}
+
+	advance(59 * time.Second)
+	if ttl, _ := c.TTL(ctx, "k"); ttl != time.Second {
+		t.Fatalf("TTL(k) = %v, want 1s", ttl)
+	}
+
+	advance(time.Second)
+	if _, err := c.Get(ctx, "k"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get(k) after expiry error = %v, want ErrNotFound", err)
+	}
+	if v, err := c.Get(ctx, "forever"); err != nil || v != "1" {
+		t.Fatalf("Get(forever) = %q, %v, want 1", v, err)
+	}
+
+	if err := c.Delete(ctx, "forever", "missing"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get(ctx, "forever"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get(forever) after Delete error = %v, want ErrNotFound", err)
+	}
+
+	if err := c.Set(ctx, "bad", struct{}{}, 0); err == nil {
+		t.Fatal("Set with an unsupported type succeeded")
+	}
+}
+
+func TestMemorySetNX(t *testing.T) {
+	ctx := context.Background()
+	c, advance := newTestMemory()
+
+	if ok, err := c.SetNX(ctx, "lock", "a", time.Minute); err != nil || !ok {
+		t.Fatalf("first SetNX = %v, %v, want true", ok, err)
+	}
+	if ok, err := c.SetNX(ctx, "lock", "b", time.Minute); err != nil || ok {
+		t.Fatalf("second SetNX = %v, %v, want false", ok, err)
+	}
+	if v, _ := c.Get(ctx, "lock"); v != "a" {
+		t.Fatalf("Get(lock) = %q, want the first value", v)
+	}
+
+	advance(time.Minute)
+	if ok, err := c.SetNX(ctx, "lock", "c", time.Minute); err != nil || !ok {
+		t.Fatalf("SetNX after expiry = %v, %v, want true", ok, err)
+	}
+	if v, _ := c.Get(ctx, "lock"); v != "c" {
+		t.Fatalf("Get(lock) = %q, want c", v)
+	}
+}
+
+func TestMemoryIncrExpire(t *testing.T) {
+	ctx := context.Background()
+	c, advance := newTestMemory()
+
+	for want := int64(1); want <= 3; want++ {
+		n, err := c.Incr(ctx, "hits")
+		if err != nil || n != want {
+			t.Fatalf("Incr = %d, %v, want %d", n, err, want)
+		}
+	}
+	if ttl, _ := c.TTL(ctx, "hits"); ttl != NoExpiry {
+		t.Fatalf("TTL of a new counter = %v, want NoExpiry", ttl)
+	}
+
+	if ok, err := c.Expire(ctx, "hits", time.Minute); err != nil || !ok {
+		t.Fatalf("Expire(hits) = %v, %v, want true", ok, err)
+	}
+	if ok, err := c.Expire(ctx, "missing", time.Minute); err != nil || ok {
+		t.Fatalf("Expire(missing) = %v, %v, want false", ok, err)
+	}
+
+	advance(30 * time.Second)
+	if n, _ := c.Incr(ctx, "hits"); n != 4 {
+		t.Fatalf("Incr = %d, want 4", n)
+	}
+	if ttl, _ := c.TTL(ctx, "hits"); ttl != 30*time.Second {
+		t.Fatalf("Incr changed the ttl to %v, want 30s kept", ttl)
+	}
+
+	advance(30 * time.Second)
+	if n, _ := c.Incr(ctx, "hits"); n != 1 {
+		t.Fatalf("Incr after expiry = %d, want a fresh counter", n)
+	}
+
+	if err := c.Set(ctx, "name", "abc", 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Incr(ctx, "name"); err == nil {
+		t.Fatal("Incr of a non-integer value succeeded")
+	}
+}
+
+func TestMemorySweep(t *testing.T) {
+	ctx := context.Background()
+	c, advance := newTestMemory()
+
+	if err := c.Set(ctx, "stale", "x", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	advance(time.Second)
+	for i := 0; i < sweepEvery; i++ {
+		if err := c.Set(ctx, "fresh", i, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, ok := c.entries["stale"]; ok {
+		t.Fatal("expired key survived a sweep")
+	}
+}
+
+func TestJSON(t *testing.T) {
+	ctx := context.Background()
+	c, _ := newTestMemory()
+
+	type item struct{ Name string }
+	if err := SetJSON(ctx, c, "item", item{Name: "a"}, 0); err != nil {
+		t.Fatal(err)
+	}
+	got, err := GetJSON[item](ctx, c, "item")
+	if err != nil || got.Name != "a" {
+		t.Fatalf("GetJSON = %+v, %v", got, err)
+	}
+	if _, err := GetJSON[item](ctx, c, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetJSON(missing) error = %v, want ErrNotFound", err)
+	}
+}
@@ -2,73 +2,108 @@ package cache
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"sync"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 
 	"github.com/infosec554/clean-archtectura/config"
+	"github.com/infosec554/clean-archtectura/pkg/health"
 )
 
-var (
-	once   sync.Once
+type redisCache struct {
 	client *redis.Client
-)
+}
+
+// NewRedis connects to Redis and fails if it cannot be reached
+func NewRedis(ctx context.Context, cfg config.Config) (ICache, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     fmt.Sprintf("%s:%s", cfg.RedisHost, cfg.RedisPort),
+		Password: cfg.RedisPassword,
+		DB:       cfg.RedisDB,
+	})
+
+	client.AddHook(metricsHook{})
+	client.AddHook(tracingHook{})
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("redis ping error: %w", err)
+	}
+	return &redisCache{client: client}, nil
+}
 
-type ICache interface {
-	Set(key string, value any, duration time.Duration) error
-	Get(key string) (string, error)
-	Scan(key string, val any) error
+func (c *redisCache) Get(ctx context.Context, key string) (string, error) {
+	res, err := c.client.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", ErrNotFound
+	}
+	return res, err
 }
 
-type cache struct {
-	client *redis.Client
+func (c *redisCache) Set(ctx context.Context, key string, value any, ttl time.Duration) error {
+	return c.client.Set(ctx, key, value, ttl).Err()
 }
 
-func (c cache) Set(key string, value any, duration time.Duration) error {
-	res := c.client.Set(
-		context.Background(),
-		key,
-		value,
-		duration,
-	)
+func (c *redisCache) SetNX(ctx context.Context, key string, value any, ttl time.Duration) (bool, error) {
+	return c.client.SetNX(ctx, key, value, ttl).Result()
+}
 
-	if res.Err() != nil {
-		return res.Err()
+func (c *redisCache) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
 	}
-	return nil
+	return c.client.Del(ctx, keys...).Err()
 }
 
-func (c cache) Get(key string) (string, error) {
-	res, err := c.client.Get(context.Background(), key).Result()
-	if err != nil {
-		return "", err
+func (c *redisCache) Incr(ctx context.Context, key string) (int64, error) {
+	return c.client.Incr(ctx, key).Result()
+}
+
+func (c *redisCache) Expire(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	if ttl <= 0 {
+		return c.client.Persist(ctx, key).Result()
 	}
-	return res, nil
+	return c.client.Expire(ctx, key, ttl).Result()
 }
 
-func (c cache) Scan(key string, val any) error {
-	err := c.client.Get(context.Background(), key).Scan(val)
+func (c *redisCache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := c.client.PTTL(ctx, key).Result()
 	if err != nil {
-		return err
+		return 0, err
 	}
-	return nil
+	// go-redis reports Redis' -2 (missing) and -1 (no expiry) as raw durations
+	switch ttl {
+	case -2:
+		return 0, ErrNotFound
+	case -1:
+		return NoExpiry, nil
+	}
+	return ttl, nil
 }
 
-func NewCache(cfg config.Config) ICache {
-	once.Do(func() {
-		client = redis.NewClient(&redis.Options{
-			Addr:     fmt.Sprintf("%s:%s", cfg.RedisHost, cfg.RedisPort),
-			Password: "", // no password set
-			DB:       0,  // use default DB
-		})
-
-		res := client.Ping(context.Background())
-		if res.Err() != nil {
-			panic(res.Err())
-		}
-	})
+// HealthChecks pings Redis
+func (c *redisCache) HealthChecks() []health.Check {
+	return []health.Check{{
+		Name:     "redis",
+		Critical: true,
+		Fn: func(ctx context.Context) error {
+			return c.client.Ping(ctx).Err()
+		},
+	}}
+}
+
+// Close releases the Redis connection pool
+func (c *redisCache) Close() error {
+	return c.client.Close()
+}
 
-	return &cache{client: client}
+// RedisClient returns the client behind a Redis cache, or nil for other
+// implementations. It is meant for components that need Lua scripts.
+func RedisClient(c ICache) *redis.Client {
+	if rc, ok := c.(*redisCache); ok {
+		return rc.client
+	}
+	return nil
 }
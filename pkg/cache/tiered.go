@@ -0,0 +1,200 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"github.com/infosec554/clean-archtectura/pkg/metrics"
+)
+
+// notFound is stored for lookups whose source had no record, so repeated
+// requests for a missing key do not reach the source either
+const notFound = "\x00not-found"
+
+// versionSep ends the version tag in front of a shared entry
+const versionSep = "\n"
+
+// Tiered is a read-through cache with an in-process LRU in front of the
+// shared cache. Concurrent misses for a key are collapsed into one load.
+//
+// Invalidation deletes the key from both tiers and is broadcast over Redis
+// pub/sub so other replicas drop their local copy. Pub/sub is best effort:
+// a replica that misses a message serves its local copy until localTTL.
+//
+// Shared entries are tagged with the key's version, which every
+// invalidation replaces. A load that overlapped an invalidation on any
+// replica may have read old data; its entry carries the old version and is
+// never served.
+type Tiered struct {
+	name   string
+	local  *LRU[string, string]
+	remote ICache
+	maxTTL time.Duration
+	group  singleflight.Group
+
+	// epoch changes on every invalidation seen by this replica; a load that
+	// overlapped one is not stored in the local tier
+	epoch atomic.Uint64
+}
+
+// NewTiered returns a cache named for metrics and pub/sub. maxTTL bounds how
+// long a shared entry lives, and so how long a version must be kept.
+func NewTiered(name string, remote ICache, size int, localTTL, maxTTL time.Duration) *Tiered {
+	return &Tiered{
+		name:   name,
+		local:  NewLRU[string, string](size, localTTL),
+		remote: remote,
+		maxTTL: maxTTL,
+	}
+}
+
+// Fetch returns the cached value for key or calls load. load returns
+// ErrNotFound when the source has no record; that result is cached for
+// negativeTTL and reported as ErrNotFound. A caller whose ctx ends stops
+// waiting, but the load carries on for the others.
+func (t *Tiered) Fetch(ctx context.Context, key string, ttl, negativeTTL time.Duration, load func(context.Context) (string, error)) (string, error) {
+	if v, ok := t.local.Get(key); ok {
+		metrics.CacheHits.WithLabelValues(t.name, metrics.TierLocal).Inc()
+		return decode(v)
+	}
+
+	epoch := t.epoch.Load()
+	// A failing shared cache degrades to loading from the source, and the
+	// result is kept out of it
+	version, verr := t.version(ctx, key)
+	if verr == nil {
+		if v, ok := t.remoteGet(ctx, key, version); ok {
+			metrics.CacheHits.WithLabelValues(t.name, metrics.TierRemote).Inc()
+			if t.epoch.Load() == epoch {
+				t.local.Set(key, v)
+			}
+			return decode(v)
+		}
+	}
+
+	ch := t.group.DoChan(key, func() (any, error) {
+		metrics.CacheMisses.WithLabelValues(t.name).Inc()
+
+		// Callers sharing this load must not fail because the first one went away
+		loadCtx := context.WithoutCancel(ctx)
+
+		v, err := load(loadCtx)
+		expiry := ttl
+		switch {
+		case errors.Is(err, ErrNotFound):
+			v, expiry = notFound, negativeTTL
+		case err != nil:
+			return "", err
+		}
+
+		if verr == nil {
+			if expiry <= 0 || expiry > t.maxTTL {
+				expiry = t.maxTTL
+			}
+			_ = t.remote.Set(loadCtx, key, version+versionSep+v, expiry)
+		}
+		if t.epoch.Load() == epoch {
+			t.local.Set(key, v)
+		}
+		return v, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return "", res.Err
+		}
+		return decode(res.Val.(string))
+	}
+}
+
+// Invalidate removes keys from every tier on every replica
+func (t *Tiered) Invalidate(ctx context.Context, keys ...string) error {
+	t.local.Delete(keys...)
+	t.epoch.Add(1)
+
+	// A new version is what keeps loads already in flight from storing old
+	// data; it outlives every entry tagged with the previous one
+	var err error
+	for _, key := range keys {
+		err = errors.Join(err, t.remote.Set(ctx, versionKey(key), rand.Text(), t.maxTTL))
+	}
+	err = errors.Join(err, t.remote.Delete(ctx, keys...))
+	if client := RedisClient(t.remote); client != nil {
+		err = errors.Join(err, client.Publish(ctx, t.channel(), strings.Join(keys, "\n")).Err())
+	}
+	return err
+}
+
+// Listen applies invalidations published by other replicas until ctx is done.
+// Without Redis there are no other replicas to hear from.
+func (t *Tiered) Listen(ctx context.Context) error {
+	client := RedisClient(t.remote)
+	if client == nil {
+		<-ctx.Done()
+		return nil
+	}
+
+	sub := client.Subscribe(ctx, t.channel())
+	defer sub.Close()
+
+	messages := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-messages:
+			if !ok {
+				return nil
+			}
+			t.local.Delete(strings.Split(msg.Payload, "\n")...)
+			t.epoch.Add(1)
+		}
+	}
+}
+
+func (t *Tiered) channel() string {
+	return "cache:invalidate:" + t.name
+}
+
+// version returns the current version of key; a key never invalidated, or
+// whose version outlived every entry, has the empty one
+func (t *Tiered) version(ctx context.Context, key string) (string, error) {
+	v, err := t.remote.Get(ctx, versionKey(key))
+	if errors.Is(err, ErrNotFound) {
+		return "", nil
+	}
+	return v, err
+}
+
+// remoteGet returns the shared entry for key if it carries version
+func (t *Tiered) remoteGet(ctx context.Context, key, version string) (string, bool) {
+	raw, err := t.remote.Get(ctx, key)
+	if err != nil {
+		return "", false
+	}
+	tag, v, ok := strings.Cut(raw, versionSep)
+	if !ok || tag != version {
+		return "", false
+	}
+	return v, true
+}
+
+func versionKey(key string) string {
+	return "version:" + key
+}
+
+func decode(v string) (string, error) {
+	if v == notFound {
+		return "", ErrNotFound
+	}
+	return v, nil
+}
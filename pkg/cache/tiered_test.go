@@ -0,0 +1,224 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// counter is a load that returns the next of its values on each call
+type counter struct {
+	calls atomic.Int32
+	err   error
+}
+
+func (c *counter) load(context.Context) (string, error) {
+	n := c.calls.Add(1)
+	if c.err != nil {
+		return "", c.err
+	}
+	return string(rune('a' + n - 1)), nil
+}
+
+func newTestTiered(remote ICache) *Tiered {
+	return NewTiered("test", remote, 100, time.Minute, time.Hour)
+}
+
+func TestTieredFetch(t *testing.T) {
+	ctx := context.Background()
+	remote := NewMemory()
+	t1, t2 := newTestTiered(remote), newTestTiered(remote)
+	var src counter
+
+	for i, tier := range []*Tiered{t1, t1, t2} {
+		v, err := tier.Fetch(ctx, "k", time.Minute, time.Minute, src.load)
+		if err != nil || v != "a" {
+			t.Fatalf("Fetch #%d = %q, %v, want a", i, v, err)
+		}
+	}
+	if n := src.calls.Load(); n != 1 {
+		t.Fatalf("load ran %d times, want once for both replicas", n)
+	}
+	if _, ok := t2.local.Get("k"); !ok {
+		t.Fatal("shared hit was not kept locally")
+	}
+
+	remote.(*memoryCache).now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	t3 := newTestTiered(remote)
+	if v, _ := t3.Fetch(ctx, "k", time.Minute, time.Minute, src.load); v != "b" {
+		t.Fatalf("Fetch after the shared ttl = %q, want a fresh load", v)
+	}
+}
+
+func TestTieredNotFound(t *testing.T) {
+	ctx := context.Background()
+	remote := NewMemory()
+	tier := newTestTiered(remote)
+	src := counter{err: ErrNotFound}
+
+	for i := 0; i < 2; i++ {
+		if _, err := tier.Fetch(ctx, "k", time.Minute, time.Minute, src.load); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Fetch #%d error = %v, want ErrNotFound", i, err)
+		}
+	}
+	if _, err := newTestTiered(remote).Fetch(ctx, "k", time.Minute, time.Minute, src.load); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Fetch on another replica error = %v, want ErrNotFound", err)
+	}
+	if n := src.calls.Load(); n != 1 {
+		t.Fatalf("load ran %d times, want the miss cached", n)
+	}
+
+	if err := tier.Invalidate(ctx, "k"); err != nil {
+		t.Fatal(err)
+	}
+	src.err = nil
+	if v, err := tier.Fetch(ctx, "k", time.Minute, time.Minute, src.load); err != nil || v != "b" {
+		t.Fatalf("Fetch after Invalidate = %q, %v, want b", v, err)
+	}
+}
+
+func TestTieredLoadError(t *testing.T) {
+	ctx := context.Background()
+	tier := newTestTiered(NewMemory())
+	src := counter{err: errors.New("connection refused")}
+
+	for i := 0; i < 2; i++ {
+		if _, err := tier.Fetch(ctx, "k", time.Minute, time.Minute, src.load); !errors.Is(err, src.err) {
+			t.Fatalf("Fetch #%d error = %v, want the load error", i, err)
+		}
+	}
+	if n := src.calls.Load(); n != 2 {
+		t.Fatalf("load ran %d times, want errors not cached", n)
+	}
+}
+
+func TestTieredInvalidate(t *testing.T) {
+	ctx := context.Background()
+	remote := NewMemory()
+	t1, t2 := newTestTiered(remote), newTestTiered(remote)
+	var src counter
+
+	if _, err := t1.Fetch(ctx, "k", time.Minute, time.Minute, src.load); err != nil {
+		t.Fatal(err)
+	}
+	if err := t2.Invalidate(ctx, "k"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := remote.Get(ctx, "k"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("shared entry survived Invalidate: %v", err)
+	}
+	// t1 only hears of it over pub/sub, which the memory backend lacks
+	if v, _ := t1.Fetch(ctx, "k", time.Minute, time.Minute, src.load); v != "a" {
+		t.Fatalf("Fetch on t1 = %q, want its local copy", v)
+	}
+	if v, _ := t2.Fetch(ctx, "k", time.Minute, time.Minute, src.load); v != "b" {
+		t.Fatalf("Fetch on t2 = %q, want a fresh load", v)
+	}
+}
+
+// A load that overlaps an invalidation on another replica read data from
+// before the write; its result must not be served from the shared cache
+func TestTieredInvalidateDuringLoad(t *testing.T) {
+	ctx := context.Background()
+	remote := NewMemory()
+	t1, t2 := newTestTiered(remote), newTestTiered(remote)
+
+	started, release := make(chan struct{}), make(chan struct{})
+	done := make(chan string)
+	go func() {
+		v, _ := t1.Fetch(ctx, "k", time.Minute, time.Minute, func(context.Context) (string, error) {
+			close(started)
+			<-release
+			return "old", nil
+		})
+		done <- v
+	}()
+
+	<-started
+	if err := t2.Invalidate(ctx, "k"); err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+	if v := <-done; v != "old" {
+		t.Fatalf("overlapping Fetch = %q, want its own load", v)
+	}
+
+	v, err := newTestTiered(remote).Fetch(ctx, "k", time.Minute, time.Minute, func(context.Context) (string, error) {
+		return "new", nil
+	})
+	if err != nil || v != "new" {
+		t.Fatalf("Fetch after the overlapping load = %q, %v, want new", v, err)
+	}
+	if _, ok := t1.local.Get("k"); !ok {
+		// The local tier only knows of local invalidations; t1 stored its
+		// result because it heard of none
+		t.Fatal("t1 did not keep its load locally")
+	}
+}
+
+func TestTieredLocalInvalidateDuringLoad(t *testing.T) {
+	ctx := context.Background()
+	tier := newTestTiered(NewMemory())
+
+	started, release := make(chan struct{}), make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = tier.Fetch(ctx, "k", time.Minute, time.Minute, func(context.Context) (string, error) {
+			close(started)
+			<-release
+			return "old", nil
+		})
+	}()
+
+	<-started
+	if err := tier.Invalidate(ctx, "k"); err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+	<-done
+
+	if _, ok := tier.local.Get("k"); ok {
+		t.Fatal("a load that overlapped Invalidate was stored locally")
+	}
+}
+
+func TestTieredCallerCancel(t *testing.T) {
+	tier := newTestTiered(NewMemory())
+
+	release := make(chan struct{})
+	load := func(ctx context.Context) (string, error) {
+		<-release
+		return "v", ctx.Err()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error)
+	go func() {
+		_, err := tier.Fetch(ctx, "k", time.Minute, time.Minute, load)
+		first <- err
+	}()
+	second := make(chan string)
+	go func() {
+		// Joins the load started by the first caller, or starts its own
+		v, _ := tier.Fetch(context.Background(), "k", time.Minute, time.Minute, load)
+		second <- v
+	}()
+
+	cancel()
+	select {
+	case err := <-first:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("canceled Fetch error = %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("canceled Fetch kept waiting for the load")
+	}
+
+	close(release)
+	if v := <-second; v != "v" {
+		t.Fatalf("other caller got %q, want the shared load to finish", v)
+	}
+}
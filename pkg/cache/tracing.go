@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/infosec554/clean-archtectura/pkg/tracing"
+)
+
+// tracingHook wraps every Redis command in a client span
+type tracingHook struct{}
+
+func (tracingHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+func (tracingHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		ctx, span := tracing.Start(ctx, "redis "+cmd.Name(), redisAttrs(cmd.Name())...)
+		defer span.End()
+
+		err := next(ctx, cmd)
+		recordRedisError(span, err)
+		return err
+	}
+}
+
+func (tracingHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		ctx, span := tracing.Start(ctx, "redis pipeline", redisAttrs("pipeline")...)
+		defer span.End()
+
+		err := next(ctx, cmds)
+		recordRedisError(span, err)
+		return err
+	}
+}
+
+func redisAttrs(command string) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("db.system.name", "redis"),
+		attribute.String("db.operation.name", command),
+	}
+}
+
+// recordRedisError ignores redis.Nil, a cache miss is not a failure
+func recordRedisError(span trace.Span, err error) {
+	if errors.Is(err, redis.Nil) {
+		return
+	}
+	tracing.RecordError(span, err)
+}
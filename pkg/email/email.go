@@ -2,14 +2,35 @@ package email
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
 
 	"github.com/infosec554/clean-archtectura/config"
+	domain "github.com/infosec554/clean-archtectura/domain/users"
+	"github.com/infosec554/clean-archtectura/pkg/health"
+	"github.com/infosec554/clean-archtectura/pkg/i18n"
+	"github.com/infosec554/clean-archtectura/pkg/metrics"
+	"github.com/infosec554/clean-archtectura/pkg/tracing"
 )
 
-const brevoURL = "https://api.brevo.com/v3/smtp/email"
+const (
+	brevoURL        = "https://api.brevo.com/v3/smtp/email"
+	brevoAccountURL = "https://api.brevo.com/v3/account"
+
+	// accountCheckTTL is how long the outcome of the health check is reused
+	accountCheckTTL = 5 * time.Minute
+)
+
+var httpClient = &http.Client{
+	Timeout:   20 * time.Second,
+	Transport: otelhttp.NewTransport(http.DefaultTransport),
+}
 
 type Sender struct {
 	cfg config.Config
@@ -31,19 +52,132 @@ type brevoContact struct {
 	Email string `json:"email"`
 }
 
+// Recipient is the addressee of an email; the language and time formatting
+// of the message follow their preferences.
+type Recipient struct {
+	Email       string
+	Preferences domain.Preferences
+}
+
+func (r Recipient) lang() i18n.Lang {
+	return i18n.Lang(r.Preferences.Locale)
+}
+
+// formatTime renders t in the recipient's timezone and language layout
+func (r Recipient) formatTime(t time.Time) string {
+	return t.In(r.Preferences.Location()).Format(i18n.T(r.lang(), i18n.MsgFormatDateTime))
+}
+
 // SendVerificationCode sends a 6-digit OTP code via Brevo API.
-func (s *Sender) SendVerificationCode(to, code string) error {
+func (s *Sender) SendVerificationCode(ctx context.Context, to Recipient, code string) error {
+	lang := to.lang()
+	return s.send(ctx, to.Email, "verification_code",
+		i18n.T(lang, i18n.MsgEmailVerificationSubject),
+		i18n.Tf(lang, i18n.MsgEmailVerificationBody, i18n.Params{"code": code}),
+	)
+}
+
+// SendDataExportLink sends the download link of a finished personal data export.
+func (s *Sender) SendDataExportLink(ctx context.Context, to Recipient, link string, expiresAt time.Time) error {
+	lang := to.lang()
+	return s.send(ctx, to.Email, "data_export_ready",
+		i18n.T(lang, i18n.MsgEmailExportReadySubject),
+		i18n.Tf(lang, i18n.MsgEmailExportReadyBody, i18n.Params{"link": link, "expires_at": to.formatTime(expiresAt)}),
+	)
+}
+
+// SendAccountStatus tells the user their account was suspended, banned or
+// reactivated. It is a notification, so a recipient who opted out of email
+// gets nothing.
+func (s *Sender) SendAccountStatus(ctx context.Context, to Recipient, status domain.AccountStatus, reason string, until *time.Time) error {
+	lang := to.lang()
+
+	var subject, text string
+	switch {
+	case status == domain.AccountStatusSuspended && until != nil:
+		subject = i18n.T(lang, i18n.MsgEmailSuspendedSubject)
+		text = i18n.Tf(lang, i18n.MsgEmailSuspendedUntilBody, i18n.Params{"until": to.formatTime(*until)})
+	case status == domain.AccountStatusSuspended:
+		subject = i18n.T(lang, i18n.MsgEmailSuspendedSubject)
+		text = i18n.T(lang, i18n.MsgEmailSuspendedBody)
+	case status == domain.AccountStatusBanned:
+		subject = i18n.T(lang, i18n.MsgEmailBannedSubject)
+		text = i18n.T(lang, i18n.MsgEmailBannedBody)
+	case status == domain.AccountStatusActive:
+		subject = i18n.T(lang, i18n.MsgEmailReactivatedSubject)
+		text = i18n.T(lang, i18n.MsgEmailReactivatedBody)
+	default:
+		return fmt.Errorf("no email for account status %q", status)
+	}
+	if reason != "" {
+		text += "\n\n" + i18n.Tf(lang, i18n.MsgEmailStatusReason, i18n.Params{"reason": reason})
+	}
+
+	return s.notify(ctx, to, "account_status", subject, text)
+}
+
+// HealthChecks verifies the Brevo API key. Email is not needed to serve
+// requests, so a failure only degrades readiness; without a key there is no
+// check. Brevo is asked at most once per accountCheckTTL, not on every probe.
+func (s *Sender) HealthChecks() []health.Check {
+	if s.cfg.BrevoAPIKey == "" {
+		return nil
+	}
+
+	return []health.Check{health.Cached(health.Check{
+		Name:     "email",
+		Critical: false,
+		Fn: func(ctx context.Context) error {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, brevoAccountURL, nil)
+			if err != nil {
+				return err
+			}
+			req.Header.Set("api-key", s.cfg.BrevoAPIKey)
+			req.Header.Set("Accept", "application/json")
+
+			resp, err := httpClient.Do(req)
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode >= 300 {
+				return fmt.Errorf("brevo: unexpected status %d", resp.StatusCode)
+			}
+			return nil
+		},
+	}, accountCheckTTL)}
+}
+
+// notify sends a message the recipient can opt out of. Verification codes
+// and export links go through send instead: the user asked for them.
+func (s *Sender) notify(ctx context.Context, to Recipient, template, subject, text string) error {
+	if !to.Preferences.Allows(domain.ChannelEmail) {
+		metrics.EmailsSent.WithLabelValues(template, metrics.ResultSkipped).Inc()
+		return nil
+	}
+	return s.send(ctx, to.Email, template, subject, text)
+}
+
+// send delivers the message and counts the outcome per template
+func (s *Sender) send(ctx context.Context, to, template, subject, text string) (err error) {
+	ctx, span := tracing.Start(ctx, "email.Send", attribute.String("email.template", template))
+	defer tracing.End(span, &err)
+
+	err = s.post(ctx, to, subject, text)
+	metrics.EmailsSent.WithLabelValues(template, metrics.Result(err)).Inc()
+	return err
+}
+
+func (s *Sender) post(ctx context.Context, to, subject, text string) error {
 	body := brevoRequest{
 		Sender: brevoContact{
 			Name:  s.cfg.BrevoSenderName,
 			Email: s.cfg.BrevoSenderEmail,
 		},
-		To:      []brevoContact{{Email: to}},
-		Subject: "Email Verification Code",
-		TextContent: fmt.Sprintf(
-			"Your verification code is: %s\n\nThis code expires in 5 minutes.",
-			code,
-		),
+		To:          []brevoContact{{Email: to}},
+		Subject:     subject,
+		TextContent: text,
 	}
 
 	payload, err := json.Marshal(body)
@@ -51,7 +185,7 @@ func (s *Sender) SendVerificationCode(to, code string) error {
 		return err
 	}
 
-	req, err := http.NewRequest(http.MethodPost, brevoURL, bytes.NewReader(payload))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, brevoURL, bytes.NewReader(payload))
 	if err != nil {
 		return err
 	}
@@ -59,7 +193,7 @@ func (s *Sender) SendVerificationCode(to, code string) error {
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return err
 	}
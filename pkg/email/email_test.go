@@ -0,0 +1,79 @@
+package email
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/infosec554/clean-archtectura/config"
+	domain "github.com/infosec554/clean-archtectura/domain/users"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// recordSent replaces the Brevo client for the test and returns the messages
+// posted to it
+func recordSent(t *testing.T) *[]brevoRequest {
+	t.Helper()
+	var sent []brevoRequest
+	orig := httpClient
+	httpClient = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		var body brevoRequest
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		sent = append(sent, body)
+		return &http.Response{StatusCode: http.StatusCreated, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+	})}
+	t.Cleanup(func() { httpClient = orig })
+	return &sent
+}
+
+func TestSendAccountStatusHonorsOptOut(t *testing.T) {
+	sent := recordSent(t)
+	sender := NewSender(config.Config{BrevoAPIKey: "key"})
+	ctx := context.Background()
+
+	prefs := domain.DefaultPreferences()
+	prefs.Locale = domain.LocaleEn
+	to := Recipient{Email: "user@example.com", Preferences: prefs}
+
+	until := time.Date(2030, 1, 2, 3, 4, 0, 0, time.UTC)
+	if err := sender.SendAccountStatus(ctx, to, domain.AccountStatusSuspended, "spam", &until); err != nil {
+		t.Fatalf("SendAccountStatus: %v", err)
+	}
+	if len(*sent) != 1 {
+		t.Fatalf("sent %d emails, want 1", len(*sent))
+	}
+	msg := (*sent)[0]
+	if msg.Subject != "Your account has been suspended" || !strings.Contains(msg.TextContent, "Reason: spam") {
+		t.Errorf("message = %q / %q", msg.Subject, msg.TextContent)
+	}
+	if !strings.Contains(msg.TextContent, "Jan 2, 2030 8:04 AM") {
+		t.Errorf("suspension end not in the user's timezone: %q", msg.TextContent)
+	}
+
+	to.Preferences.Notifications.Email = false
+	if err := sender.SendAccountStatus(ctx, to, domain.AccountStatusBanned, "spam", nil); err != nil {
+		t.Fatalf("SendAccountStatus: %v", err)
+	}
+	if len(*sent) != 1 {
+		t.Errorf("opted-out recipient was emailed")
+	}
+
+	// Codes the user asked for are sent regardless
+	if err := sender.SendVerificationCode(ctx, to, "123456"); err != nil {
+		t.Fatalf("SendVerificationCode: %v", err)
+	}
+	if len(*sent) != 2 {
+		t.Errorf("verification code not sent to an opted-out recipient")
+	}
+}
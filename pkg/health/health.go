@@ -0,0 +1,175 @@
+// Package health runs the readiness checks that components register about
+// themselves and reports a per-check breakdown with latencies.
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	StatusOK       = "ok"
+	StatusDegraded = "degraded"
+	StatusFail     = "fail"
+	StatusDraining = "draining"
+)
+
+// Check is a single named dependency probe. A failing critical check makes the
+// service not ready; a failing non-critical one only degrades the report.
+type Check struct {
+	Name     string
+	Critical bool
+	Fn       func(ctx context.Context) error
+}
+
+// Result is the outcome of one check
+type Result struct {
+	Status    string  `json:"status"`
+	Critical  bool    `json:"critical"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report is the readiness breakdown returned by Registry.Run
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks,omitempty"`
+}
+
+// Ready reports whether the service may receive traffic
+func (r Report) Ready() bool {
+	return r.Status == StatusOK || r.Status == StatusDegraded
+}
+
+// Registry holds the checks of every component
+type Registry struct {
+	timeout  time.Duration
+	draining <-chan struct{}
+
+	mu     sync.RWMutex
+	checks []Check
+}
+
+// NewRegistry returns a registry that gives every check at most timeout.
+// Once draining is closed the service reports not-ready without running checks.
+func NewRegistry(timeout time.Duration, draining <-chan struct{}) *Registry {
+	return &Registry{timeout: timeout, draining: draining}
+}
+
+// Register adds checks to the registry
+func (r *Registry) Register(checks ...Check) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks = append(r.checks, checks...)
+}
+
+// Run executes all checks concurrently
+func (r *Registry) Run(ctx context.Context) Report {
+	select {
+	case <-r.draining:
+		return Report{Status: StatusDraining}
+	default:
+	}
+
+	r.mu.RLock()
+	checks := append([]Check(nil), r.checks...)
+	r.mu.RUnlock()
+
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = r.run(ctx, c)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(checks))}
+	for i, c := range checks {
+		res := results[i]
+		report.Checks[c.Name] = res
+		if res.Status == StatusOK {
+			continue
+		}
+		if c.Critical {
+			report.Status = StatusFail
+		} else if report.Status == StatusOK {
+			report.Status = StatusDegraded
+		}
+	}
+	return report
+}
+
+func (r *Registry) run(ctx context.Context, c Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := c.Fn(ctx)
+	res := Result{
+		Status:    StatusOK,
+		Critical:  c.Critical,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		res.Status = StatusFail
+		res.Error = err.Error()
+	}
+	return res
+}
+
+// Cached runs the check at most once per ttl and reports the last outcome in
+// between, for dependencies that are slow, rate limited or billed per call.
+// A run cut short by the probe's own context is not remembered.
+func Cached(c Check, ttl time.Duration) Check {
+	var (
+		mu      sync.Mutex
+		checked time.Time
+		last    error
+	)
+	fn := c.Fn
+	c.Fn = func(ctx context.Context) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if !checked.IsZero() && time.Since(checked) < ttl {
+			return last
+		}
+		err := fn(ctx)
+		if ctx.Err() == nil {
+			checked, last = time.Now(), err
+		}
+		return err
+	}
+	return c
+}
+
+// HTTP returns a check that expects a 2xx answer to GET url,
+// e.g. the /minio/health/live endpoint of an object store
+func HTTP(name, url string, critical bool) Check {
+	return Check{
+		Name:     name,
+		Critical: critical,
+		Fn: func(ctx context.Context) error {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+			if err != nil {
+				return err
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode >= 300 {
+				return fmt.Errorf("unexpected status %d", resp.StatusCode)
+			}
+			return nil
+		},
+	}
+}
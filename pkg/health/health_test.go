@@ -0,0 +1,129 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func ok(context.Context) error   { return nil }
+func fail(context.Context) error { return errors.New("down") }
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name   string
+		checks []Check
+		status string
+		ready  bool
+	}{
+		{"no checks", nil, StatusOK, true},
+		{"all ok", []Check{{Name: "db", Critical: true, Fn: ok}, {Name: "email", Fn: ok}}, StatusOK, true},
+		{"non-critical failure", []Check{{Name: "db", Critical: true, Fn: ok}, {Name: "email", Fn: fail}}, StatusDegraded, true},
+		{"critical failure", []Check{{Name: "db", Critical: true, Fn: fail}, {Name: "email", Fn: fail}}, StatusFail, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRegistry(time.Second, make(chan struct{}))
+			r.Register(tt.checks...)
+
+			report := r.Run(context.Background())
+			if report.Status != tt.status || report.Ready() != tt.ready {
+				t.Errorf("report = %s (ready %t), want %s (ready %t)", report.Status, report.Ready(), tt.status, tt.ready)
+			}
+			if len(report.Checks) != len(tt.checks) {
+				t.Errorf("%d results, want %d", len(report.Checks), len(tt.checks))
+			}
+		})
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	r := NewRegistry(10*time.Millisecond, make(chan struct{}))
+	r.Register(Check{Name: "slow", Critical: true, Fn: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}})
+
+	report := r.Run(context.Background())
+	if res := report.Checks["slow"]; res.Status != StatusFail || res.Error != context.DeadlineExceeded.Error() {
+		t.Errorf("slow check = %+v, want a deadline failure", res)
+	}
+}
+
+func TestRunDraining(t *testing.T) {
+	draining := make(chan struct{})
+	r := NewRegistry(time.Second, draining)
+	r.Register(Check{Name: "db", Critical: true, Fn: func(context.Context) error {
+		t.Error("check ran while draining")
+		return nil
+	}})
+	close(draining)
+
+	if report := r.Run(context.Background()); report.Status != StatusDraining || report.Ready() {
+		t.Errorf("report = %+v, want draining", report)
+	}
+}
+
+func TestCached(t *testing.T) {
+	calls := 0
+	result := error(nil)
+	check := Cached(Check{Name: "email", Fn: func(context.Context) error {
+		calls++
+		return result
+	}}, 50*time.Millisecond)
+	ctx := context.Background()
+
+	for range 3 {
+		if err := check.Fn(ctx); err != nil {
+			t.Fatalf("Fn = %v", err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("probe ran %d times within the ttl, want 1", calls)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	result = errors.New("down")
+	if err := check.Fn(ctx); err == nil || calls != 2 {
+		t.Fatalf("after the ttl: Fn = %v with %d calls, want a fresh failure", err, calls)
+	}
+	if err := check.Fn(ctx); err == nil || calls != 2 {
+		t.Errorf("failure not reused: %v with %d calls", err, calls)
+	}
+}
+
+func TestCachedForgetsCancelledRuns(t *testing.T) {
+	calls := 0
+	check := Cached(Check{Name: "email", Fn: func(ctx context.Context) error {
+		calls++
+		return ctx.Err()
+	}}, time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := check.Fn(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Fn = %v, want canceled", err)
+	}
+	if err := check.Fn(context.Background()); err != nil || calls != 2 {
+		t.Errorf("Fn = %v with %d calls, want a new run", err, calls)
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/live" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	if err := HTTP("minio", srv.URL+"/live", true).Fn(context.Background()); err != nil {
+		t.Errorf("live endpoint: %v", err)
+	}
+	if err := HTTP("minio", srv.URL+"/down", true).Fn(context.Background()); err == nil {
+		t.Error("503 reported healthy")
+	}
+}
@@ -0,0 +1,120 @@
+package i18n
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	domain "github.com/infosec554/clean-archtectura/domain/users"
+)
+
+var placeholderRe = regexp.MustCompile(`\{[a-z_]+\}`)
+
+// TestCatalogComplete fails when a message exists in one language but not in another,
+// or when translations disagree on their placeholders
+func TestCatalogComplete(t *testing.T) {
+	keys := map[string]struct{}{}
+	for _, messages := range catalog {
+		for id := range messages {
+			keys[id] = struct{}{}
+		}
+	}
+
+	for _, lang := range Supported {
+		for id := range keys {
+			msg, ok := catalog[lang][id]
+			if !ok {
+				t.Errorf("%s: missing message %q", lang, id)
+				continue
+			}
+			if msg == "" {
+				t.Errorf("%s: empty message %q", lang, id)
+			}
+			if got, want := placeholders(msg), placeholders(catalog[Default][id]); got != want {
+				t.Errorf("%s: message %q has placeholders %s, want %s", lang, id, got, want)
+			}
+		}
+	}
+}
+
+func placeholders(msg string) string {
+	found := placeholderRe.FindAllString(msg, -1)
+	sort.Strings(found)
+	return fmt.Sprint(found)
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		header string
+		want   Lang
+	}{
+		{"", Default},
+		{"fr-FR", Default},
+		{"ru-RU,ru;q=0.9,en;q=0.8", Ru},
+		{"uz", Uz},
+		{"uz-Latn-UZ", Uz},
+		{"uz-Cyrl", UzCyrl},
+		{"en;q=0.5, uz-Cyrl-UZ;q=0.9", UzCyrl},
+		{"de, ru;q=0", Default},
+		{"*", Default},
+	}
+
+	for _, tt := range tests {
+		if got := Negotiate(tt.header); got != tt.want {
+			t.Errorf("Negotiate(%q) = %s, want %s", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestTf(t *testing.T) {
+	got := Tf(Ru, MsgVerificationCodeSent, Params{"email": "a@b.uz"})
+	if want := "Код подтверждения отправлен на a@b.uz"; got != want {
+		t.Errorf("Tf = %q, want %q", got, want)
+	}
+	if got := T(Uz, "no.such.message"); got != "no.such.message" {
+		t.Errorf("T of unknown ID = %q", got)
+	}
+}
+
+func TestDefaultMatchesPreferences(t *testing.T) {
+	if string(Default) != string(domain.DefaultLocale) {
+		t.Errorf("Default = %s, but new users default to %s", Default, domain.DefaultLocale)
+	}
+	for _, locale := range domain.SupportedLocales {
+		if !Lang(locale).IsSupported() {
+			t.Errorf("locale %s has no catalog", locale)
+		}
+	}
+}
+
+func TestLocalize(t *testing.T) {
+	seen := map[string]string{}
+	for id, msg := range catalog[En] {
+		if !strings.HasPrefix(id, MsgErrorPrefix) {
+			continue
+		}
+		if other, ok := seen[msg]; ok {
+			t.Errorf("%s and %s share the English text %q", id, other, msg)
+		}
+		seen[msg] = id
+	}
+
+	tests := []struct {
+		lang Lang
+		text string
+		want string
+	}{
+		{Ru, "user not found", "Пользователь не найден"},
+		{Uz, "account is banned", "Hisob bloklangan"},
+		{En, "user not found", "user not found"},
+		{Ru, "something else", "something else"},
+		{Ru, "", ""},
+	}
+	for _, tt := range tests {
+		if got := Localize(tt.lang, tt.text); got != tt.want {
+			t.Errorf("Localize(%s, %q) = %q, want %q", tt.lang, tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// Package i18n holds the translated message catalog of the API and emails.
+// Messages are looked up by ID; a language missing a message falls back to
+// the default language, and an unknown ID is returned as is.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+//go:embed locales/*.json
+var localeFS embed.FS
+
+// Lang is a supported language tag
+type Lang string
+
+const (
+	En     Lang = "en"
+	Ru     Lang = "ru"
+	Uz     Lang = "uz" // Uzbek, Latin script
+	UzCyrl Lang = "uz-Cyrl"
+)
+
+// Default is used when a client accepts none of the supported languages
+// and as the fallback for messages missing in a language. It matches the
+// default locale of user preferences.
+const Default = Uz
+
+// Supported lists every language that has a catalog file
+var Supported = []Lang{Uz, UzCyrl, Ru, En}
+
+// Params are substituted into {name} placeholders of a message
+type Params map[string]string
+
+var catalog = mustLoad()
+
+// errorIDs indexes the error messages by their English text
+var errorIDs = indexErrors(catalog[En])
+
+func mustLoad() map[Lang]map[string]string {
+	c := make(map[Lang]map[string]string, len(Supported))
+	for _, lang := range Supported {
+		data, err := localeFS.ReadFile("locales/" + string(lang) + ".json")
+		if err != nil {
+			panic(fmt.Sprintf("i18n: missing catalog for %s: %v", lang, err))
+		}
+		var messages map[string]string
+		if err := json.Unmarshal(data, &messages); err != nil {
+			panic(fmt.Sprintf("i18n: invalid catalog for %s: %v", lang, err))
+		}
+		c[lang] = messages
+	}
+	return c
+}
+
+func indexErrors(messages map[string]string) map[string]string {
+	ids := make(map[string]string)
+	for id, msg := range messages {
+		if strings.HasPrefix(id, MsgErrorPrefix) {
+			ids[msg] = id
+		}
+	}
+	return ids
+}
+
+// IsSupported reports whether lang has a catalog
+func (l Lang) IsSupported() bool {
+	_, ok := catalog[l]
+	return ok
+}
+
+// Lookup returns the message in lang, falling back to the default language
+func Lookup(lang Lang, id string) (string, bool) {
+	if msg, ok := catalog[lang][id]; ok {
+		return msg, true
+	}
+	msg, ok := catalog[Default][id]
+	return msg, ok
+}
+
+// T translates a message ID
+func T(lang Lang, id string) string {
+	if msg, ok := Lookup(lang, id); ok {
+		return msg
+	}
+	return id
+}
+
+// Tf translates a message ID and fills in its placeholders
+func Tf(lang Lang, id string, params Params) string {
+	msg := T(lang, id)
+	if len(params) == 0 {
+		return msg
+	}
+
+	pairs := make([]string, 0, len(params)*2)
+	for k, v := range params {
+		pairs = append(pairs, "{"+k+"}", v)
+	}
+	return strings.NewReplacer(pairs...).Replace(msg)
+}
+
+// Localize translates the message of a domain error. Domain errors carry
+// English, client-safe text: text that is in the catalog is returned in lang,
+// anything else as is.
+func Localize(lang Lang, text string) string {
+	if id, ok := errorIDs[text]; ok {
+		return T(lang, id)
+	}
+	return text
+}
@@ -0,0 +1,60 @@
+package i18n
+
+import "strconv"
+
+// Response descriptions
+const (
+	MsgUserRetrieved        = "response.user_retrieved"
+	MsgUserUpdated          = "response.user_updated"
+	MsgUserDeleted          = "response.user_deleted"
+	MsgUserRegistered       = "response.user_registered"
+	MsgLoginSuccessful      = "response.login_successful"
+	MsgEmailVerified        = "response.email_verified"
+	MsgVerificationCodeSent = "response.verification_code_sent"
+	MsgLoggedOut            = "response.logged_out"
+	MsgPasswordUpdated      = "response.password_updated"
+	MsgPreferencesRetrieved = "response.preferences_retrieved"
+	MsgPreferencesUpdated   = "response.preferences_updated"
+	MsgUserSuspended        = "response.user_suspended"
+	MsgUserUnsuspended      = "response.user_unsuspended"
+	MsgUserBanned           = "response.user_banned"
+	MsgUsersFound           = "response.users_found"
+	MsgImpersonationIssued  = "response.impersonation_issued"
+	MsgExportStarted        = "response.export_started"
+	MsgExportsRetrieved     = "response.exports_retrieved"
+)
+
+// Validation messages; rules are looked up as MsgValidationPrefix + rule,
+// with a ".string" suffix for length rules on strings
+const (
+	MsgValidationFailed  = "validation.failed"
+	MsgValidationDefault = "validation.default"
+	MsgValidationPrefix  = "validation."
+)
+
+// MsgErrorPrefix starts the IDs of domain error messages; see Localize
+const MsgErrorPrefix = "error."
+
+// Emails
+const (
+	MsgEmailVerificationSubject = "email.verification_code.subject"
+	MsgEmailVerificationBody    = "email.verification_code.body"
+	MsgEmailExportReadySubject  = "email.data_export_ready.subject"
+	MsgEmailExportReadyBody     = "email.data_export_ready.body"
+
+	MsgEmailSuspendedSubject   = "email.account_suspended.subject"
+	MsgEmailSuspendedBody      = "email.account_suspended.body"
+	MsgEmailSuspendedUntilBody = "email.account_suspended_until.body"
+	MsgEmailBannedSubject      = "email.account_banned.subject"
+	MsgEmailBannedBody         = "email.account_banned.body"
+	MsgEmailReactivatedSubject = "email.account_reactivated.subject"
+	MsgEmailReactivatedBody    = "email.account_reactivated.body"
+	MsgEmailStatusReason       = "email.account_status.reason"
+
+	MsgFormatDateTime = "format.datetime"
+)
+
+// MsgProblemTitle returns the ID of the problem title for an HTTP status
+func MsgProblemTitle(status int) string {
+	return "problem." + strconv.Itoa(status)
+}
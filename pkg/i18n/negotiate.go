@@ -0,0 +1,83 @@
+package i18n
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type ctxKey struct{}
+
+// WithLang stores the negotiated language in ctx
+func WithLang(ctx context.Context, lang Lang) context.Context {
+	return context.WithValue(ctx, ctxKey{}, lang)
+}
+
+// FromContext returns the language stored by WithLang, or Default
+func FromContext(ctx context.Context) Lang {
+	if lang, ok := ctx.Value(ctxKey{}).(Lang); ok {
+		return lang
+	}
+	return Default
+}
+
+// Negotiate picks the best supported language from an Accept-Language header,
+// honoring q-values. "uz" and "uz-Latn" map to Uz, any tag with the Cyrl
+// script to UzCyrl, and regional variants (ru-RU, en-US) to their base language.
+func Negotiate(header string) Lang {
+	type candidate struct {
+		tag string
+		q   float64
+	}
+
+	var candidates []candidate
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		tag := strings.TrimSpace(fields[0])
+		if tag == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, f := range fields[1:] {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(f), "q="); ok {
+				if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q > 0 {
+			candidates = append(candidates, candidate{tag: tag, q: q})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+
+	for _, c := range candidates {
+		if lang, ok := match(c.tag); ok {
+			return lang
+		}
+	}
+	return Default
+}
+
+func match(tag string) (Lang, bool) {
+	subtags := strings.Split(strings.ToLower(tag), "-")
+	switch subtags[0] {
+	case "uz":
+		for _, s := range subtags[1:] {
+			if s == "cyrl" {
+				return UzCyrl, true
+			}
+		}
+		return Uz, true
+	case "ru":
+		return Ru, true
+	case "en":
+		return En, true
+	case "*":
+		return Default, true
+	}
+	return "", false
+}
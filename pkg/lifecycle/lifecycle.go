@@ -0,0 +1,170 @@
+// Package lifecycle starts the application's servers and background workers,
+// waits for SIGINT/SIGTERM and shuts everything down in order: servers stop
+// accepting connections and drain, workers are cancelled and awaited, then
+// resources are closed in the reverse order of registration.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+type server struct {
+	name     string
+	start    func() error
+	shutdown func(ctx context.Context) error
+}
+
+type worker struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+type closer struct {
+	name  string
+	close func() error
+}
+
+// Manager owns the start and stop order of the application components
+type Manager struct {
+	timeout    time.Duration
+	drainDelay time.Duration
+	logger     zerolog.Logger
+
+	servers []server
+	workers []worker
+	closers []closer
+
+	draining chan struct{}
+	once     sync.Once
+}
+
+// New returns a Manager that gives the whole shutdown at most timeout.
+// drainDelay keeps serving after Draining is closed, so load balancers
+// polling the readiness probe stop routing before connections are refused.
+func New(timeout, drainDelay time.Duration, logger zerolog.Logger) *Manager {
+	return &Manager{
+		timeout:    timeout,
+		drainDelay: drainDelay,
+		logger:     logger.With().Str("component", "lifecycle").Logger(),
+		draining:   make(chan struct{}),
+	}
+}
+
+// Server registers a blocking listener. start must return http.ErrServerClosed
+// (or nil) once shutdown has been called.
+func (m *Manager) Server(name string, start func() error, shutdown func(ctx context.Context) error) {
+	m.servers = append(m.servers, server{name: name, start: start, shutdown: shutdown})
+}
+
+// Go registers a background worker. Its context is cancelled on shutdown,
+// and run should return once its in-flight work is finished.
+func (m *Manager) Go(name string, run func(ctx context.Context) error) {
+	m.workers = append(m.workers, worker{name: name, run: run})
+}
+
+// OnClose registers a resource to release after servers and workers have stopped
+func (m *Manager) OnClose(name string, close func() error) {
+	m.closers = append(m.closers, closer{name: name, close: close})
+}
+
+// Draining is closed as soon as shutdown begins
+func (m *Manager) Draining() <-chan struct{} {
+	return m.draining
+}
+
+// Run starts every server and worker and blocks until ctx is done, a signal
+// arrives or a component fails, then shuts down. The returned error joins
+// the failure that triggered the shutdown, if any, with shutdown errors.
+func (m *Manager) Run(ctx context.Context) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	workerCtx, cancelWorkers := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelWorkers()
+
+	failed := make(chan error, len(m.servers)+len(m.workers))
+
+	for _, s := range m.servers {
+		go func() {
+			m.logger.Info().Str("server", s.name).Msg("Starting server")
+			if err := s.start(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				failed <- fmt.Errorf("%s: %w", s.name, err)
+			}
+		}()
+	}
+
+	var wg sync.WaitGroup
+	for _, w := range m.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := w.run(workerCtx); err != nil && !errors.Is(err, context.Canceled) {
+				failed <- fmt.Errorf("%s: %w", w.name, err)
+			}
+		}()
+	}
+
+	var cause error
+	select {
+	case <-ctx.Done():
+		m.logger.Info().Msg("Shutdown signal received")
+	case cause = <-failed:
+		m.logger.Error().Err(cause).Msg("Component failed, shutting down")
+	}
+
+	return errors.Join(cause, m.shutdown(cancelWorkers, &wg))
+}
+
+func (m *Manager) shutdown(cancelWorkers context.CancelFunc, workers *sync.WaitGroup) error {
+	m.once.Do(func() { close(m.draining) })
+	if m.drainDelay > 0 {
+		m.logger.Info().Dur("delay", m.drainDelay).Msg("Draining before shutdown")
+		time.Sleep(m.drainDelay)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+
+	var errs []error
+
+	// 1. Stop accepting connections and drain in-flight requests
+	for _, s := range m.servers {
+		if err := s.shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("shutdown %s: %w", s.name, err))
+		}
+	}
+
+	// 2. Stop workers and wait for them within the remaining deadline
+	cancelWorkers()
+	done := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		errs = append(errs, fmt.Errorf("workers did not stop in time: %w", ctx.Err()))
+	}
+
+	// 3. Release resources, last registered first
+	for i := len(m.closers) - 1; i >= 0; i-- {
+		c := m.closers[i]
+		if err := c.close(); err != nil {
+			errs = append(errs, fmt.Errorf("close %s: %w", c.name, err))
+		}
+	}
+
+	m.logger.Info().Msg("Shutdown complete")
+	return errors.Join(errs...)
+}
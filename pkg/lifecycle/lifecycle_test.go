@@ -0,0 +1,138 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// recorder collects shutdown steps from several goroutines
+type recorder struct {
+	mu    sync.Mutex
+	steps []string
+}
+
+func (r *recorder) add(step string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.steps = append(r.steps, step)
+}
+
+func (r *recorder) String() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return strings.Join(r.steps, ",")
+}
+
+// fakeServer blocks in start until shutdown is called, like http.Server
+func fakeServer(m *Manager, rec *recorder, name string) {
+	stopped := make(chan struct{})
+	m.Server(name, func() error {
+		<-stopped
+		return http.ErrServerClosed
+	}, func(context.Context) error {
+		rec.add("shutdown " + name)
+		close(stopped)
+		return nil
+	})
+}
+
+// runUntil runs m and cancels it after started is closed
+func runUntil(t *testing.T, m *Manager, started <-chan struct{}) error {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+
+	done := make(chan error, 1)
+	go func() { done <- m.Run(ctx) }()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return")
+		return nil
+	}
+}
+
+func TestShutdownOrder(t *testing.T) {
+	rec := &recorder{}
+	m := New(time.Second, 0, zerolog.Nop())
+	started := make(chan struct{})
+
+	m.OnClose("postgres", func() error { rec.add("close postgres"); return nil })
+	fakeServer(m, rec, "http")
+	m.Go("worker", func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		// Servers are already drained when workers are told to stop
+		rec.add("stop worker")
+		return ctx.Err()
+	})
+	m.OnClose("redis", func() error { rec.add("close redis"); return nil })
+
+	if err := runUntil(t, m, started); err != nil {
+		t.Fatalf("Run = %v", err)
+	}
+	if got, want := rec.String(), "shutdown http,stop worker,close redis,close postgres"; got != want {
+		t.Errorf("steps = %s, want %s", got, want)
+	}
+	select {
+	case <-m.Draining():
+	default:
+		t.Error("Draining not closed")
+	}
+}
+
+func TestComponentFailure(t *testing.T) {
+	rec := &recorder{}
+	m := New(time.Second, 0, zerolog.Nop())
+	boom := errors.New("boom")
+
+	fakeServer(m, rec, "http")
+	m.Go("relay", func(context.Context) error { return boom })
+	m.OnClose("postgres", func() error { rec.add("close postgres"); return errors.New("close failed") })
+
+	err := runUntil(t, m, make(chan struct{}))
+	if !errors.Is(err, boom) {
+		t.Errorf("Run = %v, want the worker failure", err)
+	}
+	if err == nil || !strings.Contains(err.Error(), "close postgres: close failed") {
+		t.Errorf("Run = %v, want the close error joined", err)
+	}
+	if got, want := rec.String(), "shutdown http,close postgres"; got != want {
+		t.Errorf("steps = %s, want %s", got, want)
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	rec := &recorder{}
+	m := New(50*time.Millisecond, 0, zerolog.Nop())
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+
+	m.Go("stuck", func(context.Context) error {
+		close(started)
+		<-release
+		return nil
+	})
+	m.OnClose("postgres", func() error { rec.add("close postgres"); return nil })
+
+	err := runUntil(t, m, started)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Run = %v, want the deadline exceeded", err)
+	}
+	// Resources are released even when a worker overstays
+	if got := rec.String(); got != "close postgres" {
+		t.Errorf("steps = %s, want close postgres", got)
+	}
+}
@@ -0,0 +1,72 @@
+// Package logging carries request-scoped fields through context.Context.
+// Loggers built with Hook add them, together with the trace ID, to every
+// event that is given the request context via Event.Ctx.
+package logging
+
+import (
+	"context"
+
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Fields identify the request a log line belongs to
+type Fields struct {
+	RequestID string
+	UserID    string
+	Method    string
+	Route     string
+	IP        string
+}
+
+type ctxKey struct{}
+
+// NewContext stores the request fields in ctx
+func NewContext(ctx context.Context, f *Fields) context.Context {
+	return context.WithValue(ctx, ctxKey{}, f)
+}
+
+// FromContext returns the request fields stored by NewContext, or nil
+func FromContext(ctx context.Context) *Fields {
+	if ctx == nil {
+		return nil
+	}
+	f, _ := ctx.Value(ctxKey{}).(*Fields)
+	return f
+}
+
+// SetUserID records the authenticated user. It must be called before the
+// request fans out to other goroutines, i.e. from the auth middleware.
+func SetUserID(ctx context.Context, id string) {
+	if f := FromContext(ctx); f != nil {
+		f.UserID = id
+	}
+}
+
+// RequestID returns the ID of the request ctx belongs to
+func RequestID(ctx context.Context) string {
+	if f := FromContext(ctx); f != nil {
+		return f.RequestID
+	}
+	return ""
+}
+
+// Hook adds the request fields and trace ID of the event's context
+type Hook struct{}
+
+func (Hook) Run(e *zerolog.Event, _ zerolog.Level, _ string) {
+	ctx := e.GetCtx()
+
+	if f := FromContext(ctx); f != nil {
+		e.Str("request_id", f.RequestID)
+		// auth_user_id is the caller; components log the user they act on as user_id
+		if f.UserID != "" {
+			e.Str("auth_user_id", f.UserID)
+		}
+		e.Str("method", f.Method).Str("route", f.Route).Str("ip", f.IP)
+	}
+
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		e.Str("trace_id", sc.TraceID().String())
+	}
+}
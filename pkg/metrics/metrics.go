@@ -0,0 +1,206 @@
+// Package metrics defines the Prometheus collectors of the application.
+// They are registered on Registry, which is served by Handler.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "app"
+
+// Registry holds every application collector plus the Go runtime and process ones
+var Registry = prometheus.NewRegistry()
+
+// HTTP
+var (
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by method, route template and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestsInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_in_flight",
+		Help:      "HTTP requests currently being served.",
+	})
+)
+
+// Redis
+var (
+	RedisCommandDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "redis",
+		Name:      "command_duration_seconds",
+		Help:      "Redis command latency by command name.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"command"})
+
+	RedisCommandErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "redis",
+		Name:      "command_errors_total",
+		Help:      "Failed Redis commands by command name; cache misses are not errors.",
+	}, []string{"command"})
+)
+
+// Read-through caches
+var (
+	CacheHits = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "hits_total",
+		Help:      "Cache hits by cache name and tier (local, remote), including cached not-found results.",
+	}, []string{"cache", "tier"})
+
+	CacheMisses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "misses_total",
+		Help:      "Lookups that went to the source of truth, by cache name.",
+	}, []string{"cache"})
+)
+
+// Cache tier label values
+const (
+	TierLocal  = "local"
+	TierRemote = "remote"
+)
+
+// Database
+var (
+	DBTxRetries = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "tx_retries_total",
+		Help:      "Transactions run again after a serialization failure or deadlock.",
+	})
+
+	DBReplicaHealthy = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "replica_healthy",
+		Help:      "Whether a read replica is receiving reads (1) or ejected (0).",
+	}, []string{"replica"})
+
+	DBReplicaLag = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "replica_lag_bytes",
+		Help:      "Bytes of WAL a read replica had not replayed at its last health check.",
+	}, []string{"replica"})
+)
+
+// Background jobs
+var (
+	JobsProcessed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "jobs",
+		Name:      "processed_total",
+		Help:      "Job attempts by queue, kind and result (success, retry, dead).",
+	}, []string{"queue", "kind", "result"})
+
+	JobDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "jobs",
+		Name:      "duration_seconds",
+		Help:      "Time spent running a job attempt, by queue and kind.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"queue", "kind"})
+
+	OutboxDeliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "outbox",
+		Name:      "deliveries_total",
+		Help:      "Outbox event delivery attempts by topic and result (success, retry, dead).",
+	}, []string{"topic", "result"})
+)
+
+// Email
+var EmailsSent = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: "email",
+	Name:      "sent_total",
+	Help:      "Outbound emails by template and result (success, failure, skipped).",
+}, []string{"template", "result"})
+
+// Business events
+var (
+	Registrations = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "users",
+		Name:      "registrations_total",
+		Help:      "Successful user registrations.",
+	})
+
+	Logins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "users",
+		Name:      "logins_total",
+		Help:      "Login attempts by result.",
+	}, []string{"result"})
+
+	EmailVerifications = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "users",
+		Name:      "email_verifications_total",
+		Help:      "Email verification attempts by result.",
+	}, []string{"result"})
+)
+
+// Result label values
+const (
+	ResultSuccess            = "success"
+	ResultFailure            = "failure"
+	ResultInvalidCredentials = "invalid_credentials"
+	ResultUnverified         = "unverified"
+	ResultBlocked            = "blocked"
+	ResultExpired            = "expired"
+	ResultInvalidCode        = "invalid_code"
+	ResultRetry              = "retry"
+	ResultDead               = "dead"
+	ResultSkipped            = "skipped"
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequestDuration,
+		HTTPRequestsInFlight,
+		RedisCommandDuration,
+		RedisCommandErrors,
+		CacheHits,
+		CacheMisses,
+		DBTxRetries,
+		DBReplicaHealthy,
+		DBReplicaLag,
+		JobsProcessed,
+		JobDuration,
+		OutboxDeliveries,
+		EmailsSent,
+		Registrations,
+		Logins,
+		EmailVerifications,
+	)
+}
+
+// Result maps an error to the success/failure label
+func Result(err error) string {
+	if err != nil {
+		return ResultFailure
+	}
+	return ResultSuccess
+}
+
+// Handler serves Registry in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
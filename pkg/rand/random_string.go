@@ -1,6 +1,8 @@
 package rand
 
 import (
+	crand "crypto/rand"
+	"encoding/base64"
 	"math/rand"
 	"time"
 )
@@ -18,3 +20,12 @@ func RandString(n int) string {
 	}
 	return string(b)
 }
+
+// SecureToken returns a URL-safe random token built from n bytes of crypto/rand
+func SecureToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := crand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
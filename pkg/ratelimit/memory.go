@@ -0,0 +1,48 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const sweepEvery = 1024
+
+// MemoryStore keeps buckets in process memory; use it for a single node or tests
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]time.Time
+	calls   int
+	now     func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]time.Time), now: time.Now}
+}
+
+// Allow implements Store
+func (s *MemoryStore) Allow(_ context.Context, key string, p Policy) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	res, tat := gcra(now, s.buckets[key], p)
+	if res.Allowed {
+		s.buckets[key] = tat
+	}
+
+	s.calls++
+	if s.calls%sweepEvery == 0 {
+		s.sweep(now)
+	}
+	return res, nil
+}
+
+// sweep drops buckets that have refilled completely
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, tat := range s.buckets {
+		if !tat.After(now) {
+			delete(s.buckets, key)
+		}
+	}
+}
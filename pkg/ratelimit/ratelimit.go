@@ -0,0 +1,129 @@
+// Package ratelimit implements GCRA (a token bucket without a refill timer)
+// over pluggable stores: Redis for multi-replica deployments, memory for a
+// single node and tests.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// KeyBy selects what a policy counts requests per
+type KeyBy string
+
+const (
+	KeyByIP     KeyBy = "ip"
+	KeyByUser   KeyBy = "user"
+	KeyByAPIKey KeyBy = "api_key"
+)
+
+// Policy allows Limit requests per Window, with bursts of up to Limit
+type Policy struct {
+	Name   string
+	Limit  int
+	Window time.Duration
+	KeyBy  KeyBy
+}
+
+// interval is the time one request "costs" in the bucket
+func (p Policy) interval() time.Duration {
+	return p.Window / time.Duration(p.Limit)
+}
+
+// Result is the outcome of one Allow call
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	ResetAfter time.Duration // until the bucket is full again
+	RetryAfter time.Duration // until the next request is allowed, zero if allowed
+}
+
+// Store atomically takes one request from the bucket stored under key
+type Store interface {
+	Allow(ctx context.Context, key string, p Policy) (Result, error)
+}
+
+// ParsePolicies reads "name=limit/window:key" entries separated by commas,
+// e.g. "auth=5/1m:ip,private=120/1m:user"
+func ParsePolicies(s string) (map[string]Policy, error) {
+	policies := make(map[string]Policy)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		p, err := parsePolicy(entry)
+		if err != nil {
+			return nil, fmt.Errorf("rate limit policy %q: %w", entry, err)
+		}
+		policies[p.Name] = p
+	}
+	return policies, nil
+}
+
+func parsePolicy(entry string) (Policy, error) {
+	name, rule, ok := strings.Cut(entry, "=")
+	if !ok || name == "" {
+		return Policy{}, fmt.Errorf("expected name=limit/window:key")
+	}
+
+	rule, key, ok := strings.Cut(rule, ":")
+	if !ok {
+		key = string(KeyByIP)
+	}
+	switch KeyBy(key) {
+	case KeyByIP, KeyByUser, KeyByAPIKey:
+	default:
+		return Policy{}, fmt.Errorf("unknown key %q", key)
+	}
+
+	limitStr, windowStr, ok := strings.Cut(rule, "/")
+	if !ok {
+		return Policy{}, fmt.Errorf("expected limit/window")
+	}
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit <= 0 {
+		return Policy{}, fmt.Errorf("invalid limit %q", limitStr)
+	}
+	window, err := time.ParseDuration(windowStr)
+	if err != nil || window <= 0 {
+		return Policy{}, fmt.Errorf("invalid window %q", windowStr)
+	}
+
+	return Policy{Name: strings.TrimSpace(name), Limit: limit, Window: window, KeyBy: KeyBy(key)}, nil
+}
+
+// gcra applies one request to a bucket whose theoretical arrival time is tat
+// and returns the result plus the new tat to store (zero if denied)
+func gcra(now, tat time.Time, p Policy) (Result, time.Time) {
+	interval := p.interval()
+	burst := time.Duration(p.Limit) * interval
+
+	if tat.Before(now) {
+		tat = now
+	}
+	newTAT := tat.Add(interval)
+	allowAt := newTAT.Add(-burst)
+
+	if now.Before(allowAt) {
+		return Result{
+			Allowed:    false,
+			Limit:      p.Limit,
+			Remaining:  0,
+			ResetAfter: tat.Sub(now),
+			RetryAfter: allowAt.Sub(now),
+		}, time.Time{}
+	}
+
+	return Result{
+		Allowed:    true,
+		Limit:      p.Limit,
+		Remaining:  int(now.Sub(allowAt) / interval),
+		ResetAfter: newTAT.Sub(now),
+	}, newTAT
+}
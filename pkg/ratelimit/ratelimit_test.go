@@ -0,0 +1,120 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParsePolicies(t *testing.T) {
+	got, err := ParsePolicies(" auth=5/1m:ip, private=120/1m:user,,bulk=10/1h:api_key,public=60/1s")
+	if err != nil {
+		t.Fatalf("ParsePolicies: %v", err)
+	}
+	want := map[string]Policy{
+		"auth":    {Name: "auth", Limit: 5, Window: time.Minute, KeyBy: KeyByIP},
+		"private": {Name: "private", Limit: 120, Window: time.Minute, KeyBy: KeyByUser},
+		"bulk":    {Name: "bulk", Limit: 10, Window: time.Hour, KeyBy: KeyByAPIKey},
+		"public":  {Name: "public", Limit: 60, Window: time.Second, KeyBy: KeyByIP},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d policies, want %d", len(got), len(want))
+	}
+	for name, p := range want {
+		if got[name] != p {
+			t.Errorf("%s = %+v, want %+v", name, got[name], p)
+		}
+	}
+
+	for _, bad := range []string{
+		"auth",
+		"=5/1m",
+		"auth=5",
+		"auth=0/1m",
+		"auth=-1/1m",
+		"auth=x/1m",
+		"auth=5/0s",
+		"auth=5/soon",
+		"auth=5/1m:session",
+	} {
+		if _, err := ParsePolicies(bad); err == nil {
+			t.Errorf("ParsePolicies(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestGCRA(t *testing.T) {
+	p := Policy{Name: "test", Limit: 3, Window: 3 * time.Second}
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	steps := []struct {
+		at        time.Duration
+		allowed   bool
+		remaining int
+		retry     time.Duration
+		reset     time.Duration
+	}{
+		// A full bucket allows a burst of Limit requests
+		{0, true, 2, 0, time.Second},
+		{0, true, 1, 0, 2 * time.Second},
+		{0, true, 0, 0, 3 * time.Second},
+		{0, false, 0, time.Second, 3 * time.Second},
+		{500 * time.Millisecond, false, 0, 500 * time.Millisecond, 2500 * time.Millisecond},
+		// One interval later one request fits again
+		{time.Second, true, 0, 0, 3 * time.Second},
+		// After the whole window the bucket is full
+		{10 * time.Second, true, 2, 0, time.Second},
+	}
+
+	var tat time.Time
+	for i, s := range steps {
+		res, newTAT := gcra(t0.Add(s.at), tat, p)
+		if res.Allowed != s.allowed || res.Remaining != s.remaining || res.RetryAfter != s.retry || res.ResetAfter != s.reset {
+			t.Fatalf("step %d: %+v, want allowed %t remaining %d retry %s reset %s", i, res, s.allowed, s.remaining, s.retry, s.reset)
+		}
+		if res.Limit != p.Limit {
+			t.Errorf("step %d: limit %d", i, res.Limit)
+		}
+		if res.Allowed {
+			tat = newTAT
+		} else if !newTAT.IsZero() {
+			t.Errorf("step %d: denied request returned a new tat", i)
+		}
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := NewMemoryStore()
+	s.now = func() time.Time { return now }
+	ctx := context.Background()
+	p := Policy{Name: "test", Limit: 2, Window: time.Minute}
+
+	allow := func(key string) bool {
+		t.Helper()
+		res, err := s.Allow(ctx, key, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res.Allowed
+	}
+
+	if !allow("a") || !allow("a") || allow("a") {
+		t.Fatal("key a: want two allowed, then denied")
+	}
+	if !allow("b") {
+		t.Error("key b shares a's bucket")
+	}
+
+	now = now.Add(30 * time.Second)
+	if !allow("a") || allow("a") {
+		t.Error("key a: want one request back after half the window")
+	}
+
+	// Refilled buckets are swept
+	now = now.Add(time.Hour)
+	s.sweep(now)
+	if len(s.buckets) != 0 {
+		t.Errorf("%d buckets left after sweep, want 0", len(s.buckets))
+	}
+}
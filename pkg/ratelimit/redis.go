@@ -0,0 +1,64 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// gcraScript is the Redis version of gcra. It reads the clock from Redis so
+// that all replicas agree on time, and stores the TAT in milliseconds.
+//
+// KEYS[1] bucket key, ARGV[1] interval ms, ARGV[2] limit.
+// Returns {allowed, remaining, reset_after_ms, retry_after_ms}.
+var gcraScript = redis.NewScript(`
+local interval = tonumber(ARGV[1])
+local burst = interval * tonumber(ARGV[2])
+
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local tat = tonumber(redis.call('GET', KEYS[1]) or now)
+if tat < now then
+	tat = now
+end
+
+local new_tat = tat + interval
+local allow_at = new_tat - burst
+
+if now < allow_at then
+	return {0, 0, tat - now, allow_at - now}
+end
+
+redis.call('SET', KEYS[1], new_tat, 'PX', new_tat - now)
+return {1, math.floor((now - allow_at) / interval), new_tat - now, 0}
+`)
+
+// RedisStore shares buckets between replicas
+type RedisStore struct {
+	client redis.Scripter
+	prefix string
+}
+
+func NewRedisStore(client redis.Scripter) *RedisStore {
+	return &RedisStore{client: client, prefix: "ratelimit:"}
+}
+
+// Allow implements Store
+func (s *RedisStore) Allow(ctx context.Context, key string, p Policy) (Result, error) {
+	interval := max(p.interval().Milliseconds(), 1)
+
+	vals, err := gcraScript.Run(ctx, s.client, []string{s.prefix + key}, interval, p.Limit).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+
+	return Result{
+		Allowed:    vals[0] == 1,
+		Limit:      p.Limit,
+		Remaining:  int(vals[1]),
+		ResetAfter: time.Duration(vals[2]) * time.Millisecond,
+		RetryAfter: time.Duration(vals[3]) * time.Millisecond,
+	}, nil
+}
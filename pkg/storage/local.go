@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Local keeps files in a directory on the local filesystem.
+// It is meant for short-lived artifacts such as data exports.
+type Local struct {
+	dir string
+}
+
+func NewLocal(dir string) (*Local, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("storage dir error: %w", err)
+	}
+	return &Local{dir: dir}, nil
+}
+
+// Put writes the reader to a file called name and returns its storage path
+func (l *Local) Put(_ context.Context, name string, r io.Reader) (string, error) {
+	path, err := l.resolve(name)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		_ = os.Remove(path)
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(path)
+		return "", err
+	}
+
+	return name, nil
+}
+
+// Open returns a reader for a previously stored file
+func (l *Local) Open(_ context.Context, name string) (io.ReadCloser, error) {
+	path, err := l.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+// Remove deletes a stored file; missing files are not an error
+func (l *Local) Remove(_ context.Context, name string) error {
+	path, err := l.resolve(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (l *Local) resolve(name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return "", fmt.Errorf("storage: invalid file name %q", name)
+	}
+	return filepath.Join(l.dir, name), nil
+}
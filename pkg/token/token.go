@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 
 	userDomain "github.com/infosec554/clean-archtectura/domain/users"
 )
@@ -22,10 +23,12 @@ func (j *JWTManager) Generate(user userDomain.User) (string, string, error) {
 	// Access Token
 	accessExp := time.Now().Add(24 * time.Hour)
 	accessClaims := jwt.MapClaims{
+		"sub":        user.ID.String(),
 		"user_id":    user.ID.String(),
 		"email":      derefString(user.Email),
 		"first_name": user.FirstName,
 		"last_name":  user.LastName,
+		"role":       string(user.Role),
 		"exp":        accessExp.Unix(),
 		"iat":        time.Now().Unix(),
 	}
@@ -37,6 +40,7 @@ func (j *JWTManager) Generate(user userDomain.User) (string, string, error) {
 	// Refresh Token
 	refreshExp := time.Now().Add(7 * 24 * time.Hour)
 	refreshClaims := jwt.MapClaims{
+		"sub":     user.ID.String(),
 		"user_id": user.ID.String(),
 		"exp":     refreshExp.Unix(),
 		"iat":     time.Now().Unix(),
@@ -49,6 +53,34 @@ func (j *JWTManager) Generate(user userDomain.User) (string, string, error) {
 	return accessToken, refreshToken, nil
 }
 
+// GenerateImpersonation generates a short-lived access token that acts as the target user.
+// The token carries the target in "sub" and the real actor in the RFC 8693 "act" claim.
+// No refresh token is issued, so the session cannot outlive ttl.
+func (j *JWTManager) GenerateImpersonation(target userDomain.User, actorID uuid.UUID, ttl time.Duration) (string, time.Time, error) {
+	now := time.Now()
+	exp := now.Add(ttl)
+	claims := jwt.MapClaims{
+		"sub":        target.ID.String(),
+		"user_id":    target.ID.String(),
+		"email":      derefString(target.Email),
+		"first_name": target.FirstName,
+		"last_name":  target.LastName,
+		"role":       string(target.Role),
+		"act": map[string]any{
+			"sub": actorID.String(),
+		},
+		"jti": uuid.NewString(),
+		"exp": exp.Unix(),
+		"iat": now.Unix(),
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(j.SecretKey)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, exp, nil
+}
+
 func (j *JWTManager) Verify(tokenStr string) (bool, jwt.MapClaims, error) {
 	token, err := jwt.Parse(tokenStr, func(t *jwt.Token) (any, error) {
 		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
@@ -66,7 +98,7 @@ func (j *JWTManager) Verify(tokenStr string) (bool, jwt.MapClaims, error) {
 	return false, nil, fmt.Errorf("invalid token")
 }
 
-// pointerli stringni xavfsiz ochish
+// derefString returns the string s points to, or "" for nil
 func derefString(s *string) string {
 	if s == nil {
 		return ""
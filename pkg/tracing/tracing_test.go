@@ -0,0 +1,45 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func traced(ctx context.Context, fail error) (err error) {
+	_, span := Start(ctx, "Test.Op")
+	defer End(span, &err)
+
+	if fail != nil {
+		return fail
+	}
+	return nil
+}
+
+func TestEnd(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	boom := errors.New("boom")
+	_ = traced(context.Background(), nil)
+	_ = traced(context.Background(), boom)
+
+	spans := rec.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("%d spans ended, want 2", len(spans))
+	}
+	if s := spans[0]; s.Status().Code == codes.Error || len(s.Events()) != 0 {
+		t.Errorf("successful span: status %v, %d events", s.Status(), len(s.Events()))
+	}
+	if s := spans[1]; s.Status().Code != codes.Error || s.Status().Description != "boom" || len(s.Events()) != 1 {
+		t.Errorf("failed span: status %v, %d events", s.Status(), len(s.Events()))
+	}
+}
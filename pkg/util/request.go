@@ -10,10 +10,13 @@ import (
 	"time"
 
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 var client = http.Client{
 	Timeout: time.Second * 20,
+	// Creates a client span per request and injects the W3C traceparent header
+	Transport: otelhttp.NewTransport(http.DefaultTransport),
 }
 
 func SendRequest(ctx context.Context, method, url string, headers map[string]string, body []byte, query map[string]string, respModel any) error {
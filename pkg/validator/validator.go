@@ -0,0 +1,145 @@
+package validator
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/go-playground/validator/v10"
+
+	errs "github.com/infosec554/clean-archtectura/domain"
+	"github.com/infosec554/clean-archtectura/pkg/i18n"
+)
+
+var (
+	phoneRegex = regexp.MustCompile(`^\+998\d{9}$`)
+	pinflRegex = regexp.MustCompile(`^[1-6]\d{13}$`)
+)
+
+// Validator implements echo.Validator on top of go-playground/validator
+type Validator struct {
+	validate *validator.Validate
+}
+
+func New() *Validator {
+	v := validator.New(validator.WithRequiredStructEnabled())
+
+	// Report JSON / query names instead of Go field names
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		for _, tag := range []string{"json", "query", "param"} {
+			name := strings.SplitN(f.Tag.Get(tag), ",", 2)[0]
+			if name == "-" {
+				continue
+			}
+			if name != "" {
+				return name
+			}
+		}
+		return f.Name
+	})
+
+	_ = v.RegisterValidation("phone", isPhone)
+	_ = v.RegisterValidation("pinfl", isPINFL)
+	_ = v.RegisterValidation("strongpassword", isStrongPassword)
+	_ = v.RegisterValidation("timezone", isTimezone)
+
+	return &Validator{validate: v}
+}
+
+// Validate implements echo.Validator. It returns validator.ValidationErrors
+// untouched, use Translate to turn them into a localized domain error.
+func (v *Validator) Validate(i any) error {
+	return v.validate.Struct(i)
+}
+
+// Translate converts validation errors into an errs.ErrValidation error
+// with one message per field in the given language
+func Translate(err error, lang i18n.Lang) error {
+	var ve validator.ValidationErrors
+	if !errors.As(err, &ve) {
+		return errs.BadRequest("invalid payload")
+	}
+
+	fields := make([]errs.FieldError, 0, len(ve))
+	for _, fe := range ve {
+		fields = append(fields, errs.FieldError{
+			Field:   fieldPath(fe),
+			Rule:    fe.Tag(),
+			Message: message(fe, lang),
+		})
+	}
+	return errs.Validation(i18n.T(lang, i18n.MsgValidationFailed), fields...)
+}
+
+// fieldPath drops the root struct name: "CreateUser.email" -> "email"
+func fieldPath(fe validator.FieldError) string {
+	ns := fe.Namespace()
+	if i := strings.Index(ns, "."); i >= 0 {
+		return ns[i+1:]
+	}
+	return fe.Field()
+}
+
+// message looks up the catalog text of the failed rule,
+// preferring the ".string" variant for length rules on strings
+func message(fe validator.FieldError, lang i18n.Lang) string {
+	params := i18n.Params{"field": fieldPath(fe), "param": fe.Param()}
+
+	id := i18n.MsgValidationPrefix + fe.Tag()
+	if fe.Kind() == reflect.String {
+		if _, ok := i18n.Lookup(lang, id+".string"); ok {
+			return i18n.Tf(lang, id+".string", params)
+		}
+	}
+	if _, ok := i18n.Lookup(lang, id); ok {
+		return i18n.Tf(lang, id, params)
+	}
+	return i18n.Tf(lang, i18n.MsgValidationDefault, params)
+}
+
+// isPhone accepts Uzbek numbers in E.164 format, e.g. +998901234567
+func isPhone(fl validator.FieldLevel) bool {
+	return phoneRegex.MatchString(fl.Field().String())
+}
+
+// isPINFL accepts a 14-digit personal identification number
+func isPINFL(fl validator.FieldLevel) bool {
+	return pinflRegex.MatchString(fl.Field().String())
+}
+
+// isStrongPassword requires 8+ characters with upper, lower, digit and symbol
+func isStrongPassword(fl validator.FieldLevel) bool {
+	s := fl.Field().String()
+	if len([]rune(s)) < 8 {
+		return false
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range s {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			symbol = true
+		}
+	}
+	return upper && lower && digit && symbol
+}
+
+// isTimezone accepts IANA timezone names such as Asia/Tashkent. "Local" is
+// the server's own zone, not a name a user can mean.
+func isTimezone(fl validator.FieldLevel) bool {
+	name := fl.Field().String()
+	if name == "" || name == "Local" {
+		return false
+	}
+	_, err := time.LoadLocation(name)
+	return err == nil
+}
@@ -0,0 +1,93 @@
+package validator
+
+import (
+	"errors"
+	"testing"
+
+	errs "github.com/infosec554/clean-archtectura/domain"
+	"github.com/infosec554/clean-archtectura/pkg/i18n"
+)
+
+func TestRules(t *testing.T) {
+	v := New()
+
+	tests := []struct {
+		rule  string
+		value string
+		valid bool
+	}{
+		{"phone", "+998901234567", true},
+		{"phone", "998901234567", false},
+		{"phone", "+99890123456", false},
+		{"phone", "+9989012345678", false},
+		{"phone", "+79011234567", false},
+		{"phone", "+998 90 123 45 67", false},
+		{"phone", "+998٩٠١٢٣٤٥٦٧", false},
+
+		{"pinfl", "31234567890123", true},
+		{"pinfl", "61234567890123", true},
+		{"pinfl", "01234567890123", false},
+		{"pinfl", "71234567890123", false},
+		{"pinfl", "3123456789012", false},
+		{"pinfl", "312345678901234", false},
+		{"pinfl", "3123456789012a", false},
+
+		{"strongpassword", "Secret-123", true},
+		{"strongpassword", "Пароль-123", true},
+		{"strongpassword", "Sec-12a", false},
+		{"strongpassword", "secret-123", false},
+		{"strongpassword", "SECRET-123", false},
+		{"strongpassword", "Secret-abc", false},
+		{"strongpassword", "Secret1234", false},
+		{"strongpassword", "", false},
+
+		{"timezone", "Asia/Tashkent", true},
+		{"timezone", "UTC", true},
+		{"timezone", "", false},
+		{"timezone", "Local", false},
+		{"timezone", "Asia/Nowhere", false},
+		{"timezone", "+05:00", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule+"/"+tt.value, func(t *testing.T) {
+			err := v.validate.Var(tt.value, tt.rule)
+			if (err == nil) != tt.valid {
+				t.Errorf("%s(%q) = %v, want valid %t", tt.rule, tt.value, err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	type request struct {
+		Phone    string `json:"phone" validate:"required,phone"`
+		Name     string `json:"first_name" validate:"min=2"`
+		Timezone string `json:"timezone" validate:"timezone"`
+	}
+
+	err := Translate(New().Validate(request{Phone: "123", Name: "A", Timezone: "Asia/Tashkent"}), i18n.En)
+	if !errors.Is(err, errs.ErrValidation) {
+		t.Fatalf("Translate = %v, want a validation error", err)
+	}
+
+	var de *errs.Error
+	if !errors.As(err, &de) {
+		t.Fatalf("Translate = %T, want *errs.Error", err)
+	}
+	want := map[string]string{
+		"phone":      "phone must be a phone number in the format +998XXXXXXXXX",
+		"first_name": "first_name must be at least 2 characters long",
+	}
+	if len(de.Fields) != len(want) {
+		t.Fatalf("fields = %+v, want %d", de.Fields, len(want))
+	}
+	for _, f := range de.Fields {
+		if f.Message != want[f.Field] {
+			t.Errorf("%s: message %q, want %q", f.Field, f.Message, want[f.Field])
+		}
+	}
+
+	if err := Translate(errors.New("bad json"), i18n.En); !errors.Is(err, errs.ErrBadParamInput) {
+		t.Errorf("Translate(non-validation) = %v, want bad request", err)
+	}
+}
@@ -0,0 +1,456 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+
+	"github.com/infosec554/clean-archtectura/config"
+	errs "github.com/infosec554/clean-archtectura/domain"
+	jobs "github.com/infosec554/clean-archtectura/domain/jobs"
+	domain "github.com/infosec554/clean-archtectura/domain/users"
+	"github.com/infosec554/clean-archtectura/pkg/email"
+	"github.com/infosec554/clean-archtectura/pkg/rand"
+	"github.com/infosec554/clean-archtectura/pkg/tracing"
+)
+
+// staleAfter is how long an export may stay pending or processing before
+// the janitor gives up on it. It is well past the time its build job takes
+// to use up its attempts, so only exports whose job is gone are reclaimed.
+const staleAfter = time.Hour
+
+// BuildExport is the payload of BuildExportJob
+type BuildExport struct {
+	ExportID uuid.UUID `json:"export_id"`
+}
+
+// BuildExportJob builds an export archive and emails its link
+var BuildExportJob = jobs.Kind[BuildExport]{
+	Name:        "export.build",
+	Queue:       jobs.DefaultQueue,
+	MaxAttempts: 3,
+}
+
+// Contributor supplies one module's share of a user's personal data.
+// Every module that stores per-user records registers a contributor,
+// and its data ends up as <Name()>.json inside the export archive:
+// user.json, audit_log.json and data_exports.json today.
+type Contributor interface {
+	Name() string
+	Collect(ctx context.Context, userID uuid.UUID) (any, error)
+}
+
+type DataExportRepository interface {
+	Create(ctx context.Context, userID uuid.UUID) (domain.DataExport, error)
+	GetByID(ctx context.Context, id uuid.UUID) (domain.DataExport, error)
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]domain.DataExport, error)
+	ListExpired(ctx context.Context, now time.Time) ([]domain.DataExport, error)
+	ListStale(ctx context.Context, olderThan time.Duration) ([]domain.DataExport, error)
+	SetStatus(ctx context.Context, id uuid.UUID, status domain.ExportStatus, errMsg string) error
+	MarkReady(ctx context.Context, id uuid.UUID, filePath, tokenHash string, expiresAt time.Time) error
+}
+
+type UserRepository interface {
+	GetByID(ctx context.Context, id uuid.UUID) (domain.User, error)
+}
+
+// FileStorage keeps the generated archives until they expire
+type FileStorage interface {
+	Put(ctx context.Context, name string, r io.Reader) (string, error)
+	Open(ctx context.Context, name string) (io.ReadCloser, error)
+	Remove(ctx context.Context, name string) error
+}
+
+// UnitOfWork runs fn in a transaction; repository calls made with the
+// context passed to fn are part of it
+type UnitOfWork interface {
+	WithTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// JobQueue enqueues background jobs; inside a transaction the job commits
+// with it
+type JobQueue interface {
+	Enqueue(ctx context.Context, job jobs.NewJob) (bool, error)
+}
+
+// Mailer delivers the download link of a finished export
+type Mailer interface {
+	SendDataExportLink(ctx context.Context, to email.Recipient, link string, expiresAt time.Time) error
+}
+
+type ExportService struct {
+	repo        DataExportRepository
+	users       UserRepository
+	storage     FileStorage
+	uow         UnitOfWork
+	jobs        JobQueue
+	emailSender Mailer
+	logger      zerolog.Logger
+	baseURL     string
+	ttl         time.Duration
+
+	mu           sync.RWMutex
+	contributors []Contributor
+}
+
+func NewExportService(repo DataExportRepository, users UserRepository, storage FileStorage, uow UnitOfWork, jobQueue JobQueue, cfg config.Config, logger zerolog.Logger) *ExportService {
+	s := &ExportService{
+		repo:        repo,
+		users:       users,
+		storage:     storage,
+		uow:         uow,
+		jobs:        jobQueue,
+		emailSender: email.NewSender(cfg),
+		logger:      logger.With().Str("service", "export").Logger(),
+		baseURL:     strings.TrimRight(cfg.AppBaseURL, "/"),
+		ttl:         cfg.ExportTTL,
+	}
+	s.Register(s)
+	return s
+}
+
+// Register adds contributors whose data is included in every export
+func (s *ExportService) Register(contributors ...Contributor) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.contributors = append(s.contributors, contributors...)
+}
+
+// Request starts an asynchronous export for the user.
+// If an export is already in progress it is returned instead of starting a new one.
+func (s *ExportService) Request(ctx context.Context, userID uuid.UUID) (_ domain.DataExportResponse, err error) {
+	ctx, span := tracing.Start(ctx, "ExportService.Request")
+	defer tracing.End(span, &err)
+
+	// The build job commits with the export, so a restart cannot leave an
+	// export that nothing will build
+	var export domain.DataExport
+	err = s.uow.WithTx(ctx, func(ctx context.Context) error {
+		active, found, err := s.active(ctx, userID)
+		if err != nil || found {
+			export = active
+			return err
+		}
+
+		export, err = s.repo.Create(ctx, userID)
+		if errors.Is(err, errs.ErrConflict) {
+			// A concurrent request created one after the check above
+			if export, found, err = s.active(ctx, userID); err == nil && !found {
+				err = errs.Conflict("data export already in progress")
+			}
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		job, err := BuildExportJob.New(BuildExport{ExportID: export.ID}, jobs.Unique("export:"+export.ID.String()))
+		if err != nil {
+			return err
+		}
+		_, err = s.jobs.Enqueue(ctx, job)
+		return err
+	})
+	if err != nil {
+		tracing.RecordError(span, err)
+		return domain.DataExportResponse{}, err
+	}
+
+	return convertToExportResponse(export), nil
+}
+
+// List returns the user's export jobs
+func (s *ExportService) List(ctx context.Context, userID uuid.UUID) ([]domain.DataExportResponse, error) {
+	list, err := s.repo.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := make([]domain.DataExportResponse, 0, len(list))
+	for _, e := range list {
+		resp = append(resp, convertToExportResponse(e))
+	}
+	return resp, nil
+}
+
+// Download checks the link token and opens the export archive
+func (s *ExportService) Download(ctx context.Context, id uuid.UUID, token string) (io.ReadCloser, string, error) {
+	export, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if export.Status != domain.ExportStatusReady || export.TokenHash == "" {
+		return nil, "", errs.NotFound("data export not found")
+	}
+	if subtle.ConstantTimeCompare([]byte(hashToken(token)), []byte(export.TokenHash)) != 1 {
+		return nil, "", errs.NotFound("data export not found")
+	}
+	if export.ExpiresAt == nil || time.Now().After(*export.ExpiresAt) {
+		return nil, "", errs.Gone("download link expired")
+	}
+
+	f, err := s.storage.Open(ctx, export.FilePath)
+	if err != nil {
+		return nil, "", err
+	}
+	return f, archiveName(export), nil
+}
+
+// Build handles BuildExportJob. An export that is no longer pending or
+// processing was finished by an earlier attempt or given up by the janitor.
+func (s *ExportService) Build(ctx context.Context, p BuildExport) (err error) {
+	ctx, span := tracing.Start(ctx, "ExportService.Build")
+	defer tracing.End(span, &err)
+
+	export, err := s.repo.GetByID(ctx, p.ExportID)
+	if errors.Is(err, errs.ErrNotFound) {
+		// The user was deleted, and their exports with them
+		return nil
+	}
+	if err != nil {
+		tracing.RecordError(span, err)
+		return err
+	}
+	if export.Status != domain.ExportStatusPending && export.Status != domain.ExportStatusProcessing {
+		return nil
+	}
+
+	logger := s.logger.With().Ctx(ctx).Str("export_id", export.ID.String()).Str("user_id", export.UserID.String()).Logger()
+
+	err = s.build(ctx, export.ID, export.UserID)
+	if errors.Is(err, errNoRecipient) {
+		logger.Warn().Msg("Data export has no recipient")
+		return s.fail(ctx, export.ID, err)
+	}
+	if err != nil {
+		// The job retries; the janitor fails the export if it never finishes
+		logger.Warn().Err(err).Msg("Data export attempt failed")
+		tracing.RecordError(span, err)
+		return err
+	}
+
+	logger.Info().Msg("Data export ready")
+	return nil
+}
+
+// PurgeExpired removes archives whose download window has passed
+func (s *ExportService) PurgeExpired(ctx context.Context) error {
+	list, err := s.repo.ListExpired(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+
+	for _, e := range list {
+		if err := s.storage.Remove(ctx, e.FilePath); err != nil {
+			s.logger.Warn().Ctx(ctx).Err(err).Str("export_id", e.ID.String()).Msg("Failed to remove expired export")
+			continue
+		}
+		if err := s.repo.SetStatus(ctx, e.ID, domain.ExportStatusExpired, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReclaimStale fails exports that have been pending or processing for
+// longer than their build can take, which happens when the build job ran
+// out of attempts, and removes whatever archive they left behind. Without
+// this such an export would block new ones for its user.
+func (s *ExportService) ReclaimStale(ctx context.Context) error {
+	list, err := s.repo.ListStale(ctx, staleAfter)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range list {
+		if err := s.storage.Remove(ctx, archiveFile(e.ID)); err != nil {
+			s.logger.Warn().Ctx(ctx).Err(err).Str("export_id", e.ID.String()).Msg("Failed to remove abandoned export")
+			continue
+		}
+		if err := s.fail(ctx, e.ID, errors.New("export did not finish")); err != nil {
+			return err
+		}
+		s.logger.Warn().Ctx(ctx).Str("export_id", e.ID.String()).Msg("Reclaimed abandoned data export")
+	}
+	return nil
+}
+
+// RunJanitor purges expired exports and reclaims abandoned ones every
+// interval until ctx is done
+func (s *ExportService) RunJanitor(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := s.PurgeExpired(ctx); err != nil {
+				s.logger.Error().Ctx(ctx).Err(err).Msg("Failed to purge expired exports")
+			}
+			if err := s.ReclaimStale(ctx); err != nil {
+				s.logger.Error().Ctx(ctx).Err(err).Msg("Failed to reclaim abandoned exports")
+			}
+		}
+	}
+}
+
+// Name implements Contributor: the export history is itself per-user data
+func (s *ExportService) Name() string {
+	return "data_exports"
+}
+
+// Collect implements Contributor
+func (s *ExportService) Collect(ctx context.Context, userID uuid.UUID) (any, error) {
+	return s.List(ctx, userID)
+}
+
+// --- helpers ---
+
+// active returns the user's export in progress, if there is one
+func (s *ExportService) active(ctx context.Context, userID uuid.UUID) (domain.DataExport, bool, error) {
+	list, err := s.repo.ListByUser(ctx, userID)
+	if err != nil {
+		return domain.DataExport{}, false, err
+	}
+	for _, e := range list {
+		if e.Status == domain.ExportStatusPending || e.Status == domain.ExportStatusProcessing {
+			return e, true, nil
+		}
+	}
+	return domain.DataExport{}, false, nil
+}
+
+// errNoRecipient fails an export for good; retrying does not give the user an email
+var errNoRecipient = errors.New("user has no email to deliver the export to")
+
+func (s *ExportService) fail(ctx context.Context, exportID uuid.UUID, cause error) error {
+	return s.repo.SetStatus(ctx, exportID, domain.ExportStatusFailed, cause.Error())
+}
+
+// build may run more than once for an export: the archive is overwritten
+// and every attempt mails a link of its own. The link is stored only after
+// it was sent, so a failed attempt leaves no working link behind.
+func (s *ExportService) build(ctx context.Context, exportID, userID uuid.UUID) error {
+	if err := s.repo.SetStatus(ctx, exportID, domain.ExportStatusProcessing, ""); err != nil {
+		return err
+	}
+
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.Email == nil || *user.Email == "" {
+		return errNoRecipient
+	}
+
+	archive, err := s.archive(ctx, exportID, userID)
+	if err != nil {
+		return err
+	}
+
+	path, err := s.storage.Put(ctx, archiveFile(exportID), archive)
+	if err != nil {
+		return fmt.Errorf("store archive: %w", err)
+	}
+
+	token, err := rand.SecureToken(32)
+	if err != nil {
+		return err
+	}
+	expiresAt := time.Now().Add(s.ttl)
+
+	link := fmt.Sprintf("%s/api/v1/exports/%s/download?token=%s", s.baseURL, exportID, token)
+	to := email.Recipient{Email: *user.Email, Preferences: user.Preferences}
+	if err := s.emailSender.SendDataExportLink(ctx, to, link, expiresAt); err != nil {
+		return fmt.Errorf("send export email: %w", err)
+	}
+
+	return s.repo.MarkReady(ctx, exportID, path, hashToken(token), expiresAt)
+}
+
+func (s *ExportService) archive(ctx context.Context, exportID, userID uuid.UUID) (io.Reader, error) {
+	s.mu.RLock()
+	contributors := append([]Contributor(nil), s.contributors...)
+	s.mu.RUnlock()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	files := make([]string, 0, len(contributors))
+	for _, c := range contributors {
+		data, err := c.Collect(ctx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("collect %s: %w", c.Name(), err)
+		}
+
+		name := c.Name() + ".json"
+		if err := writeJSON(zw, name, data); err != nil {
+			return nil, err
+		}
+		files = append(files, name)
+	}
+
+	manifest := map[string]any{
+		"export_id":    exportID,
+		"user_id":      userID,
+		"generated_at": time.Now().UTC(),
+		"files":        files,
+	}
+	if err := writeJSON(zw, "manifest.json", manifest); err != nil {
+		return nil, err
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return &buf, nil
+}
+
+func writeJSON(zw *zip.Writer, name string, data any) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(data)
+}
+
+// archiveFile names the stored archive of an export
+func archiveFile(exportID uuid.UUID) string {
+	return exportID.String() + ".zip"
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func archiveName(export domain.DataExport) string {
+	return fmt.Sprintf("data-export-%s.zip", export.CreatedAt.UTC().Format("20060102"))
+}
+
+func convertToExportResponse(export domain.DataExport) domain.DataExportResponse {
+	return domain.DataExportResponse{
+		ID:        export.ID,
+		Status:    export.Status,
+		ExpiresAt: export.ExpiresAt,
+		CreatedAt: export.CreatedAt,
+		UpdatedAt: export.UpdatedAt,
+	}
+}
@@ -0,0 +1,351 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+
+	"github.com/infosec554/clean-archtectura/config"
+	errs "github.com/infosec554/clean-archtectura/domain"
+	jobs "github.com/infosec554/clean-archtectura/domain/jobs"
+	domain "github.com/infosec554/clean-archtectura/domain/users"
+	"github.com/infosec554/clean-archtectura/pkg/email"
+)
+
+type fakeRepo struct {
+	exports map[uuid.UUID]*domain.DataExport
+	stale   []uuid.UUID
+
+	// beforeCreate runs ahead of Create, standing in for a concurrent request
+	beforeCreate func()
+}
+
+// Create enforces one export in progress per user, like the partial unique index
+func (r *fakeRepo) Create(_ context.Context, userID uuid.UUID) (domain.DataExport, error) {
+	if r.beforeCreate != nil {
+		r.beforeCreate()
+	}
+	for _, e := range r.exports {
+		if e.UserID == userID && (e.Status == domain.ExportStatusPending || e.Status == domain.ExportStatusProcessing) {
+			return domain.DataExport{}, errs.Conflict("data export already in progress")
+		}
+	}
+	e := domain.DataExport{ID: uuid.New(), UserID: userID, Status: domain.ExportStatusPending, CreatedAt: time.Now()}
+	r.exports[e.ID] = &e
+	return e, nil
+}
+
+func (r *fakeRepo) GetByID(_ context.Context, id uuid.UUID) (domain.DataExport, error) {
+	e, ok := r.exports[id]
+	if !ok {
+		return domain.DataExport{}, errs.NotFound("data export not found")
+	}
+	return *e, nil
+}
+
+func (r *fakeRepo) ListByUser(_ context.Context, userID uuid.UUID) ([]domain.DataExport, error) {
+	var list []domain.DataExport
+	for _, e := range r.exports {
+		if e.UserID == userID {
+			list = append(list, *e)
+		}
+	}
+	return list, nil
+}
+
+func (r *fakeRepo) ListExpired(context.Context, time.Time) ([]domain.DataExport, error) {
+	return nil, nil
+}
+
+func (r *fakeRepo) ListStale(context.Context, time.Duration) ([]domain.DataExport, error) {
+	var list []domain.DataExport
+	for _, id := range r.stale {
+		list = append(list, *r.exports[id])
+	}
+	return list, nil
+}
+
+func (r *fakeRepo) SetStatus(_ context.Context, id uuid.UUID, status domain.ExportStatus, errMsg string) error {
+	r.exports[id].Status = status
+	r.exports[id].Error = errMsg
+	return nil
+}
+
+func (r *fakeRepo) MarkReady(_ context.Context, id uuid.UUID, filePath, tokenHash string, expiresAt time.Time) error {
+	e := r.exports[id]
+	e.Status, e.FilePath, e.TokenHash, e.ExpiresAt = domain.ExportStatusReady, filePath, tokenHash, &expiresAt
+	return nil
+}
+
+type fakeUsers map[uuid.UUID]domain.User
+
+func (u fakeUsers) GetByID(_ context.Context, id uuid.UUID) (domain.User, error) {
+	user, ok := u[id]
+	if !ok {
+		return domain.User{}, errs.NotFound("user not found")
+	}
+	return user, nil
+}
+
+type fakeStorage map[string][]byte
+
+func (s fakeStorage) Put(_ context.Context, name string, r io.Reader) (string, error) {
+	data, err := io.ReadAll(r)
+	s[name] = data
+	return name, err
+}
+
+func (s fakeStorage) Open(_ context.Context, name string) (io.ReadCloser, error) {
+	data, ok := s[name]
+	if !ok {
+		return nil, errors.New("no such file")
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (s fakeStorage) Remove(_ context.Context, name string) error {
+	delete(s, name)
+	return nil
+}
+
+type fakeUoW struct{}
+
+func (fakeUoW) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+type fakeQueue []jobs.NewJob
+
+func (q *fakeQueue) Enqueue(_ context.Context, job jobs.NewJob) (bool, error) {
+	*q = append(*q, job)
+	return true, nil
+}
+
+type fakeMailer struct {
+	links []string
+	err   error
+}
+
+func (m *fakeMailer) SendDataExportLink(_ context.Context, _ email.Recipient, link string, _ time.Time) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.links = append(m.links, link)
+	return nil
+}
+
+type exportTest struct {
+	svc     *ExportService
+	repo    *fakeRepo
+	storage fakeStorage
+	queue   *fakeQueue
+	mailer  *fakeMailer
+	userID  uuid.UUID
+}
+
+func newExportTest(t *testing.T) *exportTest {
+	t.Helper()
+	addr := "user@example.com"
+	tt := &exportTest{
+		repo:    &fakeRepo{exports: make(map[uuid.UUID]*domain.DataExport)},
+		storage: fakeStorage{},
+		queue:   &fakeQueue{},
+		mailer:  &fakeMailer{},
+		userID:  uuid.New(),
+	}
+	users := fakeUsers{
+		tt.userID: {ID: tt.userID, Email: &addr, Preferences: domain.DefaultPreferences()},
+	}
+	cfg := config.Config{AppBaseURL: "https://api.example.com/", ExportTTL: time.Hour}
+	tt.svc = NewExportService(tt.repo, users, tt.storage, fakeUoW{}, tt.queue, cfg, zerolog.Nop())
+	tt.svc.emailSender = tt.mailer
+	return tt
+}
+
+func (tt *exportTest) request(t *testing.T) domain.DataExportResponse {
+	t.Helper()
+	resp, err := tt.svc.Request(context.Background(), tt.userID)
+	if err != nil {
+		t.Fatalf("Request: %v", err)
+	}
+	return resp
+}
+
+func TestRequestEnqueuesOneBuild(t *testing.T) {
+	tt := newExportTest(t)
+
+	first := tt.request(t)
+	if first.Status != domain.ExportStatusPending {
+		t.Fatalf("status = %s, want pending", first.Status)
+	}
+	if len(*tt.queue) != 1 {
+		t.Fatalf("enqueued %d jobs, want 1", len(*tt.queue))
+	}
+	job := (*tt.queue)[0]
+	if job.Kind != BuildExportJob.Name || job.UniqueKey != "export:"+first.ID.String() {
+		t.Errorf("job = %+v", job)
+	}
+	payload, err := BuildExportJob.Decode(job.Payload)
+	if err != nil || payload.ExportID != first.ID {
+		t.Errorf("payload = %+v, %v", payload, err)
+	}
+
+	// An export in progress is returned instead of starting another
+	if second := tt.request(t); second.ID != first.ID || len(*tt.queue) != 1 {
+		t.Errorf("second request = %s with %d jobs, want %s with 1", second.ID, len(*tt.queue), first.ID)
+	}
+}
+
+func TestRequestConcurrent(t *testing.T) {
+	tt := newExportTest(t)
+
+	// The other request commits between this one's check and its insert
+	var concurrent domain.DataExport
+	tt.repo.beforeCreate = func() {
+		tt.repo.beforeCreate = nil
+		concurrent, _ = tt.repo.Create(context.Background(), tt.userID)
+	}
+
+	resp := tt.request(t)
+	if resp.ID != concurrent.ID {
+		t.Fatalf("request = %s, want the concurrent export %s", resp.ID, concurrent.ID)
+	}
+	if len(*tt.queue) != 0 {
+		t.Fatalf("enqueued %d jobs, want the concurrent request's only", len(*tt.queue))
+	}
+	if n := len(tt.repo.exports); n != 1 {
+		t.Fatalf("%d exports, want 1", n)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	tt := newExportTest(t)
+	ctx := context.Background()
+	export := tt.request(t)
+
+	if err := tt.svc.Build(ctx, BuildExport{ExportID: export.ID}); err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	stored := tt.repo.exports[export.ID]
+	if stored.Status != domain.ExportStatusReady {
+		t.Fatalf("status = %s, want ready", stored.Status)
+	}
+	if len(tt.mailer.links) != 1 {
+		t.Fatalf("sent %d links, want 1", len(tt.mailer.links))
+	}
+	link, err := url.Parse(tt.mailer.links[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "/api/v1/exports/" + export.ID.String() + "/download"; link.Path != want {
+		t.Errorf("link path = %s, want %s", link.Path, want)
+	}
+
+	// The mailed token opens the archive, which holds every contributor
+	r, _, err := tt.svc.Download(ctx, export.ID, link.Query().Get("token"))
+	if err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+	data, _ := io.ReadAll(r)
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	if got := strings.Join(names, ","); got != "data_exports.json,manifest.json" {
+		t.Errorf("archive files = %s", got)
+	}
+
+	if _, _, err := tt.svc.Download(ctx, export.ID, "wrong"); !errors.Is(err, errs.ErrNotFound) {
+		t.Errorf("Download with a wrong token = %v, want not found", err)
+	}
+
+	// A redelivered job leaves a finished export alone
+	if err := tt.svc.Build(ctx, BuildExport{ExportID: export.ID}); err != nil || len(tt.mailer.links) != 1 {
+		t.Errorf("rebuild = %v with %d links, want no-op", err, len(tt.mailer.links))
+	}
+}
+
+func TestBuildRetriesFailedEmail(t *testing.T) {
+	tt := newExportTest(t)
+	ctx := context.Background()
+	export := tt.request(t)
+
+	tt.mailer.err = errors.New("brevo down")
+	if err := tt.svc.Build(ctx, BuildExport{ExportID: export.ID}); err == nil {
+		t.Fatal("Build succeeded while email is down")
+	}
+	if stored := tt.repo.exports[export.ID]; stored.Status != domain.ExportStatusProcessing || stored.TokenHash != "" {
+		t.Fatalf("after failed attempt: status %s, token stored %t", stored.Status, stored.TokenHash != "")
+	}
+
+	tt.mailer.err = nil
+	if err := tt.svc.Build(ctx, BuildExport{ExportID: export.ID}); err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	if stored := tt.repo.exports[export.ID]; stored.Status != domain.ExportStatusReady {
+		t.Errorf("status after retry = %s, want ready", stored.Status)
+	}
+}
+
+func TestBuildWithoutEmailFails(t *testing.T) {
+	tt := newExportTest(t)
+	export := tt.request(t)
+	tt.svc.users = fakeUsers{tt.userID: {ID: tt.userID}}
+
+	if err := tt.svc.Build(context.Background(), BuildExport{ExportID: export.ID}); err != nil {
+		t.Fatalf("Build = %v, want nil so the job is not retried", err)
+	}
+	if stored := tt.repo.exports[export.ID]; stored.Status != domain.ExportStatusFailed {
+		t.Errorf("status = %s, want failed", stored.Status)
+	}
+}
+
+func TestBuildOfDeletedExport(t *testing.T) {
+	tt := newExportTest(t)
+	if err := tt.svc.Build(context.Background(), BuildExport{ExportID: uuid.New()}); err != nil {
+		t.Errorf("Build = %v, want nil", err)
+	}
+}
+
+func TestReclaimStale(t *testing.T) {
+	tt := newExportTest(t)
+	ctx := context.Background()
+	export := tt.request(t)
+
+	// A build that stored its archive but never finished
+	tt.mailer.err = errors.New("brevo down")
+	_ = tt.svc.Build(ctx, BuildExport{ExportID: export.ID})
+	if _, ok := tt.storage[archiveFile(export.ID)]; !ok {
+		t.Fatal("archive not stored")
+	}
+
+	tt.repo.stale = []uuid.UUID{export.ID}
+	if err := tt.svc.ReclaimStale(ctx); err != nil {
+		t.Fatalf("ReclaimStale: %v", err)
+	}
+	if _, ok := tt.storage[archiveFile(export.ID)]; ok {
+		t.Error("abandoned archive not removed")
+	}
+	if stored := tt.repo.exports[export.ID]; stored.Status != domain.ExportStatusFailed {
+		t.Errorf("status = %s, want failed", stored.Status)
+	}
+
+	// The user can ask again
+	if next := tt.request(t); next.ID == export.ID {
+		t.Error("reclaimed export still blocks new requests")
+	}
+}
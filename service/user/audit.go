@@ -0,0 +1,86 @@
+package user
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	domain "github.com/infosec554/clean-archtectura/domain/users"
+	"github.com/infosec554/clean-archtectura/pkg/tracing"
+)
+
+// AuditLog reads the audit trail
+type AuditLog interface {
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]domain.AuditEntry, error)
+}
+
+// AuditTrail contributes the audit entries about a user to their data
+// export: what they did, and what staff did to them or while acting as
+// them. Staff are not named, and their IP address and user agent are left
+// out, as those are the staff member's personal data.
+type AuditTrail struct {
+	log AuditLog
+}
+
+func NewAuditTrail(log AuditLog) AuditTrail {
+	return AuditTrail{log: log}
+}
+
+// auditRecord is an audit entry as exported: the parties are described
+// relative to the user instead of by ID
+type auditRecord struct {
+	Action     string    `json:"action"`
+	By         string    `json:"by"`
+	About      string    `json:"about,omitempty"`
+	Reason     string    `json:"reason,omitempty"`
+	Method     string    `json:"method,omitempty"`
+	Path       string    `json:"path,omitempty"`
+	StatusCode int       `json:"status_code,omitempty"`
+	IP         string    `json:"ip,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Name implements export.Contributor
+func (AuditTrail) Name() string {
+	return "audit_log"
+}
+
+// Collect implements export.Contributor
+func (a AuditTrail) Collect(ctx context.Context, userID uuid.UUID) (_ any, err error) {
+	ctx, span := tracing.Start(ctx, "AuditTrail.Collect")
+	defer tracing.End(span, &err)
+
+	entries, err := a.log.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]auditRecord, 0, len(entries))
+	for _, e := range entries {
+		rec := auditRecord{
+			Action:     e.Action,
+			By:         "you",
+			Reason:     e.Reason,
+			Method:     e.Method,
+			Path:       e.Path,
+			StatusCode: e.StatusCode,
+			CreatedAt:  e.CreatedAt,
+		}
+		if e.ActorID == userID {
+			rec.IP, rec.UserAgent = e.IP, e.UserAgent
+		} else {
+			rec.By = "staff"
+		}
+		switch {
+		case e.SubjectID == nil:
+		case *e.SubjectID == userID:
+			rec.About = "you"
+		default:
+			rec.About = "another user"
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
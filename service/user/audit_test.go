@@ -0,0 +1,64 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	domain "github.com/infosec554/clean-archtectura/domain/users"
+)
+
+type fakeAuditLog []domain.AuditEntry
+
+func (l fakeAuditLog) ListByUser(_ context.Context, userID uuid.UUID) ([]domain.AuditEntry, error) {
+	var list []domain.AuditEntry
+	for _, e := range l {
+		if e.ActorID == userID || (e.SubjectID != nil && *e.SubjectID == userID) {
+			list = append(list, e)
+		}
+	}
+	return list, nil
+}
+
+func TestAuditTrailCollect(t *testing.T) {
+	user, admin, other := uuid.New(), uuid.New(), uuid.New()
+	now := time.Now()
+	log := fakeAuditLog{
+		{ActorID: admin, SubjectID: &user, Action: domain.AuditActionImpersonationStart, Reason: "ticket 42", IP: "10.0.0.9", UserAgent: "staff-browser", CreatedAt: now},
+		{ActorID: user, SubjectID: &other, Action: "user.ban", IP: "203.0.113.7", UserAgent: "my-browser", CreatedAt: now},
+		{ActorID: admin, SubjectID: &other, Action: "user.ban", CreatedAt: now},
+	}
+
+	got, err := NewAuditTrail(log).Collect(context.Background(), user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := got.([]auditRecord)
+	if len(records) != 2 {
+		t.Fatalf("records = %+v, want the two entries involving the user", records)
+	}
+
+	staff := records[0]
+	if staff.By != "staff" || staff.About != "you" || staff.Reason != "ticket 42" {
+		t.Errorf("staff entry = %+v", staff)
+	}
+	if staff.IP != "" || staff.UserAgent != "" {
+		t.Errorf("staff entry exposes the staff member's IP or user agent: %+v", staff)
+	}
+
+	own := records[1]
+	if own.By != "you" || own.About != "another user" || own.IP != "203.0.113.7" || own.UserAgent != "my-browser" {
+		t.Errorf("own entry = %+v", own)
+	}
+
+	data, _ := json.Marshal(records)
+	for _, id := range []uuid.UUID{admin, other} {
+		if strings.Contains(string(data), id.String()) {
+			t.Errorf("export names %s", id)
+		}
+	}
+}
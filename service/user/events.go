@@ -0,0 +1,31 @@
+package user
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	outbox "github.com/infosec554/clean-archtectura/domain/outbox"
+	domain "github.com/infosec554/clean-archtectura/domain/users"
+	"github.com/infosec554/clean-archtectura/pkg/email"
+)
+
+// Registered is the payload of UserRegistered
+type Registered struct {
+	UserID      string             `json:"user_id"`
+	Email       string             `json:"email"`
+	Preferences domain.Preferences `json:"preferences"`
+}
+
+// UserRegistered is published through the outbox when an account is created
+var UserRegistered = outbox.Topic[Registered]{Name: "user.registered"}
+
+// OnRegistered subscribes to UserRegistered and queues the verification
+// email. The job is enqueued in the relay's transaction, so a redelivered
+// event does not queue a second one.
+func (s *UserService) OnRegistered(ctx context.Context, _ uuid.UUID, e Registered) error {
+	if e.Email == "" {
+		return nil
+	}
+	return s.enqueueVerificationEmail(ctx, email.Recipient{Email: e.Email, Preferences: e.Preferences})
+}
@@ -0,0 +1,156 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+
+	jobs "github.com/infosec554/clean-archtectura/domain/jobs"
+	outbox "github.com/infosec554/clean-archtectura/domain/outbox"
+	"github.com/infosec554/clean-archtectura/internal/workers"
+)
+
+type pendingKey struct{}
+
+// txJobs stands in for the database: jobs enqueued in a transaction are
+// kept only if it commits, and a failing savepoint drops only its own
+type txJobs struct {
+	mu        sync.Mutex
+	committed []jobs.NewJob
+}
+
+func (q *txJobs) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	var pending []jobs.NewJob
+	if err := fn(context.WithValue(ctx, pendingKey{}, &pending)); err != nil {
+		return err
+	}
+	if outer, ok := ctx.Value(pendingKey{}).(*[]jobs.NewJob); ok {
+		*outer = append(*outer, pending...)
+		return nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.committed = append(q.committed, pending...)
+	return nil
+}
+
+func (q *txJobs) Enqueue(ctx context.Context, job jobs.NewJob) (bool, error) {
+	pending, ok := ctx.Value(pendingKey{}).(*[]jobs.NewJob)
+	if !ok {
+		return false, errors.New("enqueue outside a transaction")
+	}
+	*pending = append(*pending, job)
+	return true, nil
+}
+
+func (q *txJobs) enqueued() []jobs.NewJob {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]jobs.NewJob(nil), q.committed...)
+}
+
+// flakyOutbox holds one event and fails to mark it delivered the first time,
+// after its subscribers ran
+type flakyOutbox struct {
+	mu        sync.Mutex
+	event     outbox.Event
+	failures  int
+	delivered chan struct{}
+}
+
+func (o *flakyOutbox) Pending(context.Context, int) ([]outbox.Event, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	select {
+	case <-o.delivered:
+		return nil, nil
+	default:
+		return []outbox.Event{o.event}, nil
+	}
+}
+
+func (o *flakyOutbox) MarkDelivered(context.Context, uuid.UUID) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.failures == 0 {
+		o.failures++
+		return errors.New("connection reset")
+	}
+	close(o.delivered)
+	return nil
+}
+
+func (o *flakyOutbox) MarkFailed(context.Context, uuid.UUID, time.Duration, string) error {
+	return nil
+}
+
+func (o *flakyOutbox) MarkDead(context.Context, uuid.UUID, string) error {
+	return nil
+}
+
+func (o *flakyOutbox) Purge(context.Context, time.Duration) (int64, error) {
+	return 0, nil
+}
+
+type silentNotifier struct{}
+
+func (silentNotifier) Listen(ctx context.Context, _ string, _ func(string)) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestOnRegisteredRedelivery(t *testing.T) {
+	event, err := UserRegistered.New(Registered{UserID: uuid.NewString(), Email: "aziz@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &flakyOutbox{
+		event:     outbox.Event{ID: uuid.New(), Topic: event.Topic, Payload: event.Payload},
+		delivered: make(chan struct{}),
+	}
+	queue := &txJobs{}
+	svc := &UserService{jobs: queue, logger: zerolog.Nop()}
+
+	relay := workers.NewRelay(store, queue, silentNotifier{}, "outbox", 10*time.Millisecond, 10, time.Hour, 5, zerolog.Nop())
+	workers.Subscribe(relay, UserRegistered, svc.OnRegistered)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- relay.Run(ctx) }()
+
+	select {
+	case <-store.delivered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("event was not redelivered")
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// The first delivery ran OnRegistered, but its transaction rolled back
+	got := queue.enqueued()
+	if len(got) != 1 {
+		t.Fatalf("enqueued %d jobs across two deliveries, want 1", len(got))
+	}
+	if got[0].Kind != VerificationEmailJob.Name || got[0].UniqueKey != "verification_email:aziz@example.com" {
+		t.Fatalf("job = %+v", got[0])
+	}
+}
+
+func TestOnRegisteredWithoutEmail(t *testing.T) {
+	queue := &txJobs{}
+	svc := &UserService{jobs: queue, logger: zerolog.Nop()}
+
+	err := queue.WithTx(context.Background(), func(ctx context.Context) error {
+		return svc.OnRegistered(ctx, uuid.New(), Registered{UserID: uuid.NewString()})
+	})
+	if err != nil || len(queue.enqueued()) != 0 {
+		t.Fatalf("OnRegistered without an email = %v, %d jobs", err, len(queue.enqueued()))
+	}
+}
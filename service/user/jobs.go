@@ -0,0 +1,88 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	errs "github.com/infosec554/clean-archtectura/domain"
+	jobs "github.com/infosec554/clean-archtectura/domain/jobs"
+	domain "github.com/infosec554/clean-archtectura/domain/users"
+	"github.com/infosec554/clean-archtectura/pkg/email"
+	"github.com/infosec554/clean-archtectura/pkg/tracing"
+)
+
+// VerificationEmail is the payload of VerificationEmailJob
+type VerificationEmail struct {
+	Email       string             `json:"email"`
+	Preferences domain.Preferences `json:"preferences"`
+}
+
+// VerificationEmailJob sends a new verification code. The code is created
+// when the email goes out, so a retried job never sends an expired one.
+var VerificationEmailJob = jobs.Kind[VerificationEmail]{
+	Name:        "user.verification_email",
+	Queue:       "email",
+	MaxAttempts: 8,
+}
+
+// SendVerificationEmail handles VerificationEmailJob
+func (s *UserService) SendVerificationEmail(ctx context.Context, p VerificationEmail) (err error) {
+	ctx, span := tracing.Start(ctx, "UserService.SendVerificationEmail")
+	defer tracing.End(span, &err)
+
+	return s.sendCode(ctx, email.Recipient{Email: p.Email, Preferences: p.Preferences})
+}
+
+// enqueueVerificationEmail schedules a verification email; while one is
+// pending for the address, asking again does not queue another
+func (s *UserService) enqueueVerificationEmail(ctx context.Context, to email.Recipient) error {
+	job, err := VerificationEmailJob.New(
+		VerificationEmail{Email: to.Email, Preferences: to.Preferences},
+		jobs.Unique("verification_email:"+to.Email),
+	)
+	if err != nil {
+		return err
+	}
+	_, err = s.jobs.Enqueue(ctx, job)
+	return err
+}
+
+// AccountStatusEmail is the payload of AccountStatusEmailJob
+type AccountStatusEmail struct {
+	UserID uuid.UUID            `json:"user_id"`
+	Status domain.AccountStatus `json:"status"`
+	Reason string               `json:"reason,omitempty"`
+	Until  *time.Time           `json:"until,omitempty"`
+}
+
+// AccountStatusEmailJob tells a user their account status changed
+var AccountStatusEmailJob = jobs.Kind[AccountStatusEmail]{
+	Name:        "user.account_status_email",
+	Queue:       "email",
+	MaxAttempts: 8,
+}
+
+// SendAccountStatusEmail handles AccountStatusEmailJob. The user is read when
+// the email goes out, so a recipient who opted out in the meantime gets
+// nothing, and neither does one whose status has changed again.
+func (s *UserService) SendAccountStatusEmail(ctx context.Context, p AccountStatusEmail) (err error) {
+	ctx, span := tracing.Start(ctx, "UserService.SendAccountStatusEmail")
+	defer tracing.End(span, &err)
+
+	user, err := s.repo.GetByID(ctx, p.UserID)
+	if errors.Is(err, errs.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if user.Email == nil || user.Status != p.Status {
+		return nil
+	}
+
+	to := email.Recipient{Email: *user.Email, Preferences: user.Preferences}
+	return s.emailSender.SendAccountStatus(ctx, to, p.Status, p.Reason, p.Until)
+}
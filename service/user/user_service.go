@@ -2,115 +2,215 @@ package user
 
 import (
 	"context"
+	"crypto/subtle"
 	"errors"
 	"fmt"
 	"math/rand"
+	"slices"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 	"github.com/infosec554/clean-archtectura/config"
+	errs "github.com/infosec554/clean-archtectura/domain"
+	jobs "github.com/infosec554/clean-archtectura/domain/jobs"
+	outbox "github.com/infosec554/clean-archtectura/domain/outbox"
 	"github.com/infosec554/clean-archtectura/pkg/cache"
 	"github.com/infosec554/clean-archtectura/pkg/email"
+	"github.com/infosec554/clean-archtectura/pkg/i18n"
+	"github.com/infosec554/clean-archtectura/pkg/metrics"
 	"github.com/rs/zerolog"
 
 	domain "github.com/infosec554/clean-archtectura/domain/users"
 	"github.com/infosec554/clean-archtectura/pkg/token"
+	"github.com/infosec554/clean-archtectura/pkg/tracing"
 )
 
-const verifyCodeTTL = 5 * time.Minute
+const (
+	verifyCodeTTL    = 5 * time.Minute
+	accountStatusTTL = time.Minute
+
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
 
 type UserRepository interface {
 	GetByEmail(ctx context.Context, email string) (domain.User, error)
+	// GetCredentials is GetByEmail with the password hash, read from the
+	// primary and never from a cache
+	GetCredentials(ctx context.Context, email string) (domain.User, error)
 	GetByID(ctx context.Context, id uuid.UUID) (domain.User, error)
+	// GetByIDForUpdate locks the user until the transaction in ctx ends
+	GetByIDForUpdate(ctx context.Context, id uuid.UUID) (domain.User, error)
 	Create(ctx context.Context, req *domain.CreateUser) (string, error)
 	Update(ctx context.Context, req *domain.UpdateUser, passwordHash string) (string, error)
 	Delete(ctx context.Context, id uuid.UUID) error
 	SetEmailVerified(ctx context.Context, email string) error
+	SetStatus(ctx context.Context, change domain.StatusChange) error
+	Search(ctx context.Context, text string, limit, offset int) ([]domain.UserSearchHit, int, error)
+	MergePreferences(ctx context.Context, id uuid.UUID, patch domain.UpdatePreferencesRequest) (domain.Preferences, error)
+}
+
+type AuditRepository interface {
+	Create(ctx context.Context, entry domain.AuditEntry) error
+}
+
+// UnitOfWork runs fn in a transaction; repository calls made with the
+// context passed to fn are part of it
+type UnitOfWork interface {
+	WithTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// JobQueue enqueues background jobs; inside a transaction the job commits
+// with it
+type JobQueue interface {
+	Enqueue(ctx context.Context, job jobs.NewJob) (bool, error)
+}
+
+// Outbox records events to publish once the transaction in the context
+// commits
+type Outbox interface {
+	Add(ctx context.Context, event outbox.NewEvent) error
 }
 
 type UserService struct {
-	repo       UserRepository
-	cache      cache.ICache
-	emailSender *email.Sender
-	logger     zerolog.Logger
-	jwtManager *token.JWTManager
+	repo             UserRepository
+	audit            AuditRepository
+	uow              UnitOfWork
+	jobs             JobQueue
+	outbox           Outbox
+	impersonationTTL time.Duration
+	cache            cache.ICache
+	emailSender      *email.Sender
+	logger           zerolog.Logger
+	jwtManager       *token.JWTManager
 }
 
-func NewUserService(repo UserRepository, cfg config.Config, c cache.ICache, logger zerolog.Logger, jwtManager *token.JWTManager) *UserService {
+func NewUserService(repo UserRepository, audit AuditRepository, uow UnitOfWork, jobQueue JobQueue, events Outbox, cfg config.Config, c cache.ICache, logger zerolog.Logger, jwtManager *token.JWTManager) *UserService {
 	return &UserService{
-		repo:        repo,
-		cache:       c,
-		emailSender: email.NewSender(cfg),
-		logger:      logger.With().Str("service", "user").Logger(),
-		jwtManager:  jwtManager,
+		repo:             repo,
+		audit:            audit,
+		uow:              uow,
+		jobs:             jobQueue,
+		outbox:           events,
+		impersonationTTL: cfg.ImpersonationTTL,
+		cache:            c,
+		emailSender:      email.NewSender(cfg),
+		logger:           logger.With().Str("service", "user").Logger(),
+		jwtManager:       jwtManager,
 	}
 }
 
 // Register creates a new user and sends email verification code
-func (s *UserService) Register(ctx context.Context, req *domain.CreateUser) (string, error) {
-	id, err := s.repo.Create(ctx, req)
-	if err != nil {
-		return "", err
-	}
+func (s *UserService) Register(ctx context.Context, req *domain.CreateUser) (_ string, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.Register")
+	defer tracing.End(span, &err)
 
-	// Verification code yuborish
-	if req.Email != "" {
-		if sendErr := s.sendCode(req.Email); sendErr != nil {
-			s.logger.Warn().Err(sendErr).Str("email", req.Email).Msg("Failed to send verification email")
+	// UserRegistered commits with the user; its subscribers, such as the
+	// verification email, run after the commit and are retried until they
+	// succeed or run out of attempts
+	var id string
+	err = s.uow.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		if id, err = s.repo.Create(ctx, req); err != nil {
+			return err
 		}
+
+		// The user has no saved preferences yet, so answer in the language they registered in
+		prefs := domain.DefaultPreferences()
+		prefs.Locale = domain.Locale(i18n.FromContext(ctx))
+		event, err := UserRegistered.New(Registered{UserID: id, Email: req.Email, Preferences: prefs})
+		if err != nil {
+			return err
+		}
+		return s.outbox.Add(ctx, event)
+	})
+	if err != nil {
+		return "", err
 	}
+	metrics.Registrations.Inc()
 
 	return id, nil
 }
 
-// SendVerificationCode — resend uchun
-func (s *UserService) SendVerificationCode(ctx context.Context, req *domain.ResendCodeRequest) error {
-	_, err := s.repo.GetByEmail(ctx, req.Email)
+// SendVerificationCode sends a new code to an address that asked to resend it
+func (s *UserService) SendVerificationCode(ctx context.Context, req *domain.ResendCodeRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "UserService.SendVerificationCode")
+	defer tracing.End(span, &err)
+
+	user, err := s.repo.GetByEmail(ctx, req.Email)
 	if err != nil {
-		return errors.New("user not found")
+		return err
 	}
-	return s.sendCode(req.Email)
+	return s.enqueueVerificationEmail(ctx, email.Recipient{Email: req.Email, Preferences: user.Preferences})
 }
 
-// VerifyEmail — codeni tekshirib email_verified=true qiladi
-func (s *UserService) VerifyEmail(ctx context.Context, req *domain.VerifyEmailRequest) error {
+// VerifyEmail checks the code and marks the email as verified
+func (s *UserService) VerifyEmail(ctx context.Context, req *domain.VerifyEmailRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "UserService.VerifyEmail")
+	defer tracing.End(span, &err)
+
 	key := verifyKey(req.Email)
-	stored, err := s.cache.Get(key)
+	stored, err := s.cache.Get(ctx, key)
 	if err != nil {
-		return errors.New("code expired or not found")
+		metrics.EmailVerifications.WithLabelValues(metrics.ResultExpired).Inc()
+		return errs.Validation("verification code expired or not found", errs.FieldError{
+			Field: "code", Rule: "expired", Message: "code expired or not found",
+		})
 	}
 	if stored != req.Code {
-		return errors.New("invalid verification code")
+		metrics.EmailVerifications.WithLabelValues(metrics.ResultInvalidCode).Inc()
+		return errs.Validation("invalid verification code", errs.FieldError{
+			Field: "code", Rule: "match", Message: "code is invalid",
+		})
 	}
 
 	if err := s.repo.SetEmailVerified(ctx, req.Email); err != nil {
 		return err
 	}
+	metrics.EmailVerifications.WithLabelValues(metrics.ResultSuccess).Inc()
 
-	// Codeni o'chirish (TTL=0 qilib)
-	_ = s.cache.Set(key, "", time.Millisecond)
+	// A code can be used once
+	_ = s.cache.Delete(ctx, key)
 	return nil
 }
 
 // Login authenticates a user and returns tokens
-func (s *UserService) Login(ctx context.Context, req *domain.LoginRequest) (domain.LoginResponse, error) {
-	user, err := s.repo.GetByEmail(ctx, req.Email)
+func (s *UserService) Login(ctx context.Context, req *domain.LoginRequest) (_ domain.LoginResponse, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.Login")
+	defer tracing.End(span, &err)
+
+	user, err := s.repo.GetCredentials(ctx, req.Email)
 	if err != nil {
-		return domain.LoginResponse{}, errors.New("invalid credentials")
+		if errors.Is(err, errs.ErrNotFound) {
+			metrics.Logins.WithLabelValues(metrics.ResultInvalidCredentials).Inc()
+			return domain.LoginResponse{}, errs.Unauthorized("invalid credentials")
+		}
+		return domain.LoginResponse{}, err
+	}
+
+	if !passwordMatches(user, req.Password) {
+		metrics.Logins.WithLabelValues(metrics.ResultInvalidCredentials).Inc()
+		return domain.LoginResponse{}, errs.Unauthorized("invalid credentials")
 	}
 
+	// Only someone who knows the password learns that the address is unverified
 	if !user.EmailVerified {
-		return domain.LoginResponse{}, errors.New("email not verified")
+		metrics.Logins.WithLabelValues(metrics.ResultUnverified).Inc()
+		return domain.LoginResponse{}, errs.Forbidden("email not verified")
 	}
 
-	if user.Password == nil || *user.Password != req.Password {
-		return domain.LoginResponse{}, errors.New("invalid credentials")
+	if err := user.EffectiveStatus(time.Now()).Err(); err != nil {
+		metrics.Logins.WithLabelValues(metrics.ResultBlocked).Inc()
+		return domain.LoginResponse{}, err
 	}
 
 	accessToken, refreshToken, err := s.jwtManager.Generate(user)
 	if err != nil {
 		return domain.LoginResponse{}, err
 	}
+	metrics.Logins.WithLabelValues(metrics.ResultSuccess).Inc()
 
 	return domain.LoginResponse{
 		User:         convertToUserResponse(user),
@@ -120,7 +220,10 @@ func (s *UserService) Login(ctx context.Context, req *domain.LoginRequest) (doma
 }
 
 // GetByID retrieves a single user by ID
-func (s *UserService) GetByID(ctx context.Context, id uuid.UUID) (domain.UserResponse, error) {
+func (s *UserService) GetByID(ctx context.Context, id uuid.UUID) (_ domain.UserResponse, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetByID")
+	defer tracing.End(span, &err)
+
 	user, err := s.repo.GetByID(ctx, id)
 	if err != nil {
 		return domain.UserResponse{}, err
@@ -129,61 +232,366 @@ func (s *UserService) GetByID(ctx context.Context, id uuid.UUID) (domain.UserRes
 }
 
 // Update updates an existing user
-func (s *UserService) Update(ctx context.Context, req *domain.UpdateUser) (string, error) {
+func (s *UserService) Update(ctx context.Context, req *domain.UpdateUser) (_ string, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.Update")
+	defer tracing.End(span, &err)
+
 	if req == nil {
-		return "", errors.New("empty update request")
+		return "", errs.BadRequest("empty update request")
 	}
 	if req.ID == uuid.Nil {
-		return "", errors.New("invalid user id")
+		return "", errs.BadRequest("invalid user id")
 	}
 	return s.repo.Update(ctx, req, "")
 }
 
 // UpdatePassword checks old password and sets new one
-func (s *UserService) UpdatePassword(ctx context.Context, userID uuid.UUID, req *domain.UpdatePasswordRequest) error {
+func (s *UserService) UpdatePassword(ctx context.Context, userID uuid.UUID, req *domain.UpdatePasswordRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "UserService.UpdatePassword")
+	defer tracing.End(span, &err)
+
+	// The row lock makes a concurrent change wait for this transaction and
+	// then check against its result, at any isolation level
+	return s.uow.WithTx(ctx, func(ctx context.Context) error {
+		user, err := s.repo.GetByIDForUpdate(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		if !passwordMatches(user, req.OldPassword) {
+			return errs.Validation("invalid old password", errs.FieldError{
+				Field: "old_password", Rule: "match", Message: "old password is incorrect",
+			})
+		}
+
+		_, err = s.repo.Update(ctx, &domain.UpdateUser{ID: userID, Password: req.NewPassword}, req.NewPassword)
+		return err
+	})
+}
+
+// Delete removes a user
+func (s *UserService) Delete(ctx context.Context, id uuid.UUID) (err error) {
+	ctx, span := tracing.Start(ctx, "UserService.Delete")
+	defer tracing.End(span, &err)
+
+	if id == uuid.Nil {
+		return errs.BadRequest("invalid user id")
+	}
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return err
+	}
+
+	// A deleted user's token must stop working now, not when the state expires
+	s.dropAccountState(ctx, id)
+	return nil
+}
+
+// Search finds users by partial or mistyped names and emails, best matches first
+func (s *UserService) Search(ctx context.Context, req *domain.SearchUsersRequest) (_ domain.UserSearchList, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.Search")
+	defer tracing.End(span, &err)
+
+	text := strings.TrimSpace(req.Query)
+	if utf8.RuneCountInString(text) < 2 {
+		return domain.UserSearchList{}, errs.Validation("invalid search query", errs.FieldError{
+			Field: "q", Rule: "min", Message: "search query must be at least 2 characters",
+		})
+	}
+
+	page, pageSize := req.Page, req.PageSize
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = defaultPageSize
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+
+	hits, total, err := s.repo.Search(ctx, text, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return domain.UserSearchList{}, err
+	}
+
+	list := make([]domain.UserSearchResult, 0, len(hits))
+	for _, hit := range hits {
+		list = append(list, domain.UserSearchResult{
+			User:      convertToUserResponse(hit.User),
+			Rank:      hit.Rank,
+			Highlight: hit.Highlight,
+		})
+	}
+
+	return domain.UserSearchList{
+		List: list,
+		Meta: domain.Meta{
+			Total:     total,
+			Page:      page,
+			PageSize:  pageSize,
+			PageCount: (total + pageSize - 1) / pageSize,
+		},
+	}, nil
+}
+
+// GetAccountState returns the current status and role of an account,
+// served from cache when possible
+func (s *UserService) GetAccountState(ctx context.Context, id uuid.UUID) (_ domain.AccountState, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetAccountState")
+	defer tracing.End(span, &err)
+
+	if cached, err := cache.GetJSON[domain.AccountState](ctx, s.cache, stateKey(id)); err == nil && cached.Status != "" {
+		return cached, nil
+	}
+
+	user, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return domain.AccountState{}, err
+	}
+
+	state := domain.AccountState{Status: user.EffectiveStatus(time.Now()), Role: user.Role}
+	ttl := accountStatusTTL
+	if state.Status == domain.AccountStatusSuspended && user.SuspendedUntil != nil {
+		if untilEnd := time.Until(*user.SuspendedUntil); untilEnd < ttl {
+			ttl = untilEnd + time.Second
+		}
+	}
+	_ = cache.SetJSON(ctx, s.cache, stateKey(id), state, ttl)
+
+	return state, nil
+}
+
+// Suspend temporarily blocks an account, optionally until the given time
+func (s *UserService) Suspend(ctx context.Context, actorID, userID uuid.UUID, req *domain.SuspendRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "UserService.Suspend")
+	defer tracing.End(span, &err)
+
+	if req.Until != nil && !req.Until.After(time.Now()) {
+		return errs.Validation("invalid suspension end", errs.FieldError{
+			Field: "until", Rule: "future", Message: "suspension end must be in the future",
+		})
+	}
+	return s.changeStatus(ctx, domain.StatusChange{
+		UserID:         userID,
+		Status:         domain.AccountStatusSuspended,
+		Reason:         req.Reason,
+		ChangedBy:      actorID,
+		SuspendedUntil: req.Until,
+	}, domain.AccountStatusActive, domain.AccountStatusLocked)
+}
+
+// Unsuspend reactivates a suspended or locked account
+func (s *UserService) Unsuspend(ctx context.Context, actorID, userID uuid.UUID, req *domain.UnsuspendRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "UserService.Unsuspend")
+	defer tracing.End(span, &err)
+
+	return s.changeStatus(ctx, domain.StatusChange{
+		UserID:    userID,
+		Status:    domain.AccountStatusActive,
+		Reason:    req.Reason,
+		ChangedBy: actorID,
+	}, domain.AccountStatusSuspended, domain.AccountStatusLocked)
+}
+
+// Ban permanently blocks an account
+func (s *UserService) Ban(ctx context.Context, actorID, userID uuid.UUID, req *domain.BanRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "UserService.Ban")
+	defer tracing.End(span, &err)
+
+	return s.changeStatus(ctx, domain.StatusChange{
+		UserID:    userID,
+		Status:    domain.AccountStatusBanned,
+		Reason:    req.Reason,
+		ChangedBy: actorID,
+	}, domain.AccountStatusActive, domain.AccountStatusSuspended, domain.AccountStatusLocked)
+}
+
+// GetPreferences returns the user's preferences with defaults applied
+func (s *UserService) GetPreferences(ctx context.Context, userID uuid.UUID) (_ domain.Preferences, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetPreferences")
+	defer tracing.End(span, &err)
+
 	user, err := s.repo.GetByID(ctx, userID)
 	if err != nil {
-		return err
+		return domain.Preferences{}, err
 	}
+	return user.Preferences, nil
+}
 
-	if user.Password == nil || *user.Password != req.OldPassword {
-		return errors.New("invalid old password")
+// UpdatePreferences applies a partial update; keys it leaves out keep their
+// stored values, even when another update changes them concurrently
+func (s *UserService) UpdatePreferences(ctx context.Context, userID uuid.UUID, req *domain.UpdatePreferencesRequest) (_ domain.Preferences, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.UpdatePreferences")
+	defer tracing.End(span, &err)
+
+	// Stored documents are valid, so checking the changed keys on top of the
+	// defaults checks the merged one
+	if err := domain.DefaultPreferences().Apply(*req).Validate(); err != nil {
+		return domain.Preferences{}, err
 	}
 
-	_, err = s.repo.Update(ctx, &domain.UpdateUser{ID: userID, Password: req.NewPassword}, req.NewPassword)
-	return err
+	return s.repo.MergePreferences(ctx, userID, *req)
 }
 
-// Delete removes a user
-func (s *UserService) Delete(ctx context.Context, id uuid.UUID) error {
-	if id == uuid.Nil {
-		return errors.New("invalid user id")
+// Impersonate issues a short-lived token that lets an admin act as a regular user.
+// Other admins cannot be impersonated, and the start of every session is written to the audit trail.
+func (s *UserService) Impersonate(ctx context.Context, actorID, targetID uuid.UUID, req *domain.ImpersonateRequest) (_ domain.ImpersonationResponse, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.Impersonate")
+	defer tracing.End(span, &err)
+
+	if actorID == targetID {
+		return domain.ImpersonationResponse{}, errs.BadRequest("cannot impersonate yourself")
+	}
+
+	target, err := s.repo.GetByID(ctx, targetID)
+	if err != nil {
+		return domain.ImpersonationResponse{}, err
+	}
+	if target.Role != "" && target.Role != domain.RoleUser {
+		return domain.ImpersonationResponse{}, domain.ErrImpersonationForbidden
+	}
+	if err := target.EffectiveStatus(time.Now()).Err(); err != nil {
+		return domain.ImpersonationResponse{}, err
+	}
+
+	accessToken, expiresAt, err := s.jwtManager.GenerateImpersonation(target, actorID, s.impersonationTTL)
+	if err != nil {
+		return domain.ImpersonationResponse{}, err
+	}
+
+	if err := s.audit.Create(ctx, domain.AuditEntry{
+		ActorID:   actorID,
+		SubjectID: &target.ID,
+		Action:    domain.AuditActionImpersonationStart,
+		Reason:    req.Reason,
+		IP:        req.IP,
+		UserAgent: req.UserAgent,
+	}); err != nil {
+		return domain.ImpersonationResponse{}, err
+	}
+
+	s.logger.Info().Ctx(ctx).
+		Str("actor_id", actorID.String()).
+		Str("user_id", targetID.String()).
+		Time("expires_at", expiresAt).
+		Msg("Impersonation session started")
+
+	return domain.ImpersonationResponse{
+		User:        convertToUserResponse(target),
+		AccessToken: accessToken,
+		ExpiresAt:   expiresAt,
+	}, nil
+}
+
+// Name implements export.Contributor
+func (s *UserService) Name() string {
+	return "user"
+}
+
+// Collect implements export.Contributor: the user's own profile row and preferences
+func (s *UserService) Collect(ctx context.Context, userID uuid.UUID) (_ any, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.Collect")
+	defer tracing.End(span, &err)
+
+	user, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
 	}
-	return s.repo.Delete(ctx, id)
+	return map[string]any{
+		"profile":     convertToUserResponse(user),
+		"preferences": user.Preferences,
+	}, nil
 }
 
 // --- helpers ---
 
-func (s *UserService) sendCode(toEmail string) error {
+func (s *UserService) sendCode(ctx context.Context, to email.Recipient) error {
 	code := fmt.Sprintf("%06d", rand.Intn(1000000))
-	if err := s.cache.Set(verifyKey(toEmail), code, verifyCodeTTL); err != nil {
+	if err := s.cache.Set(ctx, verifyKey(to.Email), code, verifyCodeTTL); err != nil {
 		return err
 	}
-	return s.emailSender.SendVerificationCode(toEmail, code)
+	return s.emailSender.SendVerificationCode(ctx, to, code)
+}
+
+func (s *UserService) changeStatus(ctx context.Context, change domain.StatusChange, allowedFrom ...domain.AccountStatus) error {
+	if change.UserID == change.ChangedBy {
+		return errs.Forbidden("cannot change the status of your own account")
+	}
+
+	// As in UpdatePassword, the row lock orders concurrent changes, so each
+	// checks the transition against the status the previous one left
+	err := s.uow.WithTx(ctx, func(ctx context.Context) error {
+		user, err := s.repo.GetByIDForUpdate(ctx, change.UserID)
+		if err != nil {
+			return err
+		}
+
+		if !slices.Contains(allowedFrom, user.EffectiveStatus(time.Now())) {
+			return domain.ErrInvalidStatusTransition
+		}
+
+		if err := s.repo.SetStatus(ctx, change); err != nil {
+			return err
+		}
+
+		job, err := AccountStatusEmailJob.New(AccountStatusEmail{
+			UserID: change.UserID,
+			Status: change.Status,
+			Reason: change.Reason,
+			Until:  change.SuspendedUntil,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = s.jobs.Enqueue(ctx, job)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	// Drop the cached state so JWTAuth sees the change immediately
+	s.dropAccountState(ctx, change.UserID)
+	return nil
+}
+
+// dropAccountState forgets the cached account state of a user. A failure only
+// delays the change until accountStatusTTL, so it is logged, not returned
+func (s *UserService) dropAccountState(ctx context.Context, id uuid.UUID) {
+	if err := s.cache.Delete(ctx, stateKey(id)); err != nil {
+		s.logger.Warn().Ctx(ctx).Err(err).Str("user_id", id.String()).Msg("Failed to drop cached account state")
+	}
+}
+
+// passwordMatches compares in constant time, so response times do not tell
+// how much of a guess was right
+func passwordMatches(user domain.User, password string) bool {
+	return user.Password != nil && subtle.ConstantTimeCompare([]byte(*user.Password), []byte(password)) == 1
 }
 
 func verifyKey(email string) string {
 	return "verify:" + email
 }
 
+func stateKey(id uuid.UUID) string {
+	return "account_state:" + id.String()
+}
+
 func convertToUserResponse(user domain.User) domain.UserResponse {
 	resp := domain.UserResponse{
 		ID:            user.ID,
 		FirstName:     user.FirstName,
 		LastName:      user.LastName,
 		EmailVerified: user.EmailVerified,
-		CreatedAt:     user.CreatedAt,
-		UpdatedAt:     user.UpdatedAt,
+		Role:          user.Role,
+
+		Status:          user.EffectiveStatus(time.Now()),
+		StatusReason:    user.StatusReason,
+		StatusChangedAt: user.StatusChangedAt,
+		SuspendedUntil:  user.SuspendedUntil,
+
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
 	}
 	if user.Email != nil {
 		resp.Email = *user.Email
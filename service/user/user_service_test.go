@@ -0,0 +1,111 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+
+	errs "github.com/infosec554/clean-archtectura/domain"
+	domain "github.com/infosec554/clean-archtectura/domain/users"
+	"github.com/infosec554/clean-archtectura/pkg/cache"
+	"github.com/infosec554/clean-archtectura/pkg/token"
+)
+
+// fakeUsers implements the lookups the tests need; any other repository call panics
+type fakeUsers struct {
+	UserRepository
+	byEmail map[string]domain.User
+	deleted map[uuid.UUID]bool
+}
+
+func (f fakeUsers) GetCredentials(_ context.Context, email string) (domain.User, error) {
+	user, ok := f.byEmail[email]
+	if !ok {
+		return domain.User{}, errs.NotFound("user not found")
+	}
+	return user, nil
+}
+
+func (f fakeUsers) Delete(_ context.Context, id uuid.UUID) error {
+	if f.deleted[id] {
+		return errs.NotFound("user not found")
+	}
+	f.deleted[id] = true
+	return nil
+}
+
+func TestLogin(t *testing.T) {
+	password := "Secret-123"
+	users := fakeUsers{byEmail: map[string]domain.User{
+		"verified@example.com":   {Password: &password, EmailVerified: true, Status: domain.AccountStatusActive},
+		"unverified@example.com": {Password: &password, Status: domain.AccountStatusActive},
+		"banned@example.com":     {Password: &password, EmailVerified: true, Status: domain.AccountStatusBanned},
+		"oauth@example.com":      {EmailVerified: true, Status: domain.AccountStatusActive},
+	}}
+	svc := &UserService{repo: users, logger: zerolog.Nop(), jwtManager: token.NewJWTManager("test-secret")}
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+		want     error
+	}{
+		{"success", "verified@example.com", password, nil},
+		{"unknown email", "nobody@example.com", password, errs.ErrUnauthorized},
+		{"wrong password", "verified@example.com", "wrong", errs.ErrUnauthorized},
+		{"wrong password for an unverified account", "unverified@example.com", "wrong", errs.ErrUnauthorized},
+		{"wrong password for a banned account", "banned@example.com", "wrong", errs.ErrUnauthorized},
+		{"no password set", "oauth@example.com", "", errs.ErrUnauthorized},
+		{"unverified", "unverified@example.com", password, errs.ErrForbidden},
+		{"banned", "banned@example.com", password, errs.ErrForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := svc.Login(context.Background(), &domain.LoginRequest{Email: tt.email, Password: tt.password})
+			if tt.want == nil {
+				if err != nil || resp.AccessToken == "" {
+					t.Fatalf("Login = %v, want tokens", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Login = %v, want %v", err, tt.want)
+			}
+			// Failures before the password matched must look identical
+			if tt.want == errs.ErrUnauthorized && err.Error() != errs.Unauthorized("invalid credentials").Error() {
+				t.Errorf("Login = %q, want the generic message", err)
+			}
+		})
+	}
+}
+
+func TestDeleteDropsAccountState(t *testing.T) {
+	ctx := context.Background()
+	id := uuid.New()
+	states := cache.NewMemory()
+	svc := &UserService{repo: fakeUsers{deleted: map[uuid.UUID]bool{}}, cache: states, logger: zerolog.Nop()}
+
+	if err := states.Set(ctx, stateKey(id), "active", accountStatusTTL); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Delete(ctx, id); err != nil {
+		t.Fatalf("Delete = %v", err)
+	}
+	if _, err := states.Get(ctx, stateKey(id)); !errors.Is(err, cache.ErrNotFound) {
+		t.Fatalf("cached state after Delete: error = %v, want ErrNotFound", err)
+	}
+
+	// A failed delete leaves the cache alone
+	if err := states.Set(ctx, stateKey(id), "active", accountStatusTTL); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Delete(ctx, id); !errors.Is(err, errs.ErrNotFound) {
+		t.Fatalf("second Delete = %v, want ErrNotFound", err)
+	}
+	if _, err := states.Get(ctx, stateKey(id)); err != nil {
+		t.Fatalf("cached state after a failed Delete: %v", err)
+	}
+}